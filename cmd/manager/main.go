@@ -6,12 +6,15 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
 	"github.com/cvicens/rocketeer-operator/pkg/apis"
 	"github.com/cvicens/rocketeer-operator/pkg/controller"
+	"github.com/cvicens/rocketeer-operator/pkg/seal"
+	"github.com/cvicens/rocketeer-operator/pkg/tracing"
 
 	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
 	"github.com/operator-framework/operator-sdk/pkg/leader"
@@ -20,6 +23,9 @@ import (
 	"github.com/operator-framework/operator-sdk/pkg/restmapper"
 	sdkVersion "github.com/operator-framework/operator-sdk/version"
 	"github.com/spf13/pflag"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
@@ -33,6 +39,11 @@ var (
 )
 var log = logf.Log.WithName("cmd")
 
+var traceEndpoint = pflag.String("trace-endpoint", "", "Address of the OpenCensus agent, or OpenTelemetry collector with an opencensus receiver, spans are exported to. Tracing is off when empty")
+var traceSampling = pflag.Float64("trace-sampling", 1, "Fraction of reconciles traced")
+var sealingKeyRotation = pflag.Duration("sealing-key-rotation", 30*24*time.Hour, "Age after which a new key is generated for SealedSecrets, old keys are kept to unseal the values sealed to them. 0 never rotates")
+var serviceMonitor = pflag.Bool("service-monitor", false, "Create a ServiceMonitor for the metrics Service when the prometheus-operator is installed")
+
 func printVersion() {
 	log.Info(fmt.Sprintf("Go Version: %s", runtime.Version()))
 	log.Info(fmt.Sprintf("Go OS/Arch: %s/%s", runtime.GOOS, runtime.GOARCH))
@@ -62,6 +73,15 @@ func main() {
 
 	printVersion()
 
+	if *traceEndpoint != "" {
+		stop, err := tracing.Start(*traceEndpoint, tracing.DefaultServiceName, *traceSampling)
+		if err != nil {
+			log.Error(err, "Failed to export traces")
+			os.Exit(1)
+		}
+		defer stop()
+	}
+
 	namespace, err := k8sutil.GetWatchNamespace()
 	if err != nil {
 		log.Error(err, "Failed to get watch namespace")
@@ -109,12 +129,33 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Generate the key SealedSecrets are sealed to in the operator namespace and rotate it
+	if operatorNamespace, err := k8sutil.GetOperatorNamespace(); err == nil {
+		c, err := client.New(cfg, client.Options{Scheme: mgr.GetScheme(), Mapper: mgr.GetRESTMapper()})
+		if err == nil {
+			err = mgr.Add(&seal.KeyRotator{Client: c, Logger: log, Namespace: operatorNamespace, MaxAge: *sealingKeyRotation})
+		}
+		if err != nil {
+			log.Error(err, "")
+			os.Exit(1)
+		}
+	} else {
+		log.Info("Sealing keys not managed: " + err.Error())
+	}
+
 	// Create Service object to expose the metrics port.
-	_, err = metrics.ExposeMetricsPort(ctx, metricsPort)
+	service, err := metrics.ExposeMetricsPort(ctx, metricsPort)
 	if err != nil {
 		log.Info(err.Error())
 	}
 
+	// Create a ServiceMonitor to scrape it, nothing is created if the prometheus-operator is not installed
+	if *serviceMonitor && service != nil {
+		if _, err := metrics.CreateServiceMonitors(cfg, service.Namespace, []*v1.Service{service}); err != nil && !errors.IsAlreadyExists(err) {
+			log.Info("Create ServiceMonitor err: " + err.Error())
+		}
+	}
+
 	log.Info("Starting the Cmd.")
 
 	// Start the Cmd
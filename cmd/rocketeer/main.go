@@ -0,0 +1,131 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+
+	"github.com/cvicens/rocketeer-operator/pkg/seal"
+	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+	"sigs.k8s.io/yaml"
+)
+
+const usage = `Usage: rocketeer seal [flags] [FILE]
+
+Seals the Secret in FILE, or standard input, into a SealedSecret descriptor printed on standard output. Its values
+can only be unsealed by the operator, into a Secret of the same name in the namespace of the Configuration applying
+the descriptor. With --raw, standard input is sealed as a single value of the Secret --name and only the sealed
+value is printed.
+
+The public key is read from the namespace the operator runs in, --operator-namespace, unless --public-key is set.
+
+Flags:
+`
+
+func main() {
+	flags := pflag.NewFlagSet("seal", pflag.ExitOnError)
+	namespace := flags.StringP("namespace", "n", "", "Namespace of the Configuration applying the Secret, the namespace of the Secret by default")
+	name := flags.String("name", "", "Name of the Secret the value sealed with --raw belongs to")
+	raw := flags.Bool("raw", false, "Seal standard input as a single value")
+	publicKey := flags.String("public-key", "", "Public key to seal to, read from the "+seal.SEALING_PUBLIC_KEY_CONFIGMAP+" ConfigMap when empty")
+	operatorNamespace := flags.String("operator-namespace", "", "Namespace of the operator the public key is read from, required without --public-key")
+	// Adds --kubeconfig
+	flags.AddGoFlagSet(flag.CommandLine)
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flags.PrintDefaults()
+	}
+
+	if len(os.Args) < 2 || os.Args[1] != "seal" {
+		flags.Usage()
+		os.Exit(2)
+	}
+	flags.Parse(os.Args[2:])
+
+	public, err := getPublicKey(*publicKey, *operatorNamespace)
+	if err != nil {
+		fail(err)
+	}
+
+	if *raw {
+		if len(*namespace) == 0 || len(*name) == 0 {
+			fail(fmt.Errorf("--raw requires --namespace and --name"))
+		}
+		value, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fail(err)
+		}
+		sealed, err := seal.Seal(public, *namespace, *name, value)
+		if err != nil {
+			fail(err)
+		}
+		fmt.Println(sealed)
+		return
+	}
+
+	input := os.Stdin
+	if flags.NArg() > 0 {
+		if input, err = os.Open(flags.Arg(0)); err != nil {
+			fail(err)
+		}
+		defer input.Close()
+	}
+	buffer, err := ioutil.ReadAll(input)
+	if err != nil {
+		fail(err)
+	}
+	secret := &corev1.Secret{}
+	if err := yaml.Unmarshal(buffer, secret); err != nil {
+		fail(err)
+	}
+	if secret.Kind != "Secret" {
+		fail(fmt.Errorf("not a Secret: %s", secret.Kind))
+	}
+	if len(*namespace) == 0 {
+		*namespace = secret.Namespace
+	}
+	if len(*namespace) == 0 {
+		fail(fmt.Errorf("the Secret has no namespace, set --namespace"))
+	}
+
+	sealed, err := seal.SealSecret(public, *namespace, secret)
+	if err != nil {
+		fail(err)
+	}
+	out, err := yaml.Marshal(sealed)
+	if err != nil {
+		fail(err)
+	}
+	os.Stdout.Write(out)
+}
+
+// getPublicKey parses publicKey, or reads the public key published by the operator in operatorNamespace
+func getPublicKey(publicKey, operatorNamespace string) (seal.PublicKey, error) {
+	if len(publicKey) > 0 {
+		return seal.ParsePublicKey(publicKey)
+	}
+	if len(operatorNamespace) == 0 {
+		return seal.PublicKey{}, fmt.Errorf("set --operator-namespace or --public-key")
+	}
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return seal.PublicKey{}, err
+	}
+	c, err := client.New(cfg, client.Options{})
+	if err != nil {
+		return seal.PublicKey{}, err
+	}
+	return seal.ReadPublicKey(c, operatorNamespace)
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "rocketeer seal: "+err.Error())
+	os.Exit(1)
+}
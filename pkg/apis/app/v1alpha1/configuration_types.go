@@ -1,6 +1,7 @@
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -16,6 +17,66 @@ type ConfigurationSpec struct {
 	GitUrl            string `json:"gitUrl"`
 	GitRef            string `json:"gitRef"`
 	DescriptorsFolder string `json:"descriptorsFolder"`
+	// Environment selects the overlays/<environment> folder patched onto base/
+	Environment string `json:"environment,omitempty"`
+	// Jsonnet holds the variables passed to .jsonnet descriptors
+	Jsonnet JsonnetSpec `json:"jsonnet,omitempty"`
+	// HealthRules override the operator-wide health rules for the same group and kind
+	HealthRules []HealthRule `json:"healthRules,omitempty"`
+	// WaveTimeoutSeconds is how long a sync wave can take to become healthy before the Configuration is Degraded, 600
+	// by default. The next waves are still only applied once the wave is healthy.
+	WaveTimeoutSeconds *int32 `json:"waveTimeoutSeconds,omitempty"`
+	// IgnoreDifferences add to the operator-wide rules for fields owned by someone else
+	IgnoreDifferences []IgnoreDifference `json:"ignoreDifferences,omitempty"`
+	// BestEffort applies objects one by one without validating the whole set with a server-side dry run first
+	BestEffort bool `json:"bestEffort,omitempty"`
+	// RollbackWindowSeconds enables automatic rollback: a revision that makes a Deployment or DeploymentConfig
+	// Degraded within this many seconds of being applied is replaced by the last healthy revision
+	RollbackWindowSeconds *int32 `json:"rollbackWindowSeconds,omitempty"`
+	// RevisionHistoryLimit is the number of revisions kept in status, 10 by default
+	RevisionHistoryLimit *int32 `json:"revisionHistoryLimit,omitempty"`
+	// SyncRunHistoryLimit is the number of SyncRuns kept, 10 by default
+	SyncRunHistoryLimit *int32 `json:"syncRunHistoryLimit,omitempty"`
+	// SnapshotStorage is the kind of object the snapshots of live objects are kept in, Secret (the default) or
+	// ConfigMap. Secrets are only snapshotted into a Secret.
+	SnapshotStorage string `json:"snapshotStorage,omitempty"`
+	// SnapshotHistoryLimit is the number of snapshots kept, 20 by default
+	SnapshotHistoryLimit *int32 `json:"snapshotHistoryLimit,omitempty"`
+	// SnapshotMaxBytes is the most compressed bytes of snapshots kept, 512KiB by default
+	SnapshotMaxBytes *int32 `json:"snapshotMaxBytes,omitempty"`
+	// DecryptionSecret names the Secret holding the age (*.agekey) and PGP (*.asc) private keys that decrypt the
+	// Secret descriptors encrypted with sops
+	DecryptionSecret string `json:"decryptionSecret,omitempty"`
+}
+
+// JsonnetSpec defines the external variables and top-level arguments used to evaluate .jsonnet descriptors
+// +k8s:openapi-gen=true
+type JsonnetSpec struct {
+	ExtVars map[string]string `json:"extVars,omitempty"`
+	TLAs    map[string]string `json:"tlas,omitempty"`
+}
+
+// HealthRule defines when objects of a group and kind are healthy, progressing or degraded. Each list holds
+// JSONPath expressions such as `status.conditions[?(@.type=="Ready")].status == "True"` and matches if any of
+// them holds. Degraded is checked first, then Healthy, then Progressing; an object matching none is Progressing.
+// +k8s:openapi-gen=true
+type HealthRule struct {
+	Group       string   `json:"group,omitempty"`
+	Kind        string   `json:"kind"`
+	Healthy     []string `json:"healthy,omitempty"`
+	Progressing []string `json:"progressing,omitempty"`
+	Degraded    []string `json:"degraded,omitempty"`
+}
+
+// IgnoreDifference lists fields of the objects of a group and kind, or of the object of that name only, that are
+// neither compared nor overwritten, e.g. `/spec/replicas` or `metadata.annotations['sidecar.istio.io/status']`
+// +k8s:openapi-gen=true
+type IgnoreDifference struct {
+	Group        string   `json:"group,omitempty"`
+	Kind         string   `json:"kind"`
+	Name         string   `json:"name,omitempty"`
+	JSONPointers []string `json:"jsonPointers,omitempty"`
+	JSONPaths    []string `json:"jsonPaths,omitempty"`
 }
 
 // ConfigurationStatus defines the observed state of Configuration
@@ -25,6 +86,133 @@ type ConfigurationStatus struct {
 	// Important: Run "operator-sdk generate k8s" to regenerate code after modifying this file
 	// Add custom validation using kubebuilder tags: https://book.kubebuilder.io/beyond_basics/generating_crd.html
 	State string `json:"state"`
+	// Overlays lists the objects touched by each overlay file
+	Overlays []OverlayStatus `json:"overlays,omitempty"`
+	// Health is the aggregated health of the applied objects
+	Health HealthStatus `json:"health,omitempty"`
+	// Resources holds the health of each applied object that could be assessed
+	Resources  []ResourceHealth         `json:"resources,omitempty"`
+	Conditions []ConfigurationCondition `json:"conditions,omitempty"`
+	// CurrentWave is the sync wave the next waves are waiting on, nil once every wave has been applied
+	CurrentWave *WaveStatus `json:"currentWave,omitempty"`
+	// Changes lists the objects updated by the last sync and the fields that differed
+	Changes []ResourceChange `json:"changes,omitempty"`
+	// Revision is the commit applied by the last sync
+	Revision string `json:"revision,omitempty"`
+	// History lists the revisions applied, the most recent last
+	History []RevisionHistory `json:"history,omitempty"`
+	// RolledBack is set while a revision is rolled back, auto-sync resumes with the next commit
+	RolledBack *RollbackStatus `json:"rolledBack,omitempty"`
+	// Restored lists the objects restored from a snapshot, they are left as restored until their descriptor changes
+	Restored []RestoredObject `json:"restored,omitempty"`
+	// Applied lists the version of each object once last applied, objects unchanged since are not compared again
+	Applied []AppliedObject `json:"applied,omitempty"`
+}
+
+// AppliedObject records the version an object had once last applied
+// +k8s:openapi-gen=true
+type AppliedObject struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	// Version is the generation of the object, or its resourceVersion for kinds without a generation
+	Version string `json:"version"`
+}
+
+// RestoredObject describes an object restored from a snapshot
+// +k8s:openapi-gen=true
+type RestoredObject struct {
+	// Snapshot is the key of the snapshot restored
+	Snapshot string      `json:"snapshot"`
+	Kind     string      `json:"kind"`
+	Name     string      `json:"name"`
+	Time     metav1.Time `json:"time"`
+	// DesiredHash is the hash of the descriptor of the object when it was restored, without the data of Secrets
+	DesiredHash string `json:"desiredHash,omitempty"`
+}
+
+// RevisionHistory records when a revision was first applied and whether it became healthy
+// +k8s:openapi-gen=true
+type RevisionHistory struct {
+	SHA       string      `json:"sha"`
+	AppliedAt metav1.Time `json:"appliedAt"`
+	Healthy   bool        `json:"healthy,omitempty"`
+}
+
+// RollbackStatus describes a revision replaced by the last healthy one
+// +k8s:openapi-gen=true
+type RollbackStatus struct {
+	// BadRevision is the revision that was rolled back
+	BadRevision string `json:"badRevision"`
+	// Revision is the healthy revision applied instead
+	Revision string      `json:"revision"`
+	Time     metav1.Time `json:"time"`
+}
+
+// ResourceChange describes an object updated because it differed from its descriptor
+// +k8s:openapi-gen=true
+type ResourceChange struct {
+	Kind   string   `json:"kind"`
+	Name   string   `json:"name"`
+	Fields []string `json:"fields,omitempty"`
+}
+
+// WaveStatus describes a sync wave that is not healthy yet
+// +k8s:openapi-gen=true
+type WaveStatus struct {
+	Wave      int         `json:"wave"`
+	StartTime metav1.Time `json:"startTime"`
+	// BlockedBy describes the object the wave is waiting for
+	BlockedBy string `json:"blockedBy,omitempty"`
+	TimedOut  bool   `json:"timedOut,omitempty"`
+}
+
+// HealthStatus is the health of an applied object or of the whole Configuration
+type HealthStatus string
+
+const (
+	HealthHealthy     HealthStatus = "Healthy"
+	HealthProgressing HealthStatus = "Progressing"
+	HealthDegraded    HealthStatus = "Degraded"
+)
+
+// ResourceHealth is the health of a single applied object
+// +k8s:openapi-gen=true
+type ResourceHealth struct {
+	Kind    string       `json:"kind"`
+	Name    string       `json:"name"`
+	Health  HealthStatus `json:"health"`
+	Message string       `json:"message,omitempty"`
+}
+
+// ConfigurationConditionType is a valid value for ConfigurationCondition.Type
+type ConfigurationConditionType string
+
+const (
+	ConfigurationHealthy     ConfigurationConditionType = "Healthy"
+	ConfigurationProgressing ConfigurationConditionType = "Progressing"
+	ConfigurationDegraded    ConfigurationConditionType = "Degraded"
+	// ConfigurationDryRunFailed is true when the last sync was aborted because the server-side dry run failed
+	ConfigurationDryRunFailed ConfigurationConditionType = "DryRunFailed"
+	// ConfigurationRolledBack is true while a revision is rolled back
+	ConfigurationRolledBack ConfigurationConditionType = "RolledBack"
+)
+
+// ConfigurationCondition describes the state of a Configuration at a certain point
+// +k8s:openapi-gen=true
+type ConfigurationCondition struct {
+	Type               ConfigurationConditionType `json:"type"`
+	Status             corev1.ConditionStatus     `json:"status"`
+	LastTransitionTime metav1.Time                `json:"lastTransitionTime,omitempty"`
+	Reason             string                     `json:"reason,omitempty"`
+	Message            string                     `json:"message,omitempty"`
+}
+
+// OverlayStatus records which objects an overlay file patched or added
+// +k8s:openapi-gen=true
+type OverlayStatus struct {
+	File    string   `json:"file"`
+	Objects []string `json:"objects"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -0,0 +1,108 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SyncTrigger is what started a sync
+type SyncTrigger string
+
+const (
+	SyncTriggerPoll    SyncTrigger = "poll"
+	SyncTriggerWebhook SyncTrigger = "webhook"
+	SyncTriggerManual  SyncTrigger = "manual"
+	SyncTriggerDrift   SyncTrigger = "drift"
+)
+
+// SyncRunSpec describes what a sync applied
+// +k8s:openapi-gen=true
+type SyncRunSpec struct {
+	// Configuration is the name of the Configuration synced
+	Configuration string      `json:"configuration"`
+	Trigger       SyncTrigger `json:"trigger"`
+	// FromRevision is the revision applied before the sync, empty for the first one
+	FromRevision string `json:"fromRevision,omitempty"`
+	ToRevision   string `json:"toRevision"`
+	// Commits lists the commits after FromRevision up to ToRevision, the most recent first
+	Commits []SyncRunCommit `json:"commits,omitempty"`
+}
+
+// SyncRunCommit is a commit applied by a sync
+// +k8s:openapi-gen=true
+type SyncRunCommit struct {
+	SHA     string `json:"sha"`
+	Message string `json:"message"`
+}
+
+// SyncRunPhase is the outcome of a sync
+type SyncRunPhase string
+
+const (
+	SyncRunSucceeded SyncRunPhase = "Succeeded"
+	SyncRunFailed    SyncRunPhase = "Failed"
+	// SyncRunNoChanges is a sync that found every object up to date
+	SyncRunNoChanges SyncRunPhase = "NoChanges"
+)
+
+// SyncAction is what a sync did to an object
+type SyncAction string
+
+const (
+	// SyncActionApplied is used for objects created, or updated without being compared first
+	SyncActionApplied   SyncAction = "Applied"
+	SyncActionUpdated   SyncAction = "Updated"
+	SyncActionUnchanged SyncAction = "Unchanged"
+	SyncActionReplaced  SyncAction = "Replaced"
+	SyncActionSkipped   SyncAction = "Skipped"
+	SyncActionFailed    SyncAction = "Failed"
+)
+
+// SyncRunStatus is the outcome of a sync
+// +k8s:openapi-gen=true
+type SyncRunStatus struct {
+	Phase          SyncRunPhase    `json:"phase"`
+	StartTime      metav1.Time     `json:"startTime"`
+	CompletionTime metav1.Time     `json:"completionTime"`
+	Duration       metav1.Duration `json:"duration"`
+	// Count is the number of consecutive NoChanges syncs of the same revision and trigger recorded by this SyncRun,
+	// StartTime is the start of the first one, CompletionTime and Duration are those of the last one
+	Count int32 `json:"count,omitempty"`
+	// Objects is empty when no object changed
+	Objects []SyncRunObject `json:"objects,omitempty"`
+	// Error is why the sync failed as a whole
+	Error string `json:"error,omitempty"`
+}
+
+// SyncRunObject is what a sync did to a single object
+// +k8s:openapi-gen=true
+type SyncRunObject struct {
+	Kind   string     `json:"kind"`
+	Name   string     `json:"name"`
+	Action SyncAction `json:"action"`
+	Error  string     `json:"error,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// SyncRun records a sync attempt of a Configuration
+// +k8s:openapi-gen=true
+type SyncRun struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SyncRunSpec   `json:"spec,omitempty"`
+	Status SyncRunStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// SyncRunList contains a list of SyncRun
+type SyncRunList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SyncRun `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SyncRun{}, &SyncRunList{})
+}
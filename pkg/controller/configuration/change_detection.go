@@ -0,0 +1,196 @@
+package configuration
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	appv1alpha1 "github.com/cvicens/rocketeer-operator/pkg/apis/app/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	k8s_yaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DESIRED_HASH_ANNOTATION holds the hash of the descriptor an object was last applied from
+const DESIRED_HASH_ANNOTATION = "app.rocketeer.com/desired-hash"
+
+// appliedObjects finds the objects unchanged since they were last applied: their DESIRED_HASH_ANNOTATION matches
+// the hash of their descriptor and their version is the one recorded in the Status of the Configuration after
+// applying them. The live objects are listed once per kind, by the label set on the applied objects, instead of
+// reading each object.
+type appliedObjects struct {
+	r        *ReconcileConfiguration
+	instance *appv1alpha1.Configuration
+	// live holds the listed objects by name of each kind, nil for the kinds that could not be listed
+	live map[schema.GroupVersionKind]map[string]liveObject
+}
+
+// liveObject is what change detection needs of a listed object
+type liveObject struct {
+	desiredHash string
+	version     string
+}
+
+// newAppliedObjects returns the objects applied by instance, forgetting those no longer in descriptors
+func newAppliedObjects(r *ReconcileConfiguration, instance *appv1alpha1.Configuration, descriptors []descriptor) *appliedObjects {
+	current := map[string]bool{}
+	for _, d := range descriptors {
+		current[appliedKey(d.apiVersion, d.kind, d.name)] = true
+	}
+	applied := []appv1alpha1.AppliedObject{}
+	for _, object := range instance.Status.Applied {
+		if current[appliedKey(object.APIVersion, object.Kind, object.Name)] {
+			applied = append(applied, object)
+		}
+	}
+	instance.Status.Applied = applied
+	return &appliedObjects{r: r, instance: instance, live: map[schema.GroupVersionKind]map[string]liveObject{}}
+}
+
+func appliedKey(apiVersion, kind, name string) string {
+	return apiVersion + "/" + kind + "/" + name
+}
+
+func (a *appliedObjects) find(d descriptor) *appv1alpha1.AppliedObject {
+	for i, object := range a.instance.Status.Applied {
+		if object.APIVersion == d.apiVersion && object.Kind == d.kind && object.Name == d.name {
+			return &a.instance.Status.Applied[i]
+		}
+	}
+	return nil
+}
+
+// unchanged reports whether the object described by d was last applied from a descriptor with hash desiredHash and
+// has not been modified since. Secrets are always compared, their hash leaves out their data.
+func (a *appliedObjects) unchanged(d descriptor, desiredHash string) bool {
+	applied := a.find(d)
+	if d.kind == "Secret" || applied == nil || applied.Version == "" {
+		return false
+	}
+	live, ok := a.liveObjects(d)[d.name]
+	return ok && live.desiredHash == desiredHash && live.version == applied.Version
+}
+
+// liveObjects returns the objects of the kind of d labelled as applied by the Configuration
+func (a *appliedObjects) liveObjects(d descriptor) map[string]liveObject {
+	gvk := schema.FromAPIVersionAndKind(d.apiVersion, d.kind)
+	if objects, ok := a.live[gvk]; ok {
+		return objects
+	}
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(gvk)
+	opts := (&client.ListOptions{}).InNamespace(a.instance.Namespace).MatchingLabels(map[string]string{CONFIGURATION_LABEL: a.instance.Name})
+	var objects map[string]liveObject
+	if err := a.r.client.List(context.TODO(), opts, list); err == nil {
+		objects = map[string]liveObject{}
+		for i := range list.Items {
+			objects[list.Items[i].GetName()] = liveObject{
+				desiredHash: list.Items[i].GetAnnotations()[DESIRED_HASH_ANNOTATION],
+				version:     objectVersion(&list.Items[i]),
+			}
+		}
+	}
+	a.live[gvk] = objects
+	return objects
+}
+
+// record remembers the version of the object described by d once applied
+func (a *appliedObjects) record(d descriptor) {
+	// Read the object from the API server, the cache may not have seen the update yet
+	live := &unstructured.Unstructured{}
+	live.SetAPIVersion(d.apiVersion)
+	live.SetKind(d.kind)
+	version := ""
+	if err := a.r.client.Get(context.TODO(), types.NamespacedName{Name: d.name, Namespace: a.instance.Namespace}, live); err == nil {
+		version = objectVersion(live)
+	}
+
+	if applied := a.find(d); applied != nil {
+		applied.Version = version
+		return
+	}
+	a.instance.Status.Applied = append(a.instance.Status.Applied, appv1alpha1.AppliedObject{
+		APIVersion: d.apiVersion, Kind: d.kind, Name: d.name, Version: version,
+	})
+}
+
+// getLiveObject reads the object described by d, typed when it is one of the kinds with a dedicated handler
+func getLiveObject(r *ReconcileConfiguration, namespace string, d descriptor) (runtime.Object, error) {
+	live := newObjectForKind(d.kind)
+	if live == nil {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion(d.apiVersion)
+		u.SetKind(d.kind)
+		live = u
+	}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: d.name, Namespace: namespace}, live); err != nil {
+		return nil, err
+	}
+	return live, nil
+}
+
+// objectVersion returns the generation of obj, or its resourceVersion for kinds without a generation
+func objectVersion(obj runtime.Object) string {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return ""
+	}
+	if generation := accessor.GetGeneration(); generation != 0 {
+		return "generation:" + strconv.FormatInt(generation, 10)
+	}
+	return "resourceVersion:" + accessor.GetResourceVersion()
+}
+
+// desiredHash returns the hash of the canonical JSON of the object in buffer. The data of Secrets is left out, the
+// hash is stored in the object and in the Status of the Configuration where the plaintext must not be guessed from.
+func desiredHash(buffer []byte) (string, error) {
+	jsonBytes, err := k8s_yaml.ToJSON(buffer)
+	if err != nil {
+		return "", err
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal(jsonBytes, &obj); err != nil {
+		return "", err
+	}
+	if obj["kind"] == "Secret" {
+		delete(obj, "data")
+		delete(obj, "stringData")
+	}
+	// Maps are marshalled with sorted keys
+	canonical, err := json.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	return hash(canonical), nil
+}
+
+// prepareBuffer returns the object in buffer as JSON to send to the API server: annotated with desiredHash, labelled
+// with the name of its Configuration and without the sync options annotation
+func prepareBuffer(buffer []byte, desiredHash string, configuration string) ([]byte, error) {
+	jsonBytes, err := k8s_yaml.ToJSON(buffer)
+	if err != nil {
+		return nil, err
+	}
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(jsonBytes); err != nil {
+		return nil, err
+	}
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[DESIRED_HASH_ANNOTATION] = desiredHash
+	delete(annotations, SYNC_OPTIONS_ANNOTATION)
+	obj.SetAnnotations(annotations)
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[CONFIGURATION_LABEL] = configuration
+	obj.SetLabels(labels)
+	return obj.MarshalJSON()
+}
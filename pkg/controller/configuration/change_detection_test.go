@@ -0,0 +1,161 @@
+package configuration
+
+import (
+	"context"
+	"testing"
+
+	appv1alpha1 "github.com/cvicens/rocketeer-operator/pkg/apis/app/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const configMapDescriptor = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+data:
+  color: blue
+`
+
+const secretDescriptor = `apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+stringData:
+  password: hunter2
+`
+
+func mustDescriptor(t *testing.T, buffer string) descriptor {
+	d, err := newDescriptor(buffer, []byte(buffer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func mustHash(t *testing.T, buffer string) string {
+	h, err := desiredHash([]byte(buffer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestDesiredHash(t *testing.T) {
+	reordered := `{"kind": "ConfigMap", "data": {"color": "blue"}, "metadata": {"name": "web"}, "apiVersion": "v1"}`
+	if mustHash(t, configMapDescriptor) != mustHash(t, reordered) {
+		t.Error("hash depends on the key order")
+	}
+	if mustHash(t, configMapDescriptor) == mustHash(t, configMapDescriptor+"  size: large\n") {
+		t.Error("hash ignores the data of ConfigMaps")
+	}
+
+	changedSecret := secretDescriptor + "data:\n  token: czNjcjN0\n"
+	if mustHash(t, secretDescriptor) != mustHash(t, changedSecret) {
+		t.Error("hash of a Secret depends on its data")
+	}
+	if mustHash(t, secretDescriptor) == mustHash(t, secretDescriptor+"type: kubernetes.io/basic-auth\n") {
+		t.Error("hash of a Secret ignores its type")
+	}
+}
+
+// apply creates or updates the object of d in c the way the handlers do
+func apply(t *testing.T, c *memoryClient, d descriptor, configuration string) {
+	buffer, err := prepareBuffer(d.buffer, mustHash(t, string(d.buffer)), configuration)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(buffer); err != nil {
+		t.Fatal(err)
+	}
+	obj.SetNamespace("apps")
+	live := obj.DeepCopy()
+	if err := c.Get(context.TODO(), client.ObjectKey{Namespace: obj.GetNamespace(), Name: obj.GetName()}, live); err == nil {
+		obj.SetResourceVersion(live.GetResourceVersion())
+		err = c.Update(context.TODO(), obj)
+	} else {
+		err = c.Create(context.TODO(), obj)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAppliedObjects(t *testing.T) {
+	c := newMemoryClient()
+	r := &ReconcileConfiguration{client: c}
+	instance := &appv1alpha1.Configuration{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "apps"}}
+	configMap := mustDescriptor(t, configMapDescriptor)
+	secret := mustDescriptor(t, secretDescriptor)
+	descriptors := []descriptor{configMap, secret}
+	changed := []descriptor{mustDescriptor(t, configMapDescriptor+"  size: large\n"), secret}
+
+	// sync applies the descriptors that changed and returns those found unchanged
+	sync := func(descriptors []descriptor) []string {
+		applied := newAppliedObjects(r, instance, descriptors)
+		unchanged := []string{}
+		for _, d := range descriptors {
+			if applied.unchanged(d, mustHash(t, string(d.buffer))) {
+				unchanged = append(unchanged, d.String())
+				continue
+			}
+			apply(t, c, d, instance.Name)
+			applied.record(d)
+		}
+		return unchanged
+	}
+
+	tests := []struct {
+		name        string
+		before      func()
+		descriptors []descriptor
+		unchanged   []string
+	}{
+		{name: "first sync", descriptors: descriptors},
+		{name: "second sync", descriptors: descriptors, unchanged: []string{"ConfigMap/web"}},
+		{
+			name: "live object modified",
+			before: func() {
+				live := &unstructured.Unstructured{}
+				live.SetAPIVersion("v1")
+				live.SetKind("ConfigMap")
+				c.Get(context.TODO(), client.ObjectKey{Namespace: "apps", Name: "web"}, live)
+				unstructured.SetNestedField(live.Object, "red", "data", "color")
+				c.Update(context.TODO(), live)
+			},
+			descriptors: descriptors,
+		},
+		{name: "restored by the sync", descriptors: descriptors, unchanged: []string{"ConfigMap/web"}},
+		{name: "descriptor changed", descriptors: changed},
+		{name: "changed descriptor applied", descriptors: changed, unchanged: []string{"ConfigMap/web"}},
+		{
+			name: "hash annotation removed",
+			before: func() {
+				live := &unstructured.Unstructured{}
+				live.SetAPIVersion("v1")
+				live.SetKind("ConfigMap")
+				c.Get(context.TODO(), client.ObjectKey{Namespace: "apps", Name: "web"}, live)
+				live.SetAnnotations(nil)
+				// Keeps the recorded version, only the annotation tells the object apart
+				c.objects[memoryKey(live.GroupVersionKind(), "apps", "web")] = live
+			},
+			descriptors: changed,
+		},
+		{name: "descriptor removed", descriptors: []descriptor{secret}},
+	}
+
+	for _, test := range tests {
+		if test.before != nil {
+			test.before()
+		}
+		unchanged := sync(test.descriptors)
+		if len(unchanged) != len(test.unchanged) || (len(unchanged) > 0 && unchanged[0] != test.unchanged[0]) {
+			t.Errorf("%s: expected %v unchanged, got %v", test.name, test.unchanged, unchanged)
+		}
+		if len(instance.Status.Applied) != len(test.descriptors) {
+			t.Errorf("%s: unexpected applied objects %+v", test.name, instance.Status.Applied)
+		}
+	}
+}
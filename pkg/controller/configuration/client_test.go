@@ -0,0 +1,185 @@
+package configuration
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	appv1alpha1 "github.com/cvicens/rocketeer-operator/pkg/apis/app/v1alpha1"
+	oappsv1 "github.com/openshift/api/apps/v1"
+	buildv1 "github.com/openshift/api/build/v1"
+	imagev1 "github.com/openshift/api/image/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// memoryClient is a client.Client keeping unstructured objects in memory, the controller-runtime in use has no fake
+// client. Typed objects are converted, their kind is looked up in the scheme when TypeMeta is not set. Creates set the
+// creationTimestamp and generate names, updates bump the resourceVersion, and the generation when the spec changes.
+type memoryClient struct {
+	objects map[string]*unstructured.Unstructured
+	version int
+}
+
+var _ client.Client = &memoryClient{}
+
+func newMemoryClient(objects ...runtime.Object) *memoryClient {
+	c := &memoryClient{objects: map[string]*unstructured.Unstructured{}}
+	for _, obj := range objects {
+		if err := c.Create(context.TODO(), obj); err != nil {
+			panic(err)
+		}
+	}
+	return c
+}
+
+var memoryScheme = runtime.NewScheme()
+
+func init() {
+	scheme.AddToScheme(memoryScheme)
+	appv1alpha1.SchemeBuilder.AddToScheme(memoryScheme)
+	oappsv1.AddToScheme(memoryScheme)
+	buildv1.AddToScheme(memoryScheme)
+	imagev1.AddToScheme(memoryScheme)
+	routev1.AddToScheme(memoryScheme)
+}
+
+func objectKind(obj runtime.Object) schema.GroupVersionKind {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	if gvk.Empty() {
+		gvk, _ = apiutil.GVKForObject(obj, memoryScheme)
+	}
+	return gvk
+}
+
+func toUnstructured(obj runtime.Object) (*unstructured.Unstructured, error) {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		return u.DeepCopy(), nil
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{Object: content}
+	u.SetGroupVersionKind(objectKind(obj))
+	return u, nil
+}
+
+func fromUnstructured(u *unstructured.Unstructured, obj runtime.Object) error {
+	if out, ok := obj.(*unstructured.Unstructured); ok {
+		out.Object = u.DeepCopy().Object
+		return nil
+	}
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(u.DeepCopy().Object, obj)
+}
+
+func memoryKey(gvk schema.GroupVersionKind, namespace, name string) string {
+	return gvk.String() + "/" + namespace + "/" + name
+}
+
+func (c *memoryClient) nextVersion() string {
+	c.version++
+	return strconv.Itoa(c.version)
+}
+
+func (c *memoryClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	gvk := objectKind(obj)
+	stored, ok := c.objects[memoryKey(gvk, key.Namespace, key.Name)]
+	if !ok {
+		return errors.NewNotFound(schema.GroupResource{Group: gvk.Group, Resource: gvk.Kind}, key.Name)
+	}
+	return fromUnstructured(stored, obj)
+}
+
+func (c *memoryClient) List(ctx context.Context, opts *client.ListOptions, list runtime.Object) error {
+	u, ok := list.(*unstructured.UnstructuredList)
+	if !ok {
+		u = &unstructured.UnstructuredList{}
+		u.SetGroupVersionKind(objectKind(list))
+		if err := c.List(ctx, opts, u); err != nil {
+			return err
+		}
+		items := []interface{}{}
+		for _, item := range u.Items {
+			items = append(items, item.Object)
+		}
+		return runtime.DefaultUnstructuredConverter.FromUnstructured(map[string]interface{}{"items": items}, list)
+	}
+	gvk := u.GroupVersionKind()
+	gvk.Kind = strings.TrimSuffix(gvk.Kind, "List")
+	u.Items = nil
+	for _, stored := range c.objects {
+		if stored.GroupVersionKind() != gvk || (opts.Namespace != "" && stored.GetNamespace() != opts.Namespace) {
+			continue
+		}
+		if opts.LabelSelector != nil && !opts.LabelSelector.Matches(labels.Set(stored.GetLabels())) {
+			continue
+		}
+		u.Items = append(u.Items, *stored.DeepCopy())
+	}
+	return nil
+}
+
+func (c *memoryClient) Create(ctx context.Context, obj runtime.Object) error {
+	u, err := toUnstructured(obj)
+	if err != nil {
+		return err
+	}
+	version := c.nextVersion()
+	if u.GetName() == "" && u.GetGenerateName() != "" {
+		u.SetName(fmt.Sprintf("%s%05d", u.GetGenerateName(), c.version))
+	}
+	key := memoryKey(u.GroupVersionKind(), u.GetNamespace(), u.GetName())
+	if _, ok := c.objects[key]; ok {
+		return errors.NewAlreadyExists(schema.GroupResource{Resource: u.GetKind()}, u.GetName())
+	}
+	u.SetResourceVersion(version)
+	u.SetCreationTimestamp(metav1.Now())
+	if _, ok := u.Object["spec"]; ok {
+		u.SetGeneration(1)
+	}
+	c.objects[key] = u
+	return fromUnstructured(u, obj)
+}
+
+func (c *memoryClient) Update(ctx context.Context, obj runtime.Object) error {
+	u, err := toUnstructured(obj)
+	if err != nil {
+		return err
+	}
+	key := memoryKey(u.GroupVersionKind(), u.GetNamespace(), u.GetName())
+	stored, ok := c.objects[key]
+	if !ok {
+		return errors.NewNotFound(schema.GroupResource{Resource: u.GetKind()}, u.GetName())
+	}
+	u.SetGeneration(stored.GetGeneration())
+	if !reflect.DeepEqual(stored.Object["spec"], u.Object["spec"]) {
+		u.SetGeneration(stored.GetGeneration() + 1)
+	}
+	u.SetResourceVersion(c.nextVersion())
+	c.objects[key] = u
+	return fromUnstructured(u, obj)
+}
+
+func (c *memoryClient) Delete(ctx context.Context, obj runtime.Object, opts ...client.DeleteOptionFunc) error {
+	u, err := toUnstructured(obj)
+	if err != nil {
+		return err
+	}
+	delete(c.objects, memoryKey(u.GroupVersionKind(), u.GetNamespace(), u.GetName()))
+	return nil
+}
+
+func (c *memoryClient) Status() client.StatusWriter {
+	return c
+}
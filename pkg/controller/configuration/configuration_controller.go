@@ -4,11 +4,11 @@ import (
 	"bytes"
 	"context"
 	"crypto/md5"
-	"encoding/gob"
 	"encoding/hex"
 	"fmt"
-	"io/ioutil"
 	"os"
+	"strings"
+	"time"
 
 	"k8s.io/apimachinery/pkg/util/json"
 
@@ -16,11 +16,12 @@ import (
 
 	appv1alpha1 "github.com/cvicens/rocketeer-operator/pkg/apis/app/v1alpha1"
 	"github.com/go-logr/logr"
-	yaml "gopkg.in/yaml.v2"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 
@@ -33,6 +34,8 @@ import (
 
 	"k8s.io/apimachinery/pkg/util/strategicpatch"
 	k8s_yaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/record"
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
@@ -47,14 +50,20 @@ import (
 	plumbing "gopkg.in/src-d/go-git.v4/plumbing"
 
 	objectmatch "github.com/cvicens/rocketeer-operator/pkg/objectmatcher"
+	"github.com/cvicens/rocketeer-operator/pkg/redact"
+	"github.com/cvicens/rocketeer-operator/pkg/tracing"
+	"go.opencensus.io/trace"
 )
 
 var log = logf.Log.WithName("controller_configuration")
-var objectMatcher := objectmatch.New(log)
+var objectMatcher = objectmatch.New(log)
 
 const GIT_LOCAL_FOLDER = "./tmp"
 const DEFAULT_DESCRIPTORS_FOLDER = "k8s"
 
+// Longest diff included in the event recorded for an updated object
+const EVENT_DIFF_MAX_LENGTH = 1024
+
 /**
 * USER ACTION REQUIRED: This is a scaffold file intended for the user to modify with their own Controller
 * business logic.  Delete these comments after modifying this file.*
@@ -63,17 +72,35 @@ const DEFAULT_DESCRIPTORS_FOLDER = "k8s"
 // Add creates a new Configuration Controller and adds it to the Manager. The Manager will set fields on the Controller
 // and Start it when the Manager is Started.
 func Add(mgr manager.Manager) error {
-	return add(mgr, newReconciler(mgr))
+	r, err := newReconciler(mgr)
+	if err != nil {
+		return err
+	}
+	return add(mgr, r)
 }
 
 // newReconciler returns a new reconcile.Reconciler
-func newReconciler(mgr manager.Manager) reconcile.Reconciler {
+func newReconciler(mgr manager.Manager) (reconcile.Reconciler, error) {
 	scheme := mgr.GetScheme()
 	oappsv1.AddToScheme(scheme)
 	imagev1.AddToScheme(scheme)
 	routev1.AddToScheme(scheme)
 	buildv1.AddToScheme(scheme)
-	return &ReconcileConfiguration{client: mgr.GetClient(), scheme: scheme}
+
+	// Desired objects are compared to live ones with the API server defaults filled in
+	defaults := runtime.NewScheme()
+	objectmatch.AddDefaultingFuncs(defaults)
+	objectMatcher = objectmatch.NewWithDefaulter(log, defaults)
+
+	// Reads the sealing keys from the API server, the cache would watch every Secret of the operator namespace
+	reader, err := client.New(mgr.GetConfig(), client.Options{Scheme: scheme, Mapper: mgr.GetRESTMapper()})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReconcileConfiguration{client: mgr.GetClient(), reader: reader, scheme: scheme,
+		recorder: mgr.GetRecorder("configuration-controller"), dynamic: dynamic.NewForConfigOrDie(mgr.GetConfig()),
+		mapper: mgr.GetRESTMapper()}, nil
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
@@ -111,7 +138,13 @@ type ReconcileConfiguration struct {
 	// This client, initialized using mgr.Client() above, is a split client
 	// that reads objects from the cache and writes to the apiserver
 	client client.Client
-	scheme *runtime.Scheme
+	// reader reads from the apiserver the objects that must not be cached
+	reader   client.Reader
+	scheme   *runtime.Scheme
+	recorder record.EventRecorder
+	// dynamic and mapper run the server-side dry runs, which the client does not support
+	dynamic dynamic.Interface
+	mapper  meta.RESTMapper
 }
 
 // Reconcile reads that state of the cluster for a Configuration object and makes changes based on the state read
@@ -124,6 +157,9 @@ type ReconcileConfiguration struct {
 func (r *ReconcileConfiguration) Reconcile(request reconcile.Request) (reconcile.Result, error) {
 	reqLogger := log.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
 	reqLogger.Info("Reconciling Configuration")
+	ctx, span := trace.StartSpan(context.Background(), "Reconcile")
+	span.AddAttributes(trace.StringAttribute("namespace", request.Namespace), trace.StringAttribute("name", request.Name))
+	defer span.End()
 
 	// Fetch the Configuration instance
 	instance := &appv1alpha1.Configuration{}
@@ -133,6 +169,7 @@ func (r *ReconcileConfiguration) Reconcile(request reconcile.Request) (reconcile
 			// Request object not found, could have been deleted after reconcile request.
 			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
 			// Return and don't requeue
+			deleteMetrics(request.NamespacedName)
 			return reconcile.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
@@ -147,12 +184,93 @@ func (r *ReconcileConfiguration) Reconcile(request reconcile.Request) (reconcile
 		return reconcile.Result{}, err
 	}
 
-	if _, err := cloneRepository(instance.Spec.GitUrl, instance.Spec.GitRef); err != nil {
+	repo, err := cloneRepository(ctx, instance.Spec.GitUrl, instance.Spec.GitRef, request.NamespacedName)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	revision := syncRevision(reqLogger, instance, head.Hash().String())
+	if revision != head.Hash().String() {
+		restore, err := checkoutRevision(repo, revision, instance.Spec.GitRef)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		defer func() {
+			if err := restore(); err != nil {
+				reqLogger.Info("Checkout " + instance.Spec.GitRef + " err: " + err.Error())
+			}
+		}()
+	}
+
+	// Restore a snapshot if requested, before syncing as the restored object is left out of syncs
+	snapshots := newSnapshotStore(r, instance)
+	if err := restoreSnapshot(r, reqLogger, instance, snapshots); err != nil {
+		reqLogger.Info("Restore snapshot err: " + err.Error())
 		return reconcile.Result{}, err
 	}
 
 	// Apply all descriptors
-	applyDescriptorsInFolder(r, request, descriptorsFolderPath)
+	run := newSyncRun()
+	previousRevision := instance.Status.Revision
+	rules := getHealthRules(r, reqLogger, instance.Spec)
+	ignore := getIgnoreRules(r, reqLogger, request.Namespace, instance.Spec)
+	descriptors, overlays, err := applyDescriptorsInFolder(ctx, r, request, descriptorsFolderPath, instance, rules, ignore, run, snapshots)
+	if err != nil {
+		// A wave waiting for a CustomResourceDefinition failed the sync once it timed out, keep it in the status
+		if wave := instance.Status.CurrentWave; wave != nil && wave.TimedOut {
+			instance.Status.Health = appv1alpha1.HealthDegraded
+			setHealthConditions(&instance.Status, appv1alpha1.HealthDegraded, err.Error())
+			if err := updateStatus(ctx, r, instance); err != nil {
+				reqLogger.Info("Update Configuration status err: " + err.Error())
+			}
+		}
+		recordSyncMetrics(instance, run, previousRevision, revision, err)
+		recordSyncRun(r, reqLogger, instance, repo, run, previousRevision, revision, err)
+		return reconcile.Result{}, err
+	}
+
+	// Assess the health of what has been applied
+	_, healthSpan := trace.StartSpan(ctx, "health")
+	resources, health := assessHealth(r, request.Namespace, descriptors, rules)
+	healthSpan.End()
+	message := healthMessage(resources, health)
+	if wave := instance.Status.CurrentWave; wave != nil {
+		if wave.TimedOut {
+			health = appv1alpha1.HealthDegraded
+			message = fmt.Sprintf("sync wave %d timed out waiting for %s", wave.Wave, wave.BlockedBy)
+		} else {
+			health = appv1alpha1.HealthProgressing
+			message = fmt.Sprintf("sync wave %d waiting for %s", wave.Wave, wave.BlockedBy)
+		}
+	}
+	result := reconcile.Result{}
+	if health == appv1alpha1.HealthProgressing {
+		result.RequeueAfter = HEALTH_REQUEUE_INTERVAL
+	}
+	if wave := instance.Status.CurrentWave; wave != nil {
+		result.RequeueAfter = waveRequeueInterval(wave)
+	}
+
+	recordRevision(instance, revision, health)
+	if revision == head.Hash().String() {
+		if healthyRevision, ok := rollbackRevision(instance, resources); ok {
+			rollBack(r, reqLogger, instance, revision, healthyRevision)
+			result.Requeue = true
+		}
+	}
+
+	instance.Status.Overlays = overlays
+	instance.Status.Resources = resources
+	instance.Status.Health = health
+	setHealthConditions(&instance.Status, health, message)
+	if err := updateStatus(ctx, r, instance); err != nil {
+		reqLogger.Info("Update Configuration status err: " + err.Error())
+	}
+	recordSyncMetrics(instance, run, previousRevision, revision, nil)
+	recordSyncRun(r, reqLogger, instance, repo, run, previousRevision, revision, nil)
 
 	// Define a new Pod object
 	pod := newPodForCR(instance)
@@ -172,15 +290,15 @@ func (r *ReconcileConfiguration) Reconcile(request reconcile.Request) (reconcile
 			return reconcile.Result{}, err
 		}
 
-		// Pod created successfully - don't requeue
-		return reconcile.Result{}, nil
+		// Pod created successfully
+		return result, nil
 	} else if err != nil {
 		return reconcile.Result{}, err
 	}
 
-	// Pod already exists - don't requeue
+	// Pod already exists
 	reqLogger.Info("Skip reconcile: Pod already exists", "Pod.Namespace", found.Namespace, "Pod.Name", found.Name)
-	return reconcile.Result{}, nil
+	return result, nil
 }
 
 // newPodForCR returns a busybox pod with the same name/namespace as the cr
@@ -218,10 +336,15 @@ func getAllConfigMaps(r *ReconcileConfiguration, request reconcile.Request, conf
 	return err
 }
 
-func cloneRepository(url string, ref string) (*git.Repository, error) {
+func cloneRepository(ctx context.Context, url string, ref string, configuration types.NamespacedName) (*git.Repository, error) {
+	start := time.Now()
 	if repo, err := git.PlainOpen(GIT_LOCAL_FOLDER); err == nil {
 		if w, err := repo.Worktree(); err == nil {
-			if err := w.Pull(&git.PullOptions{RemoteName: "origin"}); err == nil || err.Error() == "already up-to-date" {
+			_, span := trace.StartSpan(ctx, "git.pull")
+			err := w.Pull(&git.PullOptions{RemoteName: "origin"})
+			tracing.End(span, err)
+			recordGitMetrics(configuration, "fetch", start, err == nil)
+			if err == nil || err.Error() == "already up-to-date" {
 				return repo, nil
 			} else {
 				os.RemoveAll(GIT_LOCAL_FOLDER)
@@ -235,62 +358,214 @@ func cloneRepository(url string, ref string) (*git.Repository, error) {
 		// Delete just in case
 		os.RemoveAll(GIT_LOCAL_FOLDER)
 		// Clone
+		_, span := trace.StartSpan(ctx, "git.clone")
 		repo, err := git.PlainClone(GIT_LOCAL_FOLDER, false, &git.CloneOptions{
 			URL:               url,
 			ReferenceName:     plumbing.ReferenceName("refs/heads/" + ref),
 			RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
 		})
+		tracing.End(span, err)
+		recordGitMetrics(configuration, "clone", start, err == nil)
 		return repo, err
 	}
 }
 
-func applyDescriptorsInFolder(r *ReconcileConfiguration, request reconcile.Request, folder string) error {
+// applyDescriptorsInFolder applies the descriptors in folder wave by wave. When there is more than one sync wave the
+// next wave is only applied once every object in the current one is healthy, otherwise the wave being waited on is
+// recorded in instance.Status.CurrentWave. It returns the descriptors applied so far, what was done to each object is
+// recorded in run.
+func applyDescriptorsInFolder(ctx context.Context, r *ReconcileConfiguration, request reconcile.Request, folder string, instance *appv1alpha1.Configuration, rules healthRules, ignore ignoreRules, run *syncRun, snapshots *snapshotStore) ([]descriptor, []appv1alpha1.OverlayStatus, error) {
 	reqLogger := log.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
 
-	if files, err := ioutil.ReadDir(folder); err == nil {
-		for _, f := range files {
-			reqLogger.Info("===================== Current file: " + f.Name() + " =====================")
-			if b, err := ioutil.ReadFile(folder + f.Name()); err == nil {
-				typeMeta := &metav1.TypeMeta{}
-				if err := yaml.Unmarshal([]byte(b), &typeMeta); err == nil {
-					objectMeta := &metav1.ObjectMeta{}
-					if err := yaml.Unmarshal([]byte(b), &objectMeta); err == nil {
-						objectMeta.Namespace = request.Namespace
-						switch kind := typeMeta.GetObjectKind().GroupVersionKind().Kind; kind {
-						case "ConfigMap":
-							handleConfigMap(r, reqLogger, request.Namespace, b)
-						case "Secret":
-							handleSecret(r, reqLogger, request.Namespace, b)
-						case "Deployment":
-							handleDeployment(r, reqLogger, request.Namespace, b)
-						case "DeploymentConfig":
-							handleDeploymentConfig(r, reqLogger, request.Namespace, b)
-						case "ImageStream":
-							handleImageStream(r, reqLogger, request.Namespace, b)
-						case "BuildConfig":
-							handleBuildConfig(r, reqLogger, request.Namespace, b)
-						case "Route":
-							handleRoute(r, reqLogger, request.Namespace, b)
-						case "Service":
-							handleService(r, reqLogger, request.Namespace, b)
-						default:
-							reqLogger.Info("===== isOther =====" + kind)
-						}
-					} else {
-						reqLogger.Info("Unmarshall ObjectMeta error: " + err.Error())
-					}
-				} else {
-					reqLogger.Info("Unmarshall TypeMeta error: " + err.Error())
-				}
+	_, span := trace.StartSpan(ctx, "render")
+	descriptors, overlays, err := loadDescriptors(reqLogger, folder, instance.Spec, newDecrypter(r, instance))
+	tracing.End(span, err)
+	if err != nil {
+		reqLogger.Info("Load descriptors error: " + err.Error())
+		return nil, nil, err
+	}
 
-			} else {
-				reqLogger.Info("ReadFile error: " + err.Error())
+	sortDescriptors(descriptors)
+	lastApplied := newAppliedObjects(r, instance, descriptors)
+
+	if !instance.Spec.BestEffort {
+		_, span := trace.StartSpan(ctx, "dryRun")
+		err := dryRunDescriptors(r, reqLogger, instance.Namespace, descriptors, ignore, lastApplied)
+		tracing.End(span, err)
+		if err != nil {
+			reqLogger.Info("Dry run failed: " + err.Error())
+			setCondition(&instance.Status, appv1alpha1.ConfigurationDryRunFailed, corev1.ConditionTrue, "DryRunFailed", err.Error())
+			if err := updateStatus(ctx, r, instance); err != nil {
+				reqLogger.Info("Update Configuration status err: " + err.Error())
 			}
+			r.recorder.Event(instance, corev1.EventTypeWarning, "DryRunFailed", err.Error())
+			return nil, overlays, err
+		}
+		setCondition(&instance.Status, appv1alpha1.ConfigurationDryRunFailed, corev1.ConditionFalse, "DryRunSucceeded", "")
+	}
+
+	waves := splitWaves(descriptors)
+	applied := []descriptor{}
+	instance.Status.Changes = nil
+	for i, wave := range waves {
+		processed, err := applyDescriptors(ctx, r, reqLogger, instance, wave, ignore, run, snapshots, lastApplied)
+		applied = append(applied, wave[:processed]...)
+		if crdErr, ok := err.(*crdNotEstablishedError); ok {
+			if err := waitForCRD(reqLogger, instance, wave[0].wave, crdErr.name); err != nil {
+				return applied, overlays, err
+			}
+			break
+		}
+		if err != nil {
+			return applied, overlays, err
+		}
+		if i == len(waves)-1 {
+			instance.Status.CurrentWave = nil
+		} else if !waveReady(r, reqLogger, instance, wave, rules) {
+			break
 		}
-	} else {
-		reqLogger.Info("ReadDir error: " + err.Error())
 	}
 
+	return applied, overlays, nil
+}
+
+// applyDescriptors creates or updates the objects in descriptors, the objects updated are recorded in
+// instance.Status.Changes and as events on instance, and what was done to each object in run. It returns how many
+// descriptors were processed, all of them unless it stopped at a CustomResourceDefinition not established yet.
+func applyDescriptors(ctx context.Context, r *ReconcileConfiguration, logger logr.Logger, instance *appv1alpha1.Configuration, descriptors []descriptor, ignore ignoreRules, run *syncRun, snapshots *snapshotStore, lastApplied *appliedObjects) (int, error) {
+	namespace := instance.Namespace
+	// The objects after CustomResourceDefinitions are only applied once these are established
+	crds := []string{}
+	for i, d := range descriptors {
+		if d.kind != "CustomResourceDefinition" && len(crds) > 0 {
+			if err := crdsEstablished(r, crds); err != nil {
+				return i, err
+			}
+			crds = nil
+		}
+		if d.kind == "CustomResourceDefinition" {
+			crds = append(crds, d.name)
+		}
+		logger.Info("===================== Current file: " + d.file + " =====================")
+		desired, hashErr := desiredHash(d.buffer)
+		if restored := restoredObject(instance, d); restored != nil && hashErr == nil {
+			if restored.DesiredHash == "" {
+				restored.DesiredHash = desired
+			}
+			if restored.DesiredHash == desired {
+				logger.Info("------------> " + d.String() + " restored from snapshot " + restored.Snapshot + "!")
+				run.record(d, appv1alpha1.SyncActionSkipped, nil)
+				continue
+			}
+			instance.Status.Restored = removeRestored(instance.Status.Restored, d.kind, d.name)
+		}
+		if hashErr == nil {
+			if lastApplied.unchanged(d, desired) {
+				logger.Info("------------> " + d.String() + " unchanged since last sync!")
+				run.record(d, appv1alpha1.SyncActionUnchanged, nil)
+				continue
+			}
+			if buffer, err := prepareBuffer(d.buffer, desired, instance.Name); err == nil {
+				d.buffer = buffer
+			}
+		}
+		if d.options.createOnly && exists(r, namespace, d) {
+			logger.Info("------------> " + d.String() + " exists and is CreateOnly!")
+			run.record(d, appv1alpha1.SyncActionSkipped, nil)
+			continue
+		}
+		objectCtx, span := trace.StartSpan(ctx, "apply")
+		span.AddAttributes(trace.StringAttribute("kind", d.kind), trace.StringAttribute("name", d.name))
+		var result *objectmatch.MatchResult
+		var err error
+		switch kind := d.kind; kind {
+		case "ConfigMap":
+			result, err = handleConfigMap(objectCtx, r, logger, namespace, d.buffer, ignore, snapshots)
+		case "Secret":
+			result, err = handleSecret(objectCtx, r, logger, namespace, d.buffer, ignore, snapshots)
+		case "Deployment":
+			result, err = handleDeployment(objectCtx, r, logger, namespace, d.buffer, ignore, snapshots)
+		case "DeploymentConfig":
+			result, err = handleDeploymentConfig(objectCtx, r, logger, namespace, d.buffer, ignore, snapshots)
+		case "ImageStream":
+			result, err = handleImageStream(objectCtx, r, logger, namespace, d.buffer, ignore, snapshots)
+		case "BuildConfig":
+			result, err = handleBuildConfig(objectCtx, r, logger, namespace, d.buffer, ignore, snapshots)
+		case "Route":
+			result, err = handleRoute(objectCtx, r, logger, namespace, d.buffer, ignore, snapshots)
+		case "Service":
+			result, err = handleService(objectCtx, r, logger, namespace, d.buffer, ignore, snapshots)
+		default:
+			result, err = handleUnstructured(objectCtx, r, logger, namespace, d.buffer, ignore, snapshots)
+		}
+		replaced := false
+		if err != nil && d.options.replace && errors.IsInvalid(err) {
+			if err = replaceObject(r, logger, namespace, d, snapshots); err != nil {
+				logger.Info("Replace " + d.String() + " err: " + err.Error())
+			}
+			replaced = err == nil
+		}
+		tracing.End(span, err)
+		switch {
+		case err != nil:
+			run.record(d, appv1alpha1.SyncActionFailed, err)
+		case replaced:
+			run.record(d, appv1alpha1.SyncActionReplaced, nil)
+		case result == nil:
+			run.record(d, appv1alpha1.SyncActionApplied, nil)
+		case result.Matched:
+			run.record(d, appv1alpha1.SyncActionUnchanged, nil)
+		default:
+			run.record(d, appv1alpha1.SyncActionUpdated, nil)
+		}
+		if err == nil && result != nil && !result.Matched {
+			recordChange(r, logger, instance, d, result)
+		}
+		if err == nil && hashErr == nil {
+			lastApplied.record(d)
+		}
+	}
+
+	return len(descriptors), crdsEstablished(r, crds)
+}
+
+// recordChange adds the object described by d to instance.Status.Changes and records an event with the fields that
+// changed. The diff is left out of the event for Secrets.
+func recordChange(r *ReconcileConfiguration, logger logr.Logger, instance *appv1alpha1.Configuration, d descriptor, result *objectmatch.MatchResult) {
+	instance.Status.Changes = append(instance.Status.Changes, appv1alpha1.ResourceChange{Kind: d.kind, Name: d.name, Fields: result.Paths})
+
+	message := fmt.Sprintf("Updated %s: %s", d.String(), strings.Join(result.Paths, ", "))
+	if d.kind != "Secret" {
+		diff := result.Diff
+		if len(diff) > EVENT_DIFF_MAX_LENGTH {
+			diff = diff[:EVENT_DIFF_MAX_LENGTH] + "\n..."
+		}
+		message += "\n" + diff
+		logger.Info("diff (live -> desired):\n" + result.Diff)
+	}
+	r.recorder.Event(instance, corev1.EventTypeNormal, "Updated", message)
+}
+
+// newObjectForKind returns an empty typed object for the kinds handled by applyDescriptorsInFolder, nil otherwise
+func newObjectForKind(kind string) runtime.Object {
+	switch kind {
+	case "ConfigMap":
+		return &v1.ConfigMap{}
+	case "Secret":
+		return &v1.Secret{}
+	case "Deployment":
+		return &appsv1.Deployment{}
+	case "DeploymentConfig":
+		return &oappsv1.DeploymentConfig{}
+	case "ImageStream":
+		return &imagev1.ImageStream{}
+	case "BuildConfig":
+		return &buildv1.BuildConfig{}
+	case "Route":
+		return &routev1.Route{}
+	case "Service":
+		return &corev1.Service{}
+	}
 	return nil
 }
 
@@ -307,30 +582,6 @@ func hash(buffer []byte) string {
 	return hex.EncodeToString(hasher.Sum(nil))
 }
 
-func hashMapOfBytes(m map[string]byte) (string, error) {
-	b := new(bytes.Buffer)
-	e := gob.NewEncoder(b)
-
-	err := e.Encode(m)
-	if err != nil {
-		return "", err
-	}
-
-	return hash(b.Bytes()), nil
-}
-
-func hashMapOfString(m map[string]string) (string, error) {
-	b := new(bytes.Buffer)
-	e := gob.NewEncoder(b)
-
-	err := e.Encode(m)
-	if err != nil {
-		return "", err
-	}
-
-	return hash(b.Bytes()), nil
-}
-
 func diff(original, modified runtime.Object) ([]byte, error) {
 	origBytes, err := json.Marshal(original)
 	if err != nil {
@@ -357,8 +608,12 @@ func calculateMergePatchBytes(original, modified runtime.Object, dataStruct inte
 		return nil, err
 	}
 
-	logger.Info(fmt.Sprintf("diff (-want +got):\n%s", patchBytes))
+	logger.Info(fmt.Sprintf("diff (-want +got):\n%s", redact.JSON(redact.Kind(original), patchBytes)))
+
+	return strategicMergePatchBytes(origBytes, patchBytes, dataStruct)
+}
 
+func strategicMergePatchBytes(origBytes, patchBytes []byte, dataStruct interface{}) ([]byte, error) {
 	obj, err := strategicpatch.StrategicMergePatch(origBytes, patchBytes, dataStruct)
 	if err != nil {
 		return nil, err
@@ -380,23 +635,27 @@ func calculateMergePatchObject(original, modified runtime.Object, dataStruct int
 	return nil
 }
 
-func handleConfigMap(r *ReconcileConfiguration, logger logr.Logger, namespace string, buffer []byte) error {
+func handleConfigMap(ctx context.Context, r *ReconcileConfiguration, logger logr.Logger, namespace string, buffer []byte, ignore ignoreRules, snapshots *snapshotStore) (*objectmatch.MatchResult, error) {
 	logger.Info("===== ConfigMap =====")
 	fromK8s := &v1.ConfigMap{}
 	fromFile := &v1.ConfigMap{}
 	fromFile.Namespace = namespace
 	dec := k8s_yaml.NewYAMLOrJSONDecoder(bytes.NewReader(buffer), 1000)
+	var result *objectmatch.MatchResult
 	var err error
 	if err = dec.Decode(&fromFile); err == nil {
-		if err = r.client.Get(context.TODO(), types.NamespacedName{Name: fromFile.Name, Namespace: fromFile.Namespace}, fromK8s); err == nil {
+		if err = getObject(ctx, r, types.NamespacedName{Name: fromFile.Name, Namespace: fromFile.Namespace}, fromK8s); err == nil {
 			fromFile.ObjectMeta.ResourceVersion = fromK8s.ObjectMeta.ResourceVersion
-			if matched, err := objectMatcher.Match(fromK8s, fromFile); err == nil {
-				if !matched {
+			ignore.apply(logger, fromK8s, fromFile)
+			preserveFields(r, logger, fromK8s, fromFile)
+			if result, err = diffObjects(ctx, fromK8s, fromFile); err == nil {
+				if !result.Matched {
 					mergedPatchObject := &v1.ConfigMap{}
 					patchError := calculateMergePatchObject(fromK8s, fromFile, mergedPatchObject)
 					if patchError == nil {
-						logger.Info("Updating with: " + mergedPatchObject.String())
-						if err = r.client.Update(context.TODO(), mergedPatchObject); err != nil {
+						logger.Info("Updating with: " + redact.Object(mergedPatchObject))
+						snapshots.save(logger, fromK8s)
+						if err = updateObject(ctx, r, mergedPatchObject); err != nil {
 							logger.Info("Update ConfigMap err: " + err.Error())
 						}
 					} else {
@@ -413,7 +672,7 @@ func handleConfigMap(r *ReconcileConfiguration, logger logr.Logger, namespace st
 
 			}*/
 		} else {
-			if err = r.client.Create(context.TODO(), fromFile); err != nil {
+			if err = createObject(ctx, r, fromFile); err != nil {
 				logger.Info("Create ConfigMap err: " + err.Error())
 			}
 		}
@@ -421,48 +680,59 @@ func handleConfigMap(r *ReconcileConfiguration, logger logr.Logger, namespace st
 		logger.Info("Unmarshal ConfigMap err: " + err.Error())
 	}
 
-	return err
+	return result, err
 }
 
 func checkIfUpdateConfigMap(fromFile, fromK8s *v1.ConfigMap) bool {
 	logger := log.WithValues("Struct", "ConfigMap")
 	type intersection struct {
-		Labels      map[string]string
-		Annotations map[string]string
-		Data        map[string]string
-		BinaryData  map[string][]byte
+		Labels      map[string]string `json:"labels"`
+		Annotations map[string]string `json:"annotations"`
+		Data        map[string]string `json:"data"`
+		BinaryData  map[string][]byte `json:"binaryData"`
 	}
 
 	src := &intersection{fromFile.Labels, fromFile.Annotations, fromFile.Data, fromFile.BinaryData}
 	des := &intersection{fromK8s.Labels, fromK8s.Annotations, fromK8s.Data, fromK8s.BinaryData}
 
-	if diff := cmp.Diff(src, des); diff != "" {
+	if diff := cmp.Diff(redact.Value("ConfigMap", src), redact.Value("ConfigMap", des)); diff != "" {
 		logger.Info(fmt.Sprintf("mismatch (-want +got):\n%s", diff))
 	}
 
 	return cmp.Equal(src, des, nil)
 }
 
-func handleSecret(r *ReconcileConfiguration, logger logr.Logger, namespace string, buffer []byte) error {
+func handleSecret(ctx context.Context, r *ReconcileConfiguration, logger logr.Logger, namespace string, buffer []byte, ignore ignoreRules, snapshots *snapshotStore) (*objectmatch.MatchResult, error) {
 	logger.Info("===== Secret =====")
 	fromK8s := &v1.Secret{}
 	fromFile := &v1.Secret{}
 	fromFile.Namespace = namespace
 	dec := k8s_yaml.NewYAMLOrJSONDecoder(bytes.NewReader(buffer), 1000)
+	var result *objectmatch.MatchResult
 	var err error
 	if err = dec.Decode(&fromFile); err == nil {
-		if err = r.client.Get(context.TODO(), types.NamespacedName{Name: fromFile.Name, Namespace: fromFile.Namespace}, fromK8s); err == nil {
+		if err = getObject(ctx, r, types.NamespacedName{Name: fromFile.Name, Namespace: fromFile.Namespace}, fromK8s); err == nil {
 			fromFile.ObjectMeta.ResourceVersion = fromK8s.ObjectMeta.ResourceVersion
-
-			mergedPatchObject := &v1.Secret{}
-			patchError := calculateMergePatchObject(fromK8s, fromFile, mergedPatchObject)
-			if patchError == nil {
-				logger.Info("Updating with: " + mergedPatchObject.String())
-				if err = r.client.Update(context.TODO(), mergedPatchObject); err != nil {
-					logger.Info("Update Secret err: " + err.Error())
+			ignore.apply(logger, fromK8s, fromFile)
+			preserveFields(r, logger, fromK8s, fromFile)
+			if result, err = diffObjects(ctx, fromK8s, fromFile); err == nil {
+				if !result.Matched {
+					mergedPatchObject := &v1.Secret{}
+					patchError := calculateMergePatchObject(fromK8s, fromFile, mergedPatchObject)
+					if patchError == nil {
+						logger.Info("Updating with: " + redact.Object(mergedPatchObject))
+						snapshots.save(logger, fromK8s)
+						if err = updateObject(ctx, r, mergedPatchObject); err != nil {
+							logger.Info("Update Secret err: " + err.Error())
+						}
+					} else {
+						logger.Info("=======> patchError: " + patchError.Error())
+					}
+				} else {
+					logger.Info("------------> Secret intact!")
 				}
 			} else {
-				logger.Info("=======> patchError: " + patchError.Error())
+				logger.Info("=======> MatchError: " + err.Error())
 			}
 
 			/*if checkIfUpdateSecret(fromFile, fromK8s) {
@@ -471,7 +741,7 @@ func handleSecret(r *ReconcileConfiguration, logger logr.Logger, namespace strin
 				}
 			}*/
 		} else {
-			if err = r.client.Create(context.TODO(), fromFile); err != nil {
+			if err = createObject(ctx, r, fromFile); err != nil {
 				logger.Info("Create Secret err: " + err.Error())
 			}
 		}
@@ -479,87 +749,110 @@ func handleSecret(r *ReconcileConfiguration, logger logr.Logger, namespace strin
 		logger.Info("Unmarshal Secret err: " + err.Error())
 	}
 
-	return err
+	return result, err
 }
 
 func checkIfUpdateSecret(fromFile *v1.Secret, fromK8s *v1.Secret) bool {
 	logger := log.WithValues("Struct", "Secret")
 	type intersection struct {
-		Labels      map[string]string
-		Annotations map[string]string
-		Data        map[string][]byte
-		StringData  map[string]string
-		Type        v1.SecretType
+		Labels      map[string]string `json:"labels"`
+		Annotations map[string]string `json:"annotations"`
+		Data        map[string][]byte `json:"data"`
+		StringData  map[string]string `json:"stringData"`
+		Type        v1.SecretType     `json:"type"`
 	}
 
 	src := &intersection{fromFile.Labels, fromFile.Annotations, fromFile.Data, fromFile.StringData, fromFile.Type}
 	des := &intersection{fromK8s.Labels, fromK8s.Annotations, fromK8s.Data, fromK8s.StringData, fromK8s.Type}
 
-	if diff := cmp.Diff(src, des); diff != "" {
+	if diff := cmp.Diff(redact.Value("Secret", src), redact.Value("Secret", des)); diff != "" {
 		logger.Info(fmt.Sprintf("mismatch (-want +got):\n%s", diff))
 	}
 
 	return cmp.Equal(src, des, nil)
 }
 
-func handleDeployment(r *ReconcileConfiguration, logger logr.Logger, namespace string, buffer []byte) error {
+func handleDeployment(ctx context.Context, r *ReconcileConfiguration, logger logr.Logger, namespace string, buffer []byte, ignore ignoreRules, snapshots *snapshotStore) (*objectmatch.MatchResult, error) {
 	logger.Info("===== Deployment =====")
 	fromK8s := &appsv1.Deployment{}
 	fromFile := &appsv1.Deployment{}
 	fromFile.Namespace = namespace
 	dec := k8s_yaml.NewYAMLOrJSONDecoder(bytes.NewReader(buffer), 1000)
+	var result *objectmatch.MatchResult
 	var err error
 	if err = dec.Decode(&fromFile); err == nil {
-		if err = r.client.Get(context.TODO(), types.NamespacedName{Name: fromFile.Name, Namespace: fromFile.Namespace}, fromK8s); err == nil {
+		if err = getObject(ctx, r, types.NamespacedName{Name: fromFile.Name, Namespace: fromFile.Namespace}, fromK8s); err == nil {
 			fromFile.ObjectMeta.ResourceVersion = fromK8s.ObjectMeta.ResourceVersion
+			ignore.apply(logger, fromK8s, fromFile)
+			preserveFields(r, logger, fromK8s, fromFile)
 
-			mergedPatchObject := &appsv1.Deployment{}
-			patchError := calculateMergePatchObject(fromK8s, fromFile, mergedPatchObject)
-			if patchError == nil {
-				logger.Info("Updating with: " + mergedPatchObject.String())
-				if err = r.client.Update(context.TODO(), mergedPatchObject); err != nil {
-					logger.Info("Update Deployment err: " + err.Error())
+			if result, err = diffObjects(ctx, fromK8s, fromFile); err == nil {
+				if !result.Matched {
+					mergedPatchObject := &appsv1.Deployment{}
+					patchError := calculateMergePatchObject(fromK8s, fromFile, mergedPatchObject)
+					if patchError == nil {
+						logger.Info("Updating with: " + redact.Object(mergedPatchObject))
+						snapshots.save(logger, fromK8s)
+						if err = updateObject(ctx, r, mergedPatchObject); err != nil {
+							logger.Info("Update Deployment err: " + err.Error())
+						}
+					} else {
+						logger.Info("=======> patchError: " + patchError.Error())
+					}
+				} else {
+					logger.Info("------------> Deployment intact!")
 				}
 			} else {
-				logger.Info("=======> patchError: " + patchError.Error())
+				logger.Info("=======> MatchError: " + err.Error())
 			}
 
 			/*if err = r.client.Update(context.TODO(), fromFile); err != nil {
 				logger.Info("Update Deployment err: " + err.Error())
 			}*/
 		} else {
-			if err = r.client.Create(context.TODO(), fromFile); err != nil {
+			if err = createObject(ctx, r, fromFile); err != nil {
 				logger.Info("Create Deployment err: " + err.Error())
 			}
 		}
 	} else {
-		logger.Info("Unmarshal Secret err: " + err.Error())
+		logger.Info("Unmarshal Deployment err: " + err.Error())
 	}
 
-	return err
+	return result, err
 }
 
-func handleDeploymentConfig(r *ReconcileConfiguration, logger logr.Logger, namespace string, buffer []byte) error {
+func handleDeploymentConfig(ctx context.Context, r *ReconcileConfiguration, logger logr.Logger, namespace string, buffer []byte, ignore ignoreRules, snapshots *snapshotStore) (*objectmatch.MatchResult, error) {
 	logger.Info("===== DeploymentConfig =====")
 	fromK8s := &oappsv1.DeploymentConfig{}
 	fromFile := &oappsv1.DeploymentConfig{}
 	fromFile.Namespace = namespace
 	dec := k8s_yaml.NewYAMLOrJSONDecoder(bytes.NewReader(buffer), 1000)
+	var result *objectmatch.MatchResult
 	var err error
 	if err = dec.Decode(&fromFile); err == nil {
-		if err = r.client.Get(context.TODO(), types.NamespacedName{Name: fromFile.Name, Namespace: fromFile.Namespace}, fromK8s); err == nil {
+		if err = getObject(ctx, r, types.NamespacedName{Name: fromFile.Name, Namespace: fromFile.Namespace}, fromK8s); err == nil {
 			fromFile.ObjectMeta.ResourceVersion = fromK8s.ObjectMeta.ResourceVersion
+			ignore.apply(logger, fromK8s, fromFile)
+			preserveFields(r, logger, fromK8s, fromFile)
 
-			mergedPatchObject := &oappsv1.DeploymentConfig{}
-			patchError := calculateMergePatchObject(fromK8s, fromFile, mergedPatchObject)
-			logger.Info("DC mergedPatchObject: " + mergedPatchObject.String())
-			if patchError == nil {
-				logger.Info("Updating with: " + mergedPatchObject.String())
-				if err = r.client.Update(context.TODO(), mergedPatchObject); err != nil {
-					logger.Info("Update DeploymentConfig err: " + err.Error())
+			if result, err = diffObjects(ctx, fromK8s, fromFile); err == nil {
+				if !result.Matched {
+					mergedPatchObject := &oappsv1.DeploymentConfig{}
+					patchError := calculateMergePatchObject(fromK8s, fromFile, mergedPatchObject)
+					if patchError == nil {
+						logger.Info("Updating with: " + redact.Object(mergedPatchObject))
+						snapshots.save(logger, fromK8s)
+						if err = updateObject(ctx, r, mergedPatchObject); err != nil {
+							logger.Info("Update DeploymentConfig err: " + err.Error())
+						}
+					} else {
+						logger.Info("=======> patchError: " + patchError.Error())
+					}
+				} else {
+					logger.Info("------------> DeploymentConfig intact!")
 				}
 			} else {
-				logger.Info("=======> patchError: " + patchError.Error())
+				logger.Info("=======> MatchError: " + err.Error())
 			}
 
 			/*if checkIfUpdateDeploymentConfig(fromFile, fromK8s) {
@@ -568,7 +861,7 @@ func handleDeploymentConfig(r *ReconcileConfiguration, logger logr.Logger, names
 				}
 			}*/
 		} else {
-			if err = r.client.Create(context.TODO(), fromFile); err != nil {
+			if err = createObject(ctx, r, fromFile); err != nil {
 				logger.Info("Create DeploymentConfig err: " + err.Error())
 			}
 		}
@@ -576,7 +869,7 @@ func handleDeploymentConfig(r *ReconcileConfiguration, logger logr.Logger, names
 		logger.Info("Unmarshal DeploymentConfig err: " + err.Error())
 	}
 
-	return err
+	return result, err
 }
 
 func checkIfUpdateDeploymentConfig(fromFile *oappsv1.DeploymentConfig, fromK8s *oappsv1.DeploymentConfig) bool {
@@ -590,41 +883,52 @@ func checkIfUpdateDeploymentConfig(fromFile *oappsv1.DeploymentConfig, fromK8s *
 	src := &intersection{fromFile.Labels, fromFile.Annotations, fromFile.Spec}
 	des := &intersection{fromK8s.Labels, fromK8s.Annotations, fromK8s.Spec}
 
-	if diff := cmp.Diff(src, des); diff != "" {
+	if diff := cmp.Diff(redact.Value("DeploymentConfig", src), redact.Value("DeploymentConfig", des)); diff != "" {
 		logger.Info(fmt.Sprintf("mismatch (-want +got):\n%s", diff))
 	}
 
 	return cmp.Equal(src, des, nil)
 }
 
-func handleImageStream(r *ReconcileConfiguration, logger logr.Logger, namespace string, buffer []byte) error {
+func handleImageStream(ctx context.Context, r *ReconcileConfiguration, logger logr.Logger, namespace string, buffer []byte, ignore ignoreRules, snapshots *snapshotStore) (*objectmatch.MatchResult, error) {
 	logger.Info("===== ImageStream =====")
 	fromK8s := &imagev1.ImageStream{}
 	fromFile := &imagev1.ImageStream{}
 	fromFile.Namespace = namespace
 	dec := k8s_yaml.NewYAMLOrJSONDecoder(bytes.NewReader(buffer), 1000)
+	var result *objectmatch.MatchResult
 	var err error
 	if err = dec.Decode(&fromFile); err == nil {
-		if err = r.client.Get(context.TODO(), types.NamespacedName{Name: fromFile.Name, Namespace: fromFile.Namespace}, fromK8s); err == nil {
+		if err = getObject(ctx, r, types.NamespacedName{Name: fromFile.Name, Namespace: fromFile.Namespace}, fromK8s); err == nil {
 			fromFile.ObjectMeta.ResourceVersion = fromK8s.ObjectMeta.ResourceVersion
+			ignore.apply(logger, fromK8s, fromFile)
+			preserveFields(r, logger, fromK8s, fromFile)
 
-			mergedPatchObject := &imagev1.ImageStream{}
-			patchError := calculateMergePatchObject(fromK8s, fromFile, mergedPatchObject)
-			logger.Info("DC mergedPatchObject: " + mergedPatchObject.String())
-			if patchError == nil {
-				logger.Info("Updating with: " + mergedPatchObject.String())
-				if err = r.client.Update(context.TODO(), mergedPatchObject); err != nil {
-					logger.Info("Update DeploymentConfig err: " + err.Error())
+			if result, err = diffObjects(ctx, fromK8s, fromFile); err == nil {
+				if !result.Matched {
+					mergedPatchObject := &imagev1.ImageStream{}
+					patchError := calculateMergePatchObject(fromK8s, fromFile, mergedPatchObject)
+					if patchError == nil {
+						logger.Info("Updating with: " + redact.Object(mergedPatchObject))
+						snapshots.save(logger, fromK8s)
+						if err = updateObject(ctx, r, mergedPatchObject); err != nil {
+							logger.Info("Update ImageStream err: " + err.Error())
+						}
+					} else {
+						logger.Info("=======> patchError: " + patchError.Error())
+					}
+				} else {
+					logger.Info("------------> ImageStream intact!")
 				}
 			} else {
-				logger.Info("=======> patchError: " + patchError.Error())
+				logger.Info("=======> MatchError: " + err.Error())
 			}
 
 			/*if err = r.client.Update(context.TODO(), fromFile); err != nil {
 				logger.Info("Update ImageStream err: " + err.Error())
 			}*/
 		} else {
-			if err = r.client.Create(context.TODO(), fromFile); err != nil {
+			if err = createObject(ctx, r, fromFile); err != nil {
 				logger.Info("Create ImageStream err: " + err.Error())
 			}
 		}
@@ -632,24 +936,36 @@ func handleImageStream(r *ReconcileConfiguration, logger logr.Logger, namespace
 		logger.Info("Unmarshal ImageStream err: " + err.Error())
 	}
 
-	return err
+	return result, err
 }
 
-func handleBuildConfig(r *ReconcileConfiguration, logger logr.Logger, namespace string, buffer []byte) error {
+func handleBuildConfig(ctx context.Context, r *ReconcileConfiguration, logger logr.Logger, namespace string, buffer []byte, ignore ignoreRules, snapshots *snapshotStore) (*objectmatch.MatchResult, error) {
 	logger.Info("===== BuildConfig =====")
 	fromK8s := &buildv1.BuildConfig{}
 	fromFile := &buildv1.BuildConfig{}
 	fromFile.Namespace = namespace
 	dec := k8s_yaml.NewYAMLOrJSONDecoder(bytes.NewReader(buffer), 1000)
+	var result *objectmatch.MatchResult
 	var err error
 	if err = dec.Decode(&fromFile); err == nil {
-		if err = r.client.Get(context.TODO(), types.NamespacedName{Name: fromFile.Name, Namespace: fromFile.Namespace}, fromK8s); err == nil {
+		if err = getObject(ctx, r, types.NamespacedName{Name: fromFile.Name, Namespace: fromFile.Namespace}, fromK8s); err == nil {
 			fromFile.ObjectMeta.ResourceVersion = fromK8s.ObjectMeta.ResourceVersion
-			if err = r.client.Update(context.TODO(), fromFile); err != nil {
-				logger.Info("Update BuildConfig err: " + err.Error())
+			ignore.apply(logger, fromK8s, fromFile)
+			preserveFields(r, logger, fromK8s, fromFile)
+			if result, err = diffObjects(ctx, fromK8s, fromFile); err == nil {
+				if !result.Matched {
+					snapshots.save(logger, fromK8s)
+					if err = updateObject(ctx, r, fromFile); err != nil {
+						logger.Info("Update BuildConfig err: " + err.Error())
+					}
+				} else {
+					logger.Info("------------> BuildConfig intact!")
+				}
+			} else {
+				logger.Info("=======> MatchError: " + err.Error())
 			}
 		} else {
-			if err = r.client.Create(context.TODO(), fromFile); err != nil {
+			if err = createObject(ctx, r, fromFile); err != nil {
 				logger.Info("Create BuildConfig err: " + err.Error())
 			}
 		}
@@ -657,24 +973,36 @@ func handleBuildConfig(r *ReconcileConfiguration, logger logr.Logger, namespace
 		logger.Info("Unmarshal BuildConfig err: " + err.Error())
 	}
 
-	return err
+	return result, err
 }
 
-func handleRoute(r *ReconcileConfiguration, logger logr.Logger, namespace string, buffer []byte) error {
+func handleRoute(ctx context.Context, r *ReconcileConfiguration, logger logr.Logger, namespace string, buffer []byte, ignore ignoreRules, snapshots *snapshotStore) (*objectmatch.MatchResult, error) {
 	logger.Info("===== Route =====")
 	fromK8s := &routev1.Route{}
 	fromFile := &routev1.Route{}
 	fromFile.Namespace = namespace
 	dec := k8s_yaml.NewYAMLOrJSONDecoder(bytes.NewReader(buffer), 1000)
+	var result *objectmatch.MatchResult
 	var err error
 	if err = dec.Decode(&fromFile); err == nil {
-		if err = r.client.Get(context.TODO(), types.NamespacedName{Name: fromFile.Name, Namespace: fromFile.Namespace}, fromK8s); err == nil {
+		if err = getObject(ctx, r, types.NamespacedName{Name: fromFile.Name, Namespace: fromFile.Namespace}, fromK8s); err == nil {
 			fromFile.ObjectMeta.ResourceVersion = fromK8s.ObjectMeta.ResourceVersion
-			if err = r.client.Update(context.TODO(), fromFile); err != nil {
-				logger.Info("Update Route err: " + err.Error())
+			ignore.apply(logger, fromK8s, fromFile)
+			preserveFields(r, logger, fromK8s, fromFile)
+			if result, err = diffObjects(ctx, fromK8s, fromFile); err == nil {
+				if !result.Matched {
+					snapshots.save(logger, fromK8s)
+					if err = updateObject(ctx, r, fromFile); err != nil {
+						logger.Info("Update Route err: " + err.Error())
+					}
+				} else {
+					logger.Info("------------> Route intact!")
+				}
+			} else {
+				logger.Info("=======> MatchError: " + err.Error())
 			}
 		} else {
-			if err = r.client.Create(context.TODO(), fromFile); err != nil {
+			if err = createObject(ctx, r, fromFile); err != nil {
 				logger.Info("Create Route err: " + err.Error())
 			}
 		}
@@ -682,24 +1010,36 @@ func handleRoute(r *ReconcileConfiguration, logger logr.Logger, namespace string
 		logger.Info("Unmarshal Route err: " + err.Error())
 	}
 
-	return err
+	return result, err
 }
 
-func handleService(r *ReconcileConfiguration, logger logr.Logger, namespace string, buffer []byte) error {
+func handleService(ctx context.Context, r *ReconcileConfiguration, logger logr.Logger, namespace string, buffer []byte, ignore ignoreRules, snapshots *snapshotStore) (*objectmatch.MatchResult, error) {
 	logger.Info("===== Service =====")
 	fromK8s := &corev1.Service{}
 	fromFile := &corev1.Service{}
 	fromFile.Namespace = namespace
 	dec := k8s_yaml.NewYAMLOrJSONDecoder(bytes.NewReader(buffer), 1000)
+	var result *objectmatch.MatchResult
 	var err error
 	if err = dec.Decode(&fromFile); err == nil {
-		if err = r.client.Get(context.TODO(), types.NamespacedName{Name: fromFile.Name, Namespace: fromFile.Namespace}, fromK8s); err == nil {
+		if err = getObject(ctx, r, types.NamespacedName{Name: fromFile.Name, Namespace: fromFile.Namespace}, fromK8s); err == nil {
 			fromFile.ObjectMeta.ResourceVersion = fromK8s.ObjectMeta.ResourceVersion
-			if err = r.client.Update(context.TODO(), fromFile); err != nil {
-				logger.Info("Update Service err: " + err.Error())
+			ignore.apply(logger, fromK8s, fromFile)
+			preserveFields(r, logger, fromK8s, fromFile)
+			if result, err = diffObjects(ctx, fromK8s, fromFile); err == nil {
+				if !result.Matched {
+					snapshots.save(logger, fromK8s)
+					if err = updateObject(ctx, r, fromFile); err != nil {
+						logger.Info("Update Service err: " + err.Error())
+					}
+				} else {
+					logger.Info("------------> Service intact!")
+				}
+			} else {
+				logger.Info("=======> MatchError: " + err.Error())
 			}
 		} else {
-			if err = r.client.Create(context.TODO(), fromFile); err != nil {
+			if err = createObject(ctx, r, fromFile); err != nil {
 				logger.Info("Create Service err: " + err.Error())
 			}
 		}
@@ -707,5 +1047,65 @@ func handleService(r *ReconcileConfiguration, logger logr.Logger, namespace stri
 		logger.Info("Unmarshal Service err: " + err.Error())
 	}
 
-	return err
+	return result, err
+}
+
+func handleUnstructured(ctx context.Context, r *ReconcileConfiguration, logger logr.Logger, namespace string, buffer []byte, ignore ignoreRules, snapshots *snapshotStore) (*objectmatch.MatchResult, error) {
+	var result *objectmatch.MatchResult
+	fromK8s := &unstructured.Unstructured{}
+	fromFile := &unstructured.Unstructured{}
+	jsonBytes, err := k8s_yaml.ToJSON(buffer)
+	if err == nil {
+		err = fromFile.UnmarshalJSON(jsonBytes)
+	}
+	if err == nil {
+		logger.Info("===== " + fromFile.GetKind() + " =====")
+		fromFile.SetNamespace(namespace)
+		fromK8s.SetGroupVersionKind(fromFile.GroupVersionKind())
+		if err = getObject(ctx, r, types.NamespacedName{Name: fromFile.GetName(), Namespace: fromFile.GetNamespace()}, fromK8s); err == nil {
+			fromFile.SetResourceVersion(fromK8s.GetResourceVersion())
+			ignore.apply(logger, fromK8s, fromFile)
+			preserveFields(r, logger, fromK8s, fromFile)
+			if result, err = diffUnstructured(ctx, r, fromK8s, fromFile); err == nil {
+				if !result.Matched {
+					snapshots.save(logger, fromK8s)
+					if err = updateObject(ctx, r, fromFile); err != nil {
+						logger.Info("Update " + fromFile.GetKind() + " err: " + err.Error())
+					}
+				} else {
+					logger.Info("------------> " + fromFile.GetKind() + " intact!")
+				}
+			} else {
+				logger.Info("=======> MatchError: " + err.Error())
+			}
+		} else {
+			if err = createObject(ctx, r, fromFile); err != nil {
+				logger.Info("Create " + fromFile.GetKind() + " err: " + err.Error())
+			}
+		}
+	} else {
+		logger.Info("Unmarshal unstructured err: " + err.Error())
+	}
+
+	return result, err
+}
+
+// diffUnstructured compares two unstructured objects with the matcher of their typed counterpart when the scheme
+// knows their kind, so that the typed matchers apply to kinds without a dedicated handler
+func diffUnstructured(ctx context.Context, r *ReconcileConfiguration, fromK8s, fromFile *unstructured.Unstructured) (*objectmatch.MatchResult, error) {
+	typedK8s, err := r.scheme.New(fromFile.GroupVersionKind())
+	if err != nil {
+		return diffObjects(ctx, fromK8s, fromFile)
+	}
+	typedFile, err := r.scheme.New(fromFile.GroupVersionKind())
+	if err != nil {
+		return nil, err
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(fromK8s.Object, typedK8s); err != nil {
+		return nil, err
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(fromFile.Object, typedFile); err != nil {
+		return nil, err
+	}
+	return diffObjects(ctx, typedK8s, typedFile)
 }
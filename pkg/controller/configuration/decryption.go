@@ -0,0 +1,102 @@
+package configuration
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	appv1alpha1 "github.com/cvicens/rocketeer-operator/pkg/apis/app/v1alpha1"
+	"github.com/cvicens/rocketeer-operator/pkg/seal"
+	"github.com/cvicens/rocketeer-operator/pkg/sops"
+	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/yaml"
+)
+
+// decrypter turns the encrypted descriptors of a Configuration, encrypted with sops or SealedSecrets, into Secrets.
+// The keys that could not be read are only reported when a descriptor needs them.
+type decrypter struct {
+	namespace   string
+	sopsKeys    *sops.Keys
+	sopsErr     error
+	sealingKeys seal.Keys
+	sealingErr  error
+}
+
+// newDecrypter reads the keys in the decryption Secret of instance and the operator sealing keys
+func newDecrypter(r *ReconcileConfiguration, instance *appv1alpha1.Configuration) *decrypter {
+	d := &decrypter{namespace: instance.Namespace}
+	d.sopsKeys, d.sopsErr = getDecryptionKeys(r, instance)
+	if operatorNamespace, err := k8sutil.GetOperatorNamespace(); err == nil {
+		d.sealingKeys, d.sealingErr = seal.ReadKeys(r.reader, operatorNamespace)
+	} else {
+		d.sealingErr = err
+	}
+	return d
+}
+
+// getDecryptionKeys returns the age and PGP private keys in the decryption Secret of instance, nil if it has none
+func getDecryptionKeys(r *ReconcileConfiguration, instance *appv1alpha1.Configuration) (*sops.Keys, error) {
+	if len(instance.Spec.DecryptionSecret) == 0 {
+		return nil, nil
+	}
+	secret := &corev1.Secret{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: instance.Spec.DecryptionSecret, Namespace: instance.Namespace}, secret); err != nil {
+		return nil, err
+	}
+	keys, err := sops.ParseKeys(secret.Data)
+	if err != nil {
+		return nil, fmt.Errorf("decryption Secret %s: %v", secret.Name, err)
+	}
+	return keys, nil
+}
+
+// decrypt returns the plaintext Secret of an encrypted descriptor, other descriptors are returned as they are
+func (d *decrypter) decrypt(buffer []byte) ([]byte, error) {
+	if sops.IsEncrypted(buffer) {
+		return d.decryptSops(buffer)
+	}
+	sealed := &seal.SealedSecret{}
+	if err := yaml.Unmarshal(buffer, sealed); err != nil || !seal.IsSealedSecret(sealed.APIVersion, sealed.Kind) {
+		return buffer, nil
+	}
+	return d.unseal(sealed)
+}
+
+// decryptSops only decrypts Secrets, as the values of other kinds are logged and recorded in diffs
+func (d *decrypter) decryptSops(buffer []byte) ([]byte, error) {
+	if d.sopsErr != nil {
+		return nil, d.sopsErr
+	}
+	if d.sopsKeys == nil {
+		return nil, errors.New("encrypted with sops but no decryptionSecret is set")
+	}
+	plaintext, err := sops.Decrypt(buffer, d.sopsKeys)
+	if err != nil {
+		return nil, err
+	}
+	object := struct {
+		Kind string `json:"kind"`
+	}{}
+	if err := json.Unmarshal(plaintext, &object); err != nil {
+		return nil, err
+	}
+	if object.Kind != "Secret" {
+		return nil, fmt.Errorf("only Secrets may be encrypted with sops, found %s", object.Kind)
+	}
+	return plaintext, nil
+}
+
+// unseal decrypts a SealedSecret, its values are sealed for the namespace of the Configuration
+func (d *decrypter) unseal(sealed *seal.SealedSecret) ([]byte, error) {
+	if d.sealingErr != nil {
+		return nil, fmt.Errorf("sealing keys: %v", d.sealingErr)
+	}
+	secret, err := d.sealingKeys.UnsealSecret(d.namespace, sealed)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(secret)
+}
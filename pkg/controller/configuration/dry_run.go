@@ -0,0 +1,152 @@
+package configuration
+
+import (
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	k8s_yaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/dynamic"
+)
+
+// dryRunAll is the dryRun value that processes every stage of a request without persisting it
+var dryRunAll = []string{metav1.DryRunAll}
+
+// mergePatchedKinds are the kinds whose handlers update the live object with the desired one merged into it by
+// calculateMergePatchObject, instead of with the desired object itself
+var mergePatchedKinds = map[string]bool{
+	"ConfigMap":        true,
+	"Secret":           true,
+	"Deployment":       true,
+	"DeploymentConfig": true,
+	"ImageStream":      true,
+}
+
+// dryRunDescriptors runs the create or update of every object in descriptors with dryRun=All, sending the object its
+// handler would send, and returns all the errors reported by the API server. Objects of kinds defined by a CustomResourceDefinition in descriptors cannot be
+// checked before it is created and are left out, as are unchanged objects and objects with the SkipDryRun option.
+func dryRunDescriptors(r *ReconcileConfiguration, logger logr.Logger, namespace string, descriptors []descriptor, ignore ignoreRules, lastApplied *appliedObjects) error {
+	definedKinds := customResourceKinds(descriptors)
+
+	errs := []error{}
+	for _, d := range descriptors {
+		if d.options.skipDryRun {
+			continue
+		}
+		desiredHash, err := desiredHash(d.buffer)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", d.String(), err))
+			continue
+		}
+		if lastApplied.unchanged(d, desiredHash) {
+			continue
+		}
+		if err := dryRunDescriptor(r, logger, namespace, d, desiredHash, ignore, lastApplied.instance.Name); err != nil {
+			gk := schema.FromAPIVersionAndKind(d.apiVersion, d.kind).GroupKind()
+			if meta.IsNoMatchError(err) && definedKinds[gk] {
+				continue
+			}
+			errs = append(errs, fmt.Errorf("%s: %v", d.String(), err))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func dryRunDescriptor(r *ReconcileConfiguration, logger logr.Logger, namespace string, d descriptor, desiredHash string, ignore ignoreRules, configuration string) error {
+	buffer, err := prepareBuffer(d.buffer, desiredHash, configuration)
+	if err != nil {
+		return err
+	}
+	desired := &unstructured.Unstructured{}
+	if err := desired.UnmarshalJSON(buffer); err != nil {
+		return err
+	}
+
+	gvk := desired.GroupVersionKind()
+	mapping, err := r.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return err
+	}
+	var resource dynamic.ResourceInterface = r.dynamic.Resource(mapping.Resource)
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		desired.SetNamespace(namespace)
+		resource = r.dynamic.Resource(mapping.Resource).Namespace(namespace)
+	}
+
+	live, err := resource.Get(desired.GetName(), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		_, err = resource.Create(desired, metav1.CreateOptions{DryRun: dryRunAll})
+		return err
+	}
+	if err != nil || d.options.createOnly {
+		return err
+	}
+
+	desired.SetResourceVersion(live.GetResourceVersion())
+	ignore.apply(logger, live, desired)
+	preserveFields(r, logger, live, desired)
+	updated, err := updatedObject(live, desired)
+	if err != nil {
+		return err
+	}
+	_, err = resource.Update(updated, metav1.UpdateOptions{DryRun: dryRunAll})
+	if err != nil && d.options.replace && errors.IsInvalid(err) {
+		// The object would be replaced
+		return nil
+	}
+	return err
+}
+
+// updatedObject returns the object the handler of the kind of desired updates live with
+func updatedObject(live, desired *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	kind := desired.GetKind()
+	if !mergePatchedKinds[kind] {
+		return desired, nil
+	}
+
+	typedLive, typedDesired, merged := newObjectForKind(kind), newObjectForKind(kind), newObjectForKind(kind)
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(live.Object, typedLive); err != nil {
+		return nil, err
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(desired.Object, typedDesired); err != nil {
+		return nil, err
+	}
+	if err := calculateMergePatchObject(typedLive, typedDesired, merged); err != nil {
+		return nil, err
+	}
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(merged)
+	if err != nil {
+		return nil, err
+	}
+	updated := &unstructured.Unstructured{Object: object}
+	updated.SetGroupVersionKind(desired.GroupVersionKind())
+	return updated, nil
+}
+
+// customResourceKinds returns the group and kind of the custom resources defined in descriptors
+func customResourceKinds(descriptors []descriptor) map[schema.GroupKind]bool {
+	kinds := map[schema.GroupKind]bool{}
+	for _, d := range descriptors {
+		if d.kind != "CustomResourceDefinition" {
+			continue
+		}
+		jsonBytes, err := k8s_yaml.ToJSON(d.buffer)
+		if err != nil {
+			continue
+		}
+		crd := &unstructured.Unstructured{}
+		if err := crd.UnmarshalJSON(jsonBytes); err != nil {
+			continue
+		}
+		group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+		kind, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "kind")
+		kinds[schema.GroupKind{Group: group, Kind: kind}] = true
+	}
+	return kinds
+}
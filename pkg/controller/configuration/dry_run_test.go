@@ -0,0 +1,67 @@
+package configuration
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+func mustUnstructured(t *testing.T, buffer string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	if err := yaml.Unmarshal([]byte(buffer), &u.Object); err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestUpdatedObject(t *testing.T) {
+	live := mustUnstructured(t, `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+  namespace: apps
+  resourceVersion: "1234"
+  annotations:
+    example.com/owner: someone-else
+data:
+  color: red
+  size: large
+`)
+	desired := mustUnstructured(t, `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+  namespace: apps
+  resourceVersion: "1234"
+data:
+  color: blue
+unknownField: dropped by the typed handler
+`)
+
+	updated, err := updatedObject(live, desired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _, _ := unstructured.NestedStringMap(updated.Object, "data"); !reflect.DeepEqual(data, map[string]string{"color": "blue"}) {
+		t.Errorf("unexpected data %v", data)
+	}
+	if len(updated.GetAnnotations()) > 0 || updated.GetResourceVersion() != "1234" {
+		t.Errorf("unexpected metadata %v", updated.Object["metadata"])
+	}
+	if _, ok := updated.Object["unknownField"]; ok {
+		t.Error("unknown field sent, the ConfigMap handler sends a typed object")
+	}
+	if updated.GroupVersionKind() != desired.GroupVersionKind() {
+		t.Errorf("unexpected kind %v", updated.GroupVersionKind())
+	}
+
+	// Other kinds are sent as they are described
+	service := mustUnstructured(t, "apiVersion: v1\nkind: Service\nmetadata:\n  name: web\nunknownField: kept\n")
+	if updated, err := updatedObject(service.DeepCopy(), service); err != nil || updated != service {
+		t.Errorf("Service: expected the desired object, got %v %v", updated, err)
+	}
+}
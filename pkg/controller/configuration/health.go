@@ -0,0 +1,260 @@
+package configuration
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appv1alpha1 "github.com/cvicens/rocketeer-operator/pkg/apis/app/v1alpha1"
+	oappsv1 "github.com/openshift/api/apps/v1"
+	buildv1 "github.com/openshift/api/build/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const HEALTH_REQUEUE_INTERVAL = 15 * time.Second
+
+// Reasons set by the OpenShift deployment config controller on the Progressing condition
+const DC_NEW_RC_AVAILABLE_REASON = "NewReplicationControllerAvailable"
+const DC_TIMED_OUT_REASON = "ProgressDeadlineExceeded"
+
+// assessHealth returns the health of the objects in descriptors that have a health rule or a built-in health
+// check, and their aggregate. Health rules take precedence over built-in checks.
+func assessHealth(r *ReconcileConfiguration, namespace string, descriptors []descriptor, rules healthRules) ([]appv1alpha1.ResourceHealth, appv1alpha1.HealthStatus) {
+	resources := []appv1alpha1.ResourceHealth{}
+	for _, d := range descriptors {
+		var health appv1alpha1.HealthStatus
+		var message string
+		if rule, ok := rules[schema.FromAPIVersionAndKind(d.apiVersion, d.kind).GroupKind()]; ok {
+			var err error
+			if health, message, err = assessRuleHealth(r, namespace, d, rule); err != nil {
+				health, message = appv1alpha1.HealthDegraded, err.Error()
+			}
+		} else {
+			var ok bool
+			if health, message, ok = assessObjectHealth(r, namespace, d.kind, d.name); !ok {
+				continue
+			}
+		}
+		resources = append(resources, appv1alpha1.ResourceHealth{Kind: d.kind, Name: d.name, Health: health, Message: message})
+	}
+
+	return resources, aggregateHealth(resources)
+}
+
+// aggregateHealth is Degraded if any resource is Degraded, Progressing if any is Progressing and Healthy otherwise
+func aggregateHealth(resources []appv1alpha1.ResourceHealth) appv1alpha1.HealthStatus {
+	health := appv1alpha1.HealthHealthy
+	for _, resource := range resources {
+		switch resource.Health {
+		case appv1alpha1.HealthDegraded:
+			return appv1alpha1.HealthDegraded
+		case appv1alpha1.HealthProgressing:
+			health = appv1alpha1.HealthProgressing
+		}
+	}
+	return health
+}
+
+// healthMessage describes the first resource whose health is the aggregated health, if that is not Healthy
+func healthMessage(resources []appv1alpha1.ResourceHealth, health appv1alpha1.HealthStatus) string {
+	for _, resource := range resources {
+		if resource.Health == health && health != appv1alpha1.HealthHealthy {
+			return fmt.Sprintf("%s/%s: %s", resource.Kind, resource.Name, resource.Message)
+		}
+	}
+	return ""
+}
+
+// assessObjectHealth returns false if there is no health check for kind
+func assessObjectHealth(r *ReconcileConfiguration, namespace string, kind string, name string) (appv1alpha1.HealthStatus, string, bool) {
+	key := types.NamespacedName{Name: name, Namespace: namespace}
+	var err error
+	var health appv1alpha1.HealthStatus
+	var message string
+
+	switch kind {
+	case "Deployment":
+		object := &appsv1.Deployment{}
+		if err = r.client.Get(context.TODO(), key, object); err == nil {
+			health, message = deploymentHealth(object)
+		}
+	case "DeploymentConfig":
+		object := &oappsv1.DeploymentConfig{}
+		if err = r.client.Get(context.TODO(), key, object); err == nil {
+			health, message = deploymentConfigHealth(object)
+		}
+	case "Route":
+		object := &routev1.Route{}
+		if err = r.client.Get(context.TODO(), key, object); err == nil {
+			health, message = routeHealth(object)
+		}
+	case "BuildConfig":
+		object := &buildv1.BuildConfig{}
+		if err = r.client.Get(context.TODO(), key, object); err == nil {
+			health, message, err = buildConfigHealth(r, object)
+		}
+	case "PersistentVolumeClaim":
+		object := &corev1.PersistentVolumeClaim{}
+		if err = r.client.Get(context.TODO(), key, object); err == nil {
+			health, message = pvcHealth(object)
+		}
+	case "Service":
+		object := &corev1.Service{}
+		if err = r.client.Get(context.TODO(), key, object); err == nil {
+			health, message, err = serviceHealth(r, object)
+		}
+	default:
+		return "", "", false
+	}
+
+	if err != nil {
+		return appv1alpha1.HealthDegraded, err.Error(), true
+	}
+	return health, message, true
+}
+
+func deploymentHealth(deployment *appsv1.Deployment) (appv1alpha1.HealthStatus, string) {
+	for _, condition := range deployment.Status.Conditions {
+		if condition.Type == appsv1.DeploymentProgressing && condition.Status == corev1.ConditionFalse {
+			return appv1alpha1.HealthDegraded, condition.Message
+		}
+	}
+
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	if deployment.Status.ObservedGeneration < deployment.Generation {
+		return appv1alpha1.HealthProgressing, "waiting for the rollout to be observed"
+	}
+	if deployment.Status.UpdatedReplicas < replicas {
+		return appv1alpha1.HealthProgressing, fmt.Sprintf("%d of %d replicas updated", deployment.Status.UpdatedReplicas, replicas)
+	}
+	if deployment.Status.Replicas > deployment.Status.UpdatedReplicas {
+		return appv1alpha1.HealthProgressing, fmt.Sprintf("%d old replicas pending termination", deployment.Status.Replicas-deployment.Status.UpdatedReplicas)
+	}
+	if deployment.Status.AvailableReplicas < replicas {
+		return appv1alpha1.HealthProgressing, fmt.Sprintf("%d of %d replicas available", deployment.Status.AvailableReplicas, replicas)
+	}
+	return appv1alpha1.HealthHealthy, ""
+}
+
+func deploymentConfigHealth(dc *oappsv1.DeploymentConfig) (appv1alpha1.HealthStatus, string) {
+	if dc.Status.ObservedGeneration < dc.Generation {
+		return appv1alpha1.HealthProgressing, "waiting for the rollout to be observed"
+	}
+	for _, condition := range dc.Status.Conditions {
+		if condition.Type != oappsv1.DeploymentProgressing {
+			continue
+		}
+		if condition.Status == corev1.ConditionFalse || condition.Reason == DC_TIMED_OUT_REASON {
+			return appv1alpha1.HealthDegraded, condition.Message
+		}
+		if condition.Reason == DC_NEW_RC_AVAILABLE_REASON && dc.Status.AvailableReplicas >= dc.Spec.Replicas {
+			return appv1alpha1.HealthHealthy, ""
+		}
+	}
+	return appv1alpha1.HealthProgressing, fmt.Sprintf("waiting for version %d to complete", dc.Status.LatestVersion)
+}
+
+func routeHealth(route *routev1.Route) (appv1alpha1.HealthStatus, string) {
+	for _, ingress := range route.Status.Ingress {
+		for _, condition := range ingress.Conditions {
+			if condition.Type != routev1.RouteAdmitted {
+				continue
+			}
+			if condition.Status == corev1.ConditionTrue {
+				return appv1alpha1.HealthHealthy, ""
+			}
+			if condition.Status == corev1.ConditionFalse {
+				return appv1alpha1.HealthDegraded, condition.Message
+			}
+		}
+	}
+	return appv1alpha1.HealthProgressing, "waiting for the route to be admitted"
+}
+
+func buildConfigHealth(r *ReconcileConfiguration, bc *buildv1.BuildConfig) (appv1alpha1.HealthStatus, string, error) {
+	if bc.Status.LastVersion == 0 {
+		return appv1alpha1.HealthProgressing, "no build has been run yet", nil
+	}
+
+	build := &buildv1.Build{}
+	name := fmt.Sprintf("%s-%d", bc.Name, bc.Status.LastVersion)
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: bc.Namespace}, build); err != nil {
+		return "", "", err
+	}
+	switch build.Status.Phase {
+	case buildv1.BuildPhaseComplete:
+		return appv1alpha1.HealthHealthy, "", nil
+	case buildv1.BuildPhaseFailed, buildv1.BuildPhaseError, buildv1.BuildPhaseCancelled:
+		return appv1alpha1.HealthDegraded, fmt.Sprintf("build %s %s: %s", name, build.Status.Phase, build.Status.Message), nil
+	}
+	return appv1alpha1.HealthProgressing, fmt.Sprintf("build %s %s", name, build.Status.Phase), nil
+}
+
+func pvcHealth(pvc *corev1.PersistentVolumeClaim) (appv1alpha1.HealthStatus, string) {
+	switch pvc.Status.Phase {
+	case corev1.ClaimBound:
+		return appv1alpha1.HealthHealthy, ""
+	case corev1.ClaimLost:
+		return appv1alpha1.HealthDegraded, "claim lost its volume"
+	}
+	return appv1alpha1.HealthProgressing, "waiting for the claim to be bound"
+}
+
+func serviceHealth(r *ReconcileConfiguration, service *corev1.Service) (appv1alpha1.HealthStatus, string, error) {
+	if service.Spec.Type == corev1.ServiceTypeExternalName || len(service.Spec.Selector) == 0 {
+		return appv1alpha1.HealthHealthy, "", nil
+	}
+
+	endpoints := &corev1.Endpoints{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: service.Name, Namespace: service.Namespace}, endpoints); err != nil {
+		return "", "", err
+	}
+	for _, subset := range endpoints.Subsets {
+		if len(subset.Addresses) > 0 {
+			return appv1alpha1.HealthHealthy, "", nil
+		}
+	}
+	return appv1alpha1.HealthProgressing, "no ready endpoints", nil
+}
+
+// setHealthConditions sets the Healthy, Progressing and Degraded conditions so that only the one matching health is True
+func setHealthConditions(status *appv1alpha1.ConfigurationStatus, health appv1alpha1.HealthStatus, message string) {
+	for _, conditionType := range []appv1alpha1.ConfigurationConditionType{appv1alpha1.ConfigurationHealthy, appv1alpha1.ConfigurationProgressing, appv1alpha1.ConfigurationDegraded} {
+		conditionStatus := corev1.ConditionFalse
+		conditionMessage := ""
+		if string(conditionType) == string(health) {
+			conditionStatus = corev1.ConditionTrue
+			conditionMessage = message
+		}
+		setCondition(status, conditionType, conditionStatus, string(health), conditionMessage)
+	}
+}
+
+// setCondition adds or updates the condition of type conditionType, LastTransitionTime only changes with the status
+func setCondition(status *appv1alpha1.ConfigurationStatus, conditionType appv1alpha1.ConfigurationConditionType, conditionStatus corev1.ConditionStatus, reason, message string) {
+	condition := appv1alpha1.ConfigurationCondition{
+		Type:               conditionType,
+		Status:             conditionStatus,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            message,
+	}
+	for i, c := range status.Conditions {
+		if c.Type == conditionType {
+			if c.Status == conditionStatus {
+				condition.LastTransitionTime = c.LastTransitionTime
+			}
+			status.Conditions[i] = condition
+			return
+		}
+	}
+	status.Conditions = append(status.Conditions, condition)
+}
@@ -0,0 +1,157 @@
+package configuration
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	appv1alpha1 "github.com/cvicens/rocketeer-operator/pkg/apis/app/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	k8s_yaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+const HEALTH_RULES_CONFIGMAP_ENV = "HEALTH_RULES_CONFIGMAP"
+const DEFAULT_HEALTH_RULES_CONFIGMAP = "rocketeer-health-rules"
+const HEALTH_RULES_KEY = "rules.yaml"
+
+// healthRules holds the health rules to use, by group and kind
+type healthRules map[schema.GroupKind]appv1alpha1.HealthRule
+
+// getHealthRules returns the operator-wide rules, read from the health rules ConfigMap in the operator namespace,
+// overridden by the rules in the Configuration spec
+func getHealthRules(r *ReconcileConfiguration, logger logr.Logger, spec appv1alpha1.ConfigurationSpec) healthRules {
+	rules := healthRules{}
+
+	if namespace, err := k8sutil.GetOperatorNamespace(); err == nil {
+		name := nvl(os.Getenv(HEALTH_RULES_CONFIGMAP_ENV), DEFAULT_HEALTH_RULES_CONFIGMAP)
+		configMap := &corev1.ConfigMap{}
+		if err := r.client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: namespace}, configMap); err == nil {
+			operatorRules := []appv1alpha1.HealthRule{}
+			dec := k8s_yaml.NewYAMLOrJSONDecoder(bytes.NewReader([]byte(configMap.Data[HEALTH_RULES_KEY])), 1000)
+			if err := dec.Decode(&operatorRules); err == nil {
+				rules.add(operatorRules)
+			} else {
+				logger.Info("Unmarshal health rules err: " + err.Error())
+			}
+		} else if !errors.IsNotFound(err) {
+			logger.Info("Get health rules err: " + err.Error())
+		}
+	}
+
+	rules.add(spec.HealthRules)
+	return rules
+}
+
+func (rules healthRules) add(list []appv1alpha1.HealthRule) {
+	for _, rule := range list {
+		rules[schema.GroupKind{Group: rule.Group, Kind: rule.Kind}] = rule
+	}
+}
+
+// assessRuleHealth evaluates rule against the live object described by d
+func assessRuleHealth(r *ReconcileConfiguration, namespace string, d descriptor, rule appv1alpha1.HealthRule) (appv1alpha1.HealthStatus, string, error) {
+	object := &unstructured.Unstructured{}
+	object.SetAPIVersion(d.apiVersion)
+	object.SetKind(d.kind)
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: d.name, Namespace: namespace}, object); err != nil {
+		return "", "", err
+	}
+
+	checks := []struct {
+		health      appv1alpha1.HealthStatus
+		expressions []string
+	}{
+		{appv1alpha1.HealthDegraded, rule.Degraded},
+		{appv1alpha1.HealthHealthy, rule.Healthy},
+		{appv1alpha1.HealthProgressing, rule.Progressing},
+	}
+	for _, check := range checks {
+		for _, expression := range check.expressions {
+			matched, err := evaluateHealthExpression(expression, object.Object)
+			if err != nil {
+				return "", "", err
+			}
+			if matched {
+				return check.health, expression, nil
+			}
+		}
+	}
+
+	return appv1alpha1.HealthProgressing, "no health rule matched", nil
+}
+
+// evaluateHealthExpression evaluates `<jsonpath> == <value>`, `<jsonpath> != <value>` or `<jsonpath>`, the latter
+// holding if the path is found. Values may be quoted.
+func evaluateHealthExpression(expression string, object map[string]interface{}) (bool, error) {
+	path, operator, value := splitHealthExpression(expression)
+
+	if !strings.HasPrefix(path, "{") {
+		path = "{." + strings.TrimPrefix(path, ".") + "}"
+	}
+	j := jsonpath.New("health").AllowMissingKeys(true)
+	if err := j.Parse(path); err != nil {
+		return false, fmt.Errorf("invalid health expression %q: %v", expression, err)
+	}
+	results, err := j.FindResults(object)
+	if err != nil {
+		return false, fmt.Errorf("could not evaluate health expression %q: %v", expression, err)
+	}
+
+	found := false
+	equal := false
+	for _, result := range results {
+		for _, v := range result {
+			found = true
+			if fmt.Sprint(v.Interface()) == value {
+				equal = true
+			}
+		}
+	}
+
+	switch operator {
+	case "==":
+		return equal, nil
+	case "!=":
+		return !equal, nil
+	}
+	return found, nil
+}
+
+// splitHealthExpression splits expression on the first == or != found outside brackets and quotes
+func splitHealthExpression(expression string) (string, string, string) {
+	depth := 0
+	quote := rune(0)
+	for i, c := range expression {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '(' || c == '{':
+			depth++
+		case c == ']' || c == ')' || c == '}':
+			depth--
+		case depth == 0 && (c == '=' || c == '!') && strings.HasPrefix(expression[i+1:], "="):
+			value := strings.TrimSpace(expression[i+2:])
+			if unquoted, err := strconv.Unquote(value); err == nil {
+				value = unquoted
+			} else if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+				value = value[1 : len(value)-1]
+			}
+			return strings.TrimSpace(expression[:i]), expression[i : i+2], value
+		}
+	}
+	return strings.TrimSpace(expression), "", ""
+}
@@ -0,0 +1,94 @@
+package configuration
+
+import (
+	"testing"
+
+	"sigs.k8s.io/yaml"
+)
+
+const certificate = `
+metadata:
+  name: web
+  labels:
+    app.kubernetes.io/name: web
+status:
+  replicas: 3
+  conditions:
+  - type: Issuing
+    status: "False"
+  - type: Ready
+    status: "True"
+    message: it's ready
+`
+
+func TestSplitHealthExpression(t *testing.T) {
+	tests := []struct {
+		expression string
+		path       string
+		operator   string
+		value      string
+	}{
+		{`status.phase`, "status.phase", "", ""},
+		{`status.phase == Bound`, "status.phase", "==", "Bound"},
+		{`status.phase=="Bound"`, "status.phase", "==", "Bound"},
+		{`status.phase != 'Lost'`, "status.phase", "!=", "Lost"},
+		{`status.message == "a == b"`, "status.message", "==", "a == b"},
+		{`status.message == 'it''s'`, "status.message", "==", "it''s"},
+		{`status.conditions[?(@.type=="Ready")].status == "True"`, `status.conditions[?(@.type=="Ready")].status`, "==", "True"},
+		{`status.conditions[?(@.type!="Ready")].status`, `status.conditions[?(@.type!="Ready")].status`, "", ""},
+		{`{.status.conditions[?(@.type=="Ready")].status} != False`, `{.status.conditions[?(@.type=="Ready")].status}`, "!=", "False"},
+	}
+
+	for _, test := range tests {
+		path, operator, value := splitHealthExpression(test.expression)
+		if path != test.path || operator != test.operator || value != test.value {
+			t.Errorf("%s: expected %q %q %q, got %q %q %q", test.expression, test.path, test.operator, test.value, path, operator, value)
+		}
+	}
+}
+
+func TestEvaluateHealthExpression(t *testing.T) {
+	object := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(certificate), &object); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		expression string
+		matched    bool
+		invalid    bool
+	}{
+		{expression: `status.conditions[?(@.type=="Ready")].status == "True"`, matched: true},
+		{expression: `status.conditions[?(@.type=="Issuing")].status == "True"`},
+		{expression: `status.conditions[?(@.type=="Ready")].status != "True"`},
+		{expression: `status.conditions[?(@.type=="Ready")].status`, matched: true},
+		{expression: `status.conditions[?(@.type=="Failed")].status`},
+		{expression: `status.replicas == 3`, matched: true},
+		{expression: `.status.replicas == '3'`, matched: true},
+		{expression: `status.conditions[?(@.type=="Ready")].message == 'it's ready'`, matched: true},
+		{expression: `metadata.labels.app\.kubernetes\.io/name == 'web'`, matched: true},
+		{expression: `{.status.conditions[?(@.type!="Ready")].type} == Issuing`, matched: true},
+		{expression: `status.phase != Failed`, matched: true},
+		{expression: `status.phase == ""`},
+		{expression: `status.phase`},
+		{expression: `status.conditions[?(@.type=="Ready").status`, invalid: true},
+		{expression: `status.conditions[0 == "True"`, invalid: true},
+	}
+
+	for _, test := range tests {
+		matched, err := evaluateHealthExpression(test.expression, object)
+		if test.invalid {
+			if err == nil {
+				t.Errorf("%s: expected an error", test.expression)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.expression, err)
+			continue
+		}
+		if matched != test.matched {
+			t.Errorf("%s: expected %v, got %v", test.expression, test.matched, matched)
+		}
+	}
+}
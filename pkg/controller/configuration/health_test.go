@@ -0,0 +1,252 @@
+package configuration
+
+import (
+	"testing"
+
+	appv1alpha1 "github.com/cvicens/rocketeer-operator/pkg/apis/app/v1alpha1"
+	oappsv1 "github.com/openshift/api/apps/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func int32Ptr(i int32) *int32 { return &i }
+
+func TestDeploymentHealth(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   appsv1.DeploymentStatus
+		expected appv1alpha1.HealthStatus
+	}{
+		{
+			name:     "rolled out",
+			status:   appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 3, AvailableReplicas: 3},
+			expected: appv1alpha1.HealthHealthy,
+		},
+		{
+			name:     "not observed",
+			status:   appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 3, UpdatedReplicas: 3, AvailableReplicas: 3},
+			expected: appv1alpha1.HealthProgressing,
+		},
+		{
+			name:     "updating",
+			status:   appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 1, AvailableReplicas: 3},
+			expected: appv1alpha1.HealthProgressing,
+		},
+		{
+			name:     "terminating old replicas",
+			status:   appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 4, UpdatedReplicas: 3, AvailableReplicas: 3},
+			expected: appv1alpha1.HealthProgressing,
+		},
+		{
+			name:     "unavailable",
+			status:   appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 3, AvailableReplicas: 2},
+			expected: appv1alpha1.HealthProgressing,
+		},
+		{
+			name: "deadline exceeded",
+			status: appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 1, Conditions: []appsv1.DeploymentCondition{
+				{Type: appsv1.DeploymentProgressing, Status: corev1.ConditionFalse, Reason: "ProgressDeadlineExceeded"},
+			}},
+			expected: appv1alpha1.HealthDegraded,
+		},
+	}
+
+	for _, test := range tests {
+		deployment := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Generation: 2},
+			Spec:       appsv1.DeploymentSpec{Replicas: int32Ptr(3)},
+			Status:     test.status,
+		}
+		if health, message := deploymentHealth(deployment); health != test.expected {
+			t.Errorf("%s: expected %s, got %s %s", test.name, test.expected, health, message)
+		}
+	}
+
+	// Replicas defaults to 1
+	deployment := &appsv1.Deployment{Status: appsv1.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1}}
+	if health, message := deploymentHealth(deployment); health != appv1alpha1.HealthHealthy {
+		t.Errorf("default replicas: expected %s, got %s %s", appv1alpha1.HealthHealthy, health, message)
+	}
+}
+
+func TestDeploymentConfigHealth(t *testing.T) {
+	progressing := func(status corev1.ConditionStatus, reason string) []oappsv1.DeploymentCondition {
+		return []oappsv1.DeploymentCondition{
+			{Type: oappsv1.DeploymentAvailable, Status: corev1.ConditionTrue},
+			{Type: oappsv1.DeploymentProgressing, Status: status, Reason: reason},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		status   oappsv1.DeploymentConfigStatus
+		expected appv1alpha1.HealthStatus
+	}{
+		{
+			name:     "rolled out",
+			status:   oappsv1.DeploymentConfigStatus{ObservedGeneration: 2, AvailableReplicas: 2, Conditions: progressing(corev1.ConditionTrue, DC_NEW_RC_AVAILABLE_REASON)},
+			expected: appv1alpha1.HealthHealthy,
+		},
+		{
+			name:     "not observed",
+			status:   oappsv1.DeploymentConfigStatus{ObservedGeneration: 1, AvailableReplicas: 2, Conditions: progressing(corev1.ConditionTrue, DC_NEW_RC_AVAILABLE_REASON)},
+			expected: appv1alpha1.HealthProgressing,
+		},
+		{
+			name:     "unavailable",
+			status:   oappsv1.DeploymentConfigStatus{ObservedGeneration: 2, AvailableReplicas: 1, Conditions: progressing(corev1.ConditionTrue, DC_NEW_RC_AVAILABLE_REASON)},
+			expected: appv1alpha1.HealthProgressing,
+		},
+		{
+			name:     "rolling out",
+			status:   oappsv1.DeploymentConfigStatus{ObservedGeneration: 2, AvailableReplicas: 2, Conditions: progressing(corev1.ConditionTrue, "ReplicationControllerUpdated")},
+			expected: appv1alpha1.HealthProgressing,
+		},
+		{
+			name:     "no conditions",
+			status:   oappsv1.DeploymentConfigStatus{ObservedGeneration: 2},
+			expected: appv1alpha1.HealthProgressing,
+		},
+		{
+			name:     "timed out",
+			status:   oappsv1.DeploymentConfigStatus{ObservedGeneration: 2, Conditions: progressing(corev1.ConditionTrue, DC_TIMED_OUT_REASON)},
+			expected: appv1alpha1.HealthDegraded,
+		},
+		{
+			name:     "failed",
+			status:   oappsv1.DeploymentConfigStatus{ObservedGeneration: 2, Conditions: progressing(corev1.ConditionFalse, "RolloutCancelled")},
+			expected: appv1alpha1.HealthDegraded,
+		},
+	}
+
+	for _, test := range tests {
+		dc := &oappsv1.DeploymentConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Generation: 2},
+			Spec:       oappsv1.DeploymentConfigSpec{Replicas: 2},
+			Status:     test.status,
+		}
+		if health, message := deploymentConfigHealth(dc); health != test.expected {
+			t.Errorf("%s: expected %s, got %s %s", test.name, test.expected, health, message)
+		}
+	}
+}
+
+func TestRouteHealth(t *testing.T) {
+	admitted := func(status corev1.ConditionStatus) routev1.RouteIngress {
+		return routev1.RouteIngress{RouterName: "default", Conditions: []routev1.RouteIngressCondition{{Type: routev1.RouteAdmitted, Status: status}}}
+	}
+
+	tests := []struct {
+		name     string
+		ingress  []routev1.RouteIngress
+		expected appv1alpha1.HealthStatus
+	}{
+		{name: "admitted", ingress: []routev1.RouteIngress{admitted(corev1.ConditionTrue)}, expected: appv1alpha1.HealthHealthy},
+		{name: "not admitted", ingress: []routev1.RouteIngress{admitted(corev1.ConditionFalse)}, expected: appv1alpha1.HealthDegraded},
+		{name: "pending", ingress: []routev1.RouteIngress{admitted(corev1.ConditionUnknown)}, expected: appv1alpha1.HealthProgressing},
+		{name: "no router", expected: appv1alpha1.HealthProgressing},
+	}
+
+	for _, test := range tests {
+		route := &routev1.Route{ObjectMeta: metav1.ObjectMeta{Name: "web"}, Status: routev1.RouteStatus{Ingress: test.ingress}}
+		if health, message := routeHealth(route); health != test.expected {
+			t.Errorf("%s: expected %s, got %s %s", test.name, test.expected, health, message)
+		}
+	}
+}
+
+func TestPVCHealth(t *testing.T) {
+	tests := []struct {
+		phase    corev1.PersistentVolumeClaimPhase
+		expected appv1alpha1.HealthStatus
+	}{
+		{corev1.ClaimBound, appv1alpha1.HealthHealthy},
+		{corev1.ClaimPending, appv1alpha1.HealthProgressing},
+		{"", appv1alpha1.HealthProgressing},
+		{corev1.ClaimLost, appv1alpha1.HealthDegraded},
+	}
+
+	for _, test := range tests {
+		pvc := &corev1.PersistentVolumeClaim{Status: corev1.PersistentVolumeClaimStatus{Phase: test.phase}}
+		if health, message := pvcHealth(pvc); health != test.expected {
+			t.Errorf("%q: expected %s, got %s %s", test.phase, test.expected, health, message)
+		}
+	}
+}
+
+func TestServiceHealth(t *testing.T) {
+	endpoints := func(name string, subsets ...corev1.EndpointSubset) *corev1.Endpoints {
+		return &corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "apps"}, Subsets: subsets}
+	}
+	address := corev1.EndpointSubset{Addresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}}}
+	notReady := corev1.EndpointSubset{NotReadyAddresses: []corev1.EndpointAddress{{IP: "10.0.0.2"}}}
+	r := &ReconcileConfiguration{client: newMemoryClient(
+		endpoints("ready", notReady, address),
+		endpoints("not-ready", notReady),
+		endpoints("empty"),
+	)}
+
+	selector := map[string]string{"app": "web"}
+	tests := []struct {
+		name     string
+		spec     corev1.ServiceSpec
+		expected appv1alpha1.HealthStatus
+		err      bool
+	}{
+		{name: "ready", spec: corev1.ServiceSpec{Selector: selector}, expected: appv1alpha1.HealthHealthy},
+		{name: "not-ready", spec: corev1.ServiceSpec{Selector: selector}, expected: appv1alpha1.HealthProgressing},
+		{name: "empty", spec: corev1.ServiceSpec{Selector: selector}, expected: appv1alpha1.HealthProgressing},
+		{name: "missing", spec: corev1.ServiceSpec{Selector: selector}, err: true},
+		{name: "missing", spec: corev1.ServiceSpec{}, expected: appv1alpha1.HealthHealthy},
+		{name: "missing", spec: corev1.ServiceSpec{Type: corev1.ServiceTypeExternalName, ExternalName: "db.example.com"}, expected: appv1alpha1.HealthHealthy},
+	}
+
+	for _, test := range tests {
+		service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: test.name, Namespace: "apps"}, Spec: test.spec}
+		health, message, err := serviceHealth(r, service)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %s", test.name, health)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if health != test.expected {
+			t.Errorf("%s %+v: expected %s, got %s %s", test.name, test.spec, test.expected, health, message)
+		}
+	}
+}
+
+func TestAggregateHealth(t *testing.T) {
+	healthy := appv1alpha1.ResourceHealth{Kind: "Route", Name: "web", Health: appv1alpha1.HealthHealthy}
+	progressing := appv1alpha1.ResourceHealth{Kind: "Deployment", Name: "web", Health: appv1alpha1.HealthProgressing, Message: "1 of 3 replicas available"}
+	degraded := appv1alpha1.ResourceHealth{Kind: "PersistentVolumeClaim", Name: "data", Health: appv1alpha1.HealthDegraded, Message: "claim lost its volume"}
+
+	tests := []struct {
+		name      string
+		resources []appv1alpha1.ResourceHealth
+		expected  appv1alpha1.HealthStatus
+		message   string
+	}{
+		{name: "no resources", expected: appv1alpha1.HealthHealthy},
+		{name: "healthy", resources: []appv1alpha1.ResourceHealth{healthy, healthy}, expected: appv1alpha1.HealthHealthy},
+		{name: "progressing", resources: []appv1alpha1.ResourceHealth{healthy, progressing}, expected: appv1alpha1.HealthProgressing, message: "Deployment/web: 1 of 3 replicas available"},
+		{name: "degraded after progressing", resources: []appv1alpha1.ResourceHealth{progressing, degraded, healthy}, expected: appv1alpha1.HealthDegraded, message: "PersistentVolumeClaim/data: claim lost its volume"},
+		{name: "degraded before progressing", resources: []appv1alpha1.ResourceHealth{degraded, progressing}, expected: appv1alpha1.HealthDegraded, message: "PersistentVolumeClaim/data: claim lost its volume"},
+	}
+
+	for _, test := range tests {
+		health := aggregateHealth(test.resources)
+		if health != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.name, test.expected, health)
+		}
+		if message := healthMessage(test.resources, health); message != test.message {
+			t.Errorf("%s: expected message %q, got %q", test.name, test.message, message)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package configuration
+
+import (
+	"bytes"
+	"context"
+	"os"
+
+	appv1alpha1 "github.com/cvicens/rocketeer-operator/pkg/apis/app/v1alpha1"
+	objectmatch "github.com/cvicens/rocketeer-operator/pkg/objectmatcher"
+	"github.com/go-logr/logr"
+	oappsv1 "github.com/openshift/api/apps/v1"
+	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	k8s_yaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const IGNORE_DIFFERENCES_CONFIGMAP_ENV = "IGNORE_DIFFERENCES_CONFIGMAP"
+const DEFAULT_IGNORE_DIFFERENCES_CONFIGMAP = "rocketeer-ignore-differences"
+const IGNORE_DIFFERENCES_KEY = "rules.yaml"
+
+// openShiftIgnoreDifferences are the fields OpenShift sets on objects it does not own
+var openShiftIgnoreDifferences = []appv1alpha1.IgnoreDifference{
+	{
+		Kind: "Namespace",
+		JSONPointers: []string{
+			"/metadata/annotations/openshift.io~1sa.scc.mcs",
+			"/metadata/annotations/openshift.io~1sa.scc.supplemental-groups",
+			"/metadata/annotations/openshift.io~1sa.scc.uid-range",
+		},
+	},
+	{
+		Kind: "Service",
+		JSONPointers: []string{
+			"/metadata/annotations/service.alpha.openshift.io~1serving-cert-signed-by",
+			"/metadata/annotations/service.beta.openshift.io~1serving-cert-signed-by",
+		},
+	},
+	{
+		// The dockercfg and token secrets of service accounts
+		Kind:         "ServiceAccount",
+		JSONPointers: []string{"/secrets", "/imagePullSecrets"},
+	},
+}
+
+// ignoreRules holds the fields to ignore, by group and kind
+type ignoreRules map[schema.GroupKind][]appv1alpha1.IgnoreDifference
+
+// getIgnoreRules returns the built-in presets, the replicas of the objects scaled by a HorizontalPodAutoscaler in
+// namespace, the operator-wide rules read from the ignore differences ConfigMap in the operator namespace and the
+// rules in the Configuration spec
+func getIgnoreRules(r *ReconcileConfiguration, logger logr.Logger, namespace string, spec appv1alpha1.ConfigurationSpec) ignoreRules {
+	rules := ignoreRules{}
+	rules.add(logger, openShiftIgnoreDifferences)
+	rules.add(logger, hpaIgnoreDifferences(r, logger, namespace))
+
+	if operatorNamespace, err := k8sutil.GetOperatorNamespace(); err == nil {
+		name := nvl(os.Getenv(IGNORE_DIFFERENCES_CONFIGMAP_ENV), DEFAULT_IGNORE_DIFFERENCES_CONFIGMAP)
+		configMap := &corev1.ConfigMap{}
+		if err := r.client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: operatorNamespace}, configMap); err == nil {
+			operatorRules := []appv1alpha1.IgnoreDifference{}
+			dec := k8s_yaml.NewYAMLOrJSONDecoder(bytes.NewReader([]byte(configMap.Data[IGNORE_DIFFERENCES_KEY])), 1000)
+			if err := dec.Decode(&operatorRules); err == nil {
+				rules.add(logger, operatorRules)
+			} else {
+				logger.Info("Unmarshal ignore differences err: " + err.Error())
+			}
+		} else if !errors.IsNotFound(err) {
+			logger.Info("Get ignore differences err: " + err.Error())
+		}
+	}
+
+	rules.add(logger, spec.IgnoreDifferences)
+	return rules
+}
+
+// add adds the rules in list, leaving out those with invalid expressions
+func (rules ignoreRules) add(logger logr.Logger, list []appv1alpha1.IgnoreDifference) {
+	for _, rule := range list {
+		if err := objectmatch.ValidateIgnoreFields(rule.JSONPointers, rule.JSONPaths); err != nil {
+			logger.Info("Invalid ignore differences rule for " + rule.Kind + ": " + err.Error())
+			continue
+		}
+		gk := schema.GroupKind{Group: rule.Group, Kind: rule.Kind}
+		rules[gk] = append(rules[gk], rule)
+	}
+}
+
+// apply sets the ignored fields of desired to their value in live
+func (rules ignoreRules) apply(logger logr.Logger, live, desired runtime.Object) {
+	accessor, err := meta.Accessor(desired)
+	if err != nil {
+		return
+	}
+	var pointers, paths []string
+	for _, rule := range rules[desired.GetObjectKind().GroupVersionKind().GroupKind()] {
+		if rule.Name == "" || rule.Name == accessor.GetName() {
+			pointers = append(pointers, rule.JSONPointers...)
+			paths = append(paths, rule.JSONPaths...)
+		}
+	}
+	if dc, ok := desired.(*oappsv1.DeploymentConfig); ok {
+		paths = append(paths, imageTriggerPaths(dc)...)
+	}
+	if len(pointers) == 0 && len(paths) == 0 {
+		return
+	}
+
+	if err := objectmatch.IgnoreFields(live, desired, pointers, paths); err != nil {
+		logger.Info("Ignore differences err: " + err.Error())
+	}
+}
+
+// hpaIgnoreDifferences returns rules ignoring the replicas of the objects scaled by the HorizontalPodAutoscalers in
+// namespace
+func hpaIgnoreDifferences(r *ReconcileConfiguration, logger logr.Logger, namespace string) []appv1alpha1.IgnoreDifference {
+	hpaList := &autoscalingv1.HorizontalPodAutoscalerList{}
+	if err := r.client.List(context.TODO(), &client.ListOptions{Namespace: namespace}, hpaList); err != nil {
+		logger.Info("List HorizontalPodAutoscalers err: " + err.Error())
+		return nil
+	}
+
+	rules := []appv1alpha1.IgnoreDifference{}
+	for _, hpa := range hpaList.Items {
+		target := hpa.Spec.ScaleTargetRef
+		gv, err := schema.ParseGroupVersion(target.APIVersion)
+		if err != nil {
+			continue
+		}
+		rules = append(rules, appv1alpha1.IgnoreDifference{
+			Group:        gv.Group,
+			Kind:         target.Kind,
+			Name:         target.Name,
+			JSONPointers: []string{objectmatch.REPLICAS_POINTER},
+		})
+	}
+	return rules
+}
+
+// imageTriggerPaths returns the images of the containers of dc that are set by its image change triggers
+func imageTriggerPaths(dc *oappsv1.DeploymentConfig) []string {
+	paths := []string{}
+	for _, trigger := range dc.Spec.Triggers {
+		if trigger.Type != oappsv1.DeploymentTriggerOnImageChange || trigger.ImageChangeParams == nil {
+			continue
+		}
+		for _, name := range trigger.ImageChangeParams.ContainerNames {
+			paths = append(paths,
+				`spec.template.spec.containers[?(@.name=="`+name+`")].image`,
+				`spec.template.spec.initContainers[?(@.name=="`+name+`")].image`)
+		}
+	}
+	return paths
+}
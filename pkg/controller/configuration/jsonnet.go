@@ -0,0 +1,132 @@
+package configuration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	appv1alpha1 "github.com/cvicens/rocketeer-operator/pkg/apis/app/v1alpha1"
+	jsonnet "github.com/google/go-jsonnet"
+)
+
+const JSONNET_EXTENSION = ".jsonnet"
+
+// readJsonnetDescriptors evaluates a .jsonnet file of the repository cloned in repository and returns a descriptor
+// for each object it emits. Imports are resolved relative to the importing file or to the repository root and may
+// not leave the repository.
+func readJsonnetDescriptors(repository, file string, spec appv1alpha1.JsonnetSpec) ([]descriptor, error) {
+	root, err := filepath.Abs(repository)
+	if err != nil {
+		return nil, err
+	}
+	path, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	if !isInFolder(root, path) {
+		return nil, fmt.Errorf("jsonnet file %s is outside the repository", file)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	vm := jsonnet.MakeVM()
+	vm.Importer(&repositoryImporter{root: root, cache: map[string]jsonnet.Contents{}})
+	for key, value := range spec.ExtVars {
+		vm.ExtVar(key, value)
+	}
+	for key, value := range spec.TLAs {
+		vm.TLAVar(key, value)
+	}
+
+	output, err := vm.EvaluateSnippet(path, string(b))
+	if err != nil {
+		return nil, err
+	}
+
+	return descriptorsFromJSON(filepath.Base(file), []byte(output))
+}
+
+// descriptorsFromJSON returns a descriptor for a JSON object or for each object in a JSON array
+func descriptorsFromJSON(file string, output []byte) ([]descriptor, error) {
+	items := []json.RawMessage{}
+	if trimmed := bytes.TrimSpace(output); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &items); err != nil {
+			return nil, err
+		}
+	} else {
+		items = append(items, json.RawMessage(trimmed))
+	}
+
+	descriptors := []descriptor{}
+	for _, item := range items {
+		d, err := newDescriptor(file, item)
+		if err != nil {
+			return nil, err
+		}
+		descriptors = append(descriptors, d)
+	}
+
+	return descriptors, nil
+}
+
+// repositoryImporter is a jsonnet.Importer that skips the candidates resolving outside root
+type repositoryImporter struct {
+	root  string
+	cache map[string]jsonnet.Contents
+}
+
+func (i *repositoryImporter) Import(importedFrom, importedPath string) (jsonnet.Contents, string, error) {
+	candidates := []string{}
+	if filepath.IsAbs(importedPath) {
+		candidates = append(candidates, filepath.Clean(importedPath))
+	} else {
+		candidates = append(candidates, filepath.Join(filepath.Dir(importedFrom), importedPath), filepath.Join(i.root, importedPath))
+	}
+
+	outside := false
+	for _, candidate := range candidates {
+		if !isInFolder(i.root, candidate) {
+			outside = true
+			continue
+		}
+		if contents, ok := i.cache[candidate]; ok {
+			return contents, candidate, nil
+		}
+		b, err := ioutil.ReadFile(candidate)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return jsonnet.Contents{}, "", err
+		}
+		contents := jsonnet.MakeContents(string(b))
+		i.cache[candidate] = contents
+		return contents, candidate, nil
+	}
+
+	if outside {
+		return jsonnet.Contents{}, "", fmt.Errorf("import %s is outside the repository", importedPath)
+	}
+	return jsonnet.Contents{}, "", fmt.Errorf("couldn't open import %s: not found in the repository", importedPath)
+}
+
+// isInFolder returns true if path, once symbolic links are resolved, is folder or lives under it
+func isInFolder(folder, path string) bool {
+	if resolved, err := filepath.EvalSymlinks(folder); err == nil {
+		folder = resolved
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	rel, err := filepath.Rel(folder, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
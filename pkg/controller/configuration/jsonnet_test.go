@@ -0,0 +1,109 @@
+package configuration
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	appv1alpha1 "github.com/cvicens/rocketeer-operator/pkg/apis/app/v1alpha1"
+)
+
+// writeFiles creates files, relative paths to their contents, under folder
+func writeFiles(t *testing.T, folder string, files map[string]string) {
+	for name, contents := range files {
+		path := filepath.Join(folder, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReadJsonnetDescriptors(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "jsonnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	root := filepath.Join(tmp, "repository")
+	writeFiles(t, tmp, map[string]string{
+		"secret.libsonnet": `{ password: "hunter2" }`,
+	})
+	writeFiles(t, root, map[string]string{
+		"lib/config.libsonnet": `{ name(suffix): "web-" + suffix }`,
+		"lib/labels.libsonnet": `{ app: "web" }`,
+		"k8s/labels.libsonnet": `{ app: "relative" }`,
+		"k8s/relative.jsonnet": `
+local labels = import "labels.libsonnet";
+{ apiVersion: "v1", kind: "ConfigMap", metadata: { name: "relative", labels: labels } }`,
+		"k8s/root.jsonnet": `
+local config = import "lib/config.libsonnet";
+function(replicas) [
+  { apiVersion: "v1", kind: "ConfigMap", metadata: { name: config.name(std.extVar("environment")) } },
+  { apiVersion: "apps/v1", kind: "Deployment", metadata: { name: "web" }, spec: { replicas: std.parseInt(replicas) } },
+]`,
+		"k8s/escaping.jsonnet": `
+local secret = import "../../secret.libsonnet";
+{ apiVersion: "v1", kind: "Secret", metadata: { name: secret.password } }`,
+		"k8s/absolute.jsonnet": `
+local secret = import "` + filepath.Join(tmp, "secret.libsonnet") + `";
+{ apiVersion: "v1", kind: "Secret", metadata: { name: secret.password } }`,
+		"k8s/symlinked.jsonnet": `
+local labels = import "lib/labels.libsonnet";
+{ apiVersion: "v1", kind: "ConfigMap", metadata: { name: "symlinked", labels: labels } }`,
+		"k8s/broken.jsonnet": `{ apiVersion: "v1", kind: "ConfigMap", metadata: { name: std.extVar("missing") } }`,
+	})
+	// k8s/lib links outside of the repository, lib/labels.libsonnet is then found from the root
+	if err := os.Symlink(tmp, filepath.Join(root, "k8s", "lib")); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, tmp, map[string]string{"lib/labels.libsonnet": `{ app: "outside" }`})
+
+	spec := appv1alpha1.JsonnetSpec{ExtVars: map[string]string{"environment": "prod"}, TLAs: map[string]string{"replicas": "3"}}
+
+	tests := []struct {
+		file     string
+		expected []string
+		err      string
+	}{
+		{file: "relative.jsonnet", expected: []string{`"app":"relative"`}},
+		{file: "root.jsonnet", expected: []string{`"name":"web-prod"`, `"replicas":3`}},
+		{file: "symlinked.jsonnet", expected: []string{`"app":"web"`}},
+		{file: "escaping.jsonnet", err: "outside the repository"},
+		{file: "absolute.jsonnet", err: "outside the repository"},
+		{file: "broken.jsonnet", err: "missing"},
+	}
+
+	for _, test := range tests {
+		descriptors, err := readJsonnetDescriptors(root, filepath.Join(root, "k8s", test.file), spec)
+		if len(test.err) > 0 {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: expected %q error, got %v", test.file, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.file, err)
+			continue
+		}
+		buffers := ""
+		for _, d := range descriptors {
+			if d.file != test.file {
+				t.Errorf("%s: descriptor of %s", test.file, d.file)
+			}
+			buffers += strings.Join(strings.Fields(string(d.buffer)), "")
+		}
+		for _, expected := range test.expected {
+			if !strings.Contains(buffers, expected) {
+				t.Errorf("%s: %s not found in %s", test.file, expected, buffers)
+			}
+		}
+		if strings.Contains(buffers, "hunter2") || strings.Contains(buffers, "outside") {
+			t.Errorf("%s: read outside of the repository: %s", test.file, buffers)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package configuration
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	appv1alpha1 "github.com/cvicens/rocketeer-operator/pkg/apis/app/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Metrics are labelled by the namespace and name of the Configuration
+var (
+	syncDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "rocketeer_sync_duration_seconds",
+		Help:    "Time taken to apply the descriptors of a Configuration, by result (success or failure).",
+		Buckets: prometheus.ExponentialBuckets(0.1, 2, 12),
+	}, []string{"namespace", "configuration", "result"})
+
+	gitDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "rocketeer_git_duration_seconds",
+		Help:    "Time taken to clone or fetch the repository of a Configuration, by operation (clone or fetch).",
+		Buckets: prometheus.ExponentialBuckets(0.1, 2, 10),
+	}, []string{"namespace", "configuration", "operation"})
+
+	gitBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "rocketeer_git_bytes_total",
+		Help: "Bytes added to the local repository of a Configuration by clones and fetches, by operation (clone or fetch).",
+	}, []string{"namespace", "configuration", "operation"})
+
+	syncObjects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "rocketeer_sync_objects_total",
+		Help: "Objects synced by a Configuration, by result (applied, unchanged or failed).",
+	}, []string{"namespace", "configuration", "result"})
+
+	driftDetected = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "rocketeer_drift_detected",
+		Help: "Objects of a Configuration found to differ from their descriptor by the last sync of an unchanged revision.",
+	}, []string{"namespace", "configuration"})
+
+	lastSuccessfulSync = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "rocketeer_last_successful_sync_timestamp_seconds",
+		Help: "Time of the last successful sync of a Configuration.",
+	}, []string{"namespace", "configuration"})
+)
+
+var syncResults = []string{"success", "failure"}
+var gitOperations = []string{"clone", "fetch"}
+var objectResults = []string{"applied", "unchanged", "failed"}
+
+func init() {
+	metrics.Registry.MustRegister(syncDuration, gitDuration, gitBytes, syncObjects, driftDetected, lastSuccessfulSync)
+}
+
+// objectResult returns the result an action is counted as
+func objectResult(action appv1alpha1.SyncAction) string {
+	switch action {
+	case appv1alpha1.SyncActionUnchanged, appv1alpha1.SyncActionSkipped:
+		return "unchanged"
+	case appv1alpha1.SyncActionFailed:
+		return "failed"
+	}
+	return "applied"
+}
+
+// drifted reports whether an action was taken on an object that differed from its descriptor. Objects are only
+// applied without a diff when they are created, which for an unchanged revision means they had been deleted.
+func drifted(action appv1alpha1.SyncAction) bool {
+	switch action {
+	case appv1alpha1.SyncActionApplied, appv1alpha1.SyncActionUpdated, appv1alpha1.SyncActionReplaced:
+		return true
+	}
+	return false
+}
+
+// recordSyncMetrics records the metrics of the sync of instance from revision from to revision to
+func recordSyncMetrics(instance *appv1alpha1.Configuration, run *syncRun, from, to string, syncErr error) {
+	namespace, name := instance.Namespace, instance.Name
+	result := "success"
+	if syncErr != nil {
+		result = "failure"
+	}
+	syncDuration.WithLabelValues(namespace, name, result).Observe(time.Since(run.start).Seconds())
+
+	drift := 0
+	for _, object := range run.objects {
+		syncObjects.WithLabelValues(namespace, name, objectResult(object.Action)).Inc()
+		if drifted(object.Action) {
+			drift++
+		}
+	}
+	if syncErr != nil {
+		return
+	}
+	if from != to {
+		drift = 0
+	}
+	driftDetected.WithLabelValues(namespace, name).Set(float64(drift))
+	lastSuccessfulSync.WithLabelValues(namespace, name).SetToCurrentTime()
+}
+
+// gitFolderSize is the size of GIT_LOCAL_FOLDER after the last clone or fetch that changed it, -1 until the first
+// clone
+var gitFolderSize int64 = -1
+
+// recordGitMetrics records the duration of a clone or fetch started at start. The local repository is only measured
+// after the clones and fetches that changed it, the bytes they added are the growth from the last measure.
+func recordGitMetrics(configuration types.NamespacedName, operation string, start time.Time, changed bool) {
+	gitDuration.WithLabelValues(configuration.Namespace, configuration.Name, operation).Observe(time.Since(start).Seconds())
+	if !changed {
+		return
+	}
+	size, previous := dirSize(GIT_LOCAL_FOLDER), gitFolderSize
+	if operation == "clone" {
+		previous = 0
+	}
+	gitFolderSize = size
+	// The size before the first fetch after a restart is not known
+	if grown := size - previous; previous >= 0 && grown > 0 {
+		gitBytes.WithLabelValues(configuration.Namespace, configuration.Name, operation).Add(float64(grown))
+	}
+}
+
+// deleteMetrics drops the metrics of a deleted Configuration
+func deleteMetrics(configuration types.NamespacedName) {
+	namespace, name := configuration.Namespace, configuration.Name
+	for _, result := range syncResults {
+		syncDuration.DeleteLabelValues(namespace, name, result)
+	}
+	for _, operation := range gitOperations {
+		gitDuration.DeleteLabelValues(namespace, name, operation)
+		gitBytes.DeleteLabelValues(namespace, name, operation)
+	}
+	for _, result := range objectResults {
+		syncObjects.DeleteLabelValues(namespace, name, result)
+	}
+	driftDetected.DeleteLabelValues(namespace, name)
+	lastSuccessfulSync.DeleteLabelValues(namespace, name)
+}
+
+// dirSize returns the size of the files under path, 0 if it does not exist
+func dirSize(path string) int64 {
+	var size int64
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
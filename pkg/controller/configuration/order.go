@@ -0,0 +1,183 @@
+package configuration
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	appv1alpha1 "github.com/cvicens/rocketeer-operator/pkg/apis/app/v1alpha1"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const SYNC_WAVE_ANNOTATION = "app.rocketeer.com/sync-wave"
+
+const DEFAULT_WAVE_TIMEOUT_SECONDS = 600
+
+const CRD_POLL_INTERVAL = 2 * time.Second
+const CRD_ESTABLISHED_TIMEOUT = 30 * time.Second
+
+// CRD_NOT_ESTABLISHED is the message of a wave waiting for a CustomResourceDefinition
+const CRD_NOT_ESTABLISHED = "not established"
+
+// kindOrder is the order kinds are applied in within a sync wave, kinds not listed here are applied last
+var kindOrder = []string{
+	"Namespace",
+	"CustomResourceDefinition",
+	"ServiceAccount",
+	"Role",
+	"ClusterRole",
+	"RoleBinding",
+	"ClusterRoleBinding",
+	"ConfigMap",
+	"Secret",
+	"PersistentVolumeClaim",
+	"Service",
+	"ImageStream",
+	"BuildConfig",
+	"Deployment",
+	"DeploymentConfig",
+	"StatefulSet",
+	"DaemonSet",
+	"Job",
+	"CronJob",
+	"Route",
+}
+
+func kindPriority(kind string) int {
+	for i, k := range kindOrder {
+		if k == kind {
+			return i
+		}
+	}
+	return len(kindOrder)
+}
+
+// syncWave returns the value of the sync-wave annotation, 0 if it is not set
+func syncWave(annotations map[string]string) (int, error) {
+	value, ok := annotations[SYNC_WAVE_ANNOTATION]
+	if !ok {
+		return 0, nil
+	}
+	wave, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s annotation %q", SYNC_WAVE_ANNOTATION, value)
+	}
+	return wave, nil
+}
+
+// sortDescriptors orders descriptors by sync wave first and kind priority second, keeping the file order otherwise
+func sortDescriptors(descriptors []descriptor) {
+	sort.SliceStable(descriptors, func(i, j int) bool {
+		if descriptors[i].wave != descriptors[j].wave {
+			return descriptors[i].wave < descriptors[j].wave
+		}
+		return kindPriority(descriptors[i].kind) < kindPriority(descriptors[j].kind)
+	})
+}
+
+// splitWaves groups sorted descriptors by sync wave
+func splitWaves(descriptors []descriptor) [][]descriptor {
+	waves := [][]descriptor{}
+	for i, d := range descriptors {
+		if i == 0 || d.wave != descriptors[i-1].wave {
+			waves = append(waves, []descriptor{})
+		}
+		waves[len(waves)-1] = append(waves[len(waves)-1], d)
+	}
+	return waves
+}
+
+// waveReady returns true if every object in wave is healthy. Otherwise it records in instance.Status.CurrentWave
+// which object the wave is waiting for and whether it has been waiting for longer than the wave timeout. A timed out
+// wave is not skipped, the next waves stay blocked until the stuck object is healthy or its descriptor is fixed.
+func waveReady(r *ReconcileConfiguration, logger logr.Logger, instance *appv1alpha1.Configuration, wave []descriptor, rules healthRules) bool {
+	resources, health := assessHealth(r, instance.Namespace, wave, rules)
+	if health == appv1alpha1.HealthHealthy {
+		return true
+	}
+
+	status := instance.Status.CurrentWave
+	if status == nil || status.Wave != wave[0].wave {
+		status = &appv1alpha1.WaveStatus{Wave: wave[0].wave, StartTime: metav1.Now()}
+	}
+	timeout := int32(DEFAULT_WAVE_TIMEOUT_SECONDS)
+	if instance.Spec.WaveTimeoutSeconds != nil {
+		timeout = *instance.Spec.WaveTimeoutSeconds
+	}
+	status.BlockedBy = healthMessage(resources, health)
+	status.TimedOut = time.Since(status.StartTime.Time) > time.Duration(timeout)*time.Second
+	instance.Status.CurrentWave = status
+
+	logger.Info(fmt.Sprintf("Sync wave %d waiting for %s", status.Wave, status.BlockedBy))
+	return false
+}
+
+// crdNotEstablishedError is returned by applyDescriptors when a CustomResourceDefinition it applied is not
+// established yet, the descriptors after it may be custom resources of it
+type crdNotEstablishedError struct {
+	name string
+}
+
+func (e *crdNotEstablishedError) Error() string {
+	return "CustomResourceDefinition " + e.name + " " + CRD_NOT_ESTABLISHED
+}
+
+// crdsEstablished returns a crdNotEstablishedError for the first CustomResourceDefinition in names whose Established
+// condition is not True
+func crdsEstablished(r *ReconcileConfiguration, names []string) error {
+	for _, name := range names {
+		crd := &unstructured.Unstructured{}
+		crd.SetGroupVersionKind(schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1beta1", Kind: "CustomResourceDefinition"})
+		if err := r.client.Get(context.TODO(), types.NamespacedName{Name: name}, crd); err != nil {
+			if errors.IsNotFound(err) {
+				return &crdNotEstablishedError{name: name}
+			}
+			return err
+		}
+		established := false
+		conditions, _, _ := unstructured.NestedSlice(crd.Object, "status", "conditions")
+		for _, c := range conditions {
+			if condition, ok := c.(map[string]interface{}); ok && condition["type"] == "Established" && condition["status"] == "True" {
+				established = true
+			}
+		}
+		if !established {
+			return &crdNotEstablishedError{name: name}
+		}
+	}
+	return nil
+}
+
+// waitForCRD records in instance.Status.CurrentWave that wave is waiting for the CustomResourceDefinition name to be
+// established, the sync is requeued instead of waiting. It returns an error once the CustomResourceDefinition has not
+// been established for CRD_ESTABLISHED_TIMEOUT.
+func waitForCRD(logger logr.Logger, instance *appv1alpha1.Configuration, wave int, name string) error {
+	blockedBy := "CustomResourceDefinition/" + name + ": " + CRD_NOT_ESTABLISHED
+	status := instance.Status.CurrentWave
+	if status == nil || status.Wave != wave || status.BlockedBy != blockedBy {
+		status = &appv1alpha1.WaveStatus{Wave: wave, StartTime: metav1.Now(), BlockedBy: blockedBy}
+	}
+	instance.Status.CurrentWave = status
+	if time.Since(status.StartTime.Time) > CRD_ESTABLISHED_TIMEOUT {
+		status.TimedOut = true
+		return fmt.Errorf("CustomResourceDefinition %s not established after %s", name, CRD_ESTABLISHED_TIMEOUT)
+	}
+	logger.Info(fmt.Sprintf("Sync wave %d waiting for %s", wave, blockedBy))
+	return nil
+}
+
+// waveRequeueInterval returns when to check again the wave recorded in status
+func waveRequeueInterval(status *appv1alpha1.WaveStatus) time.Duration {
+	if strings.HasPrefix(status.BlockedBy, "CustomResourceDefinition/") && strings.HasSuffix(status.BlockedBy, CRD_NOT_ESTABLISHED) {
+		return CRD_POLL_INTERVAL
+	}
+	return HEALTH_REQUEUE_INTERVAL
+}
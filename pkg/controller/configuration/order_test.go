@@ -0,0 +1,190 @@
+package configuration
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	appv1alpha1 "github.com/cvicens/rocketeer-operator/pkg/apis/app/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+const crdDescriptor = `apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  names:
+    kind: Widget
+    plural: widgets
+  scope: Namespaced
+  version: v1
+`
+
+const widgetDescriptor = `apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: web
+`
+
+func TestApplyDescriptorsWaitsForCRDs(t *testing.T) {
+	c := newMemoryClient()
+	r := &ReconcileConfiguration{client: c}
+	instance := &appv1alpha1.Configuration{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "apps"}}
+	descriptors := []descriptor{mustDescriptor(t, widgetDescriptor), mustDescriptor(t, configMapDescriptor), mustDescriptor(t, crdDescriptor)}
+	sortDescriptors(descriptors)
+
+	apply := func() (int, error) {
+		return applyDescriptors(context.TODO(), r, logf.Log, instance, descriptors, nil, newSyncRun(), nil, newAppliedObjects(r, instance, descriptors))
+	}
+
+	processed, err := apply()
+	if _, ok := err.(*crdNotEstablishedError); !ok || processed != 1 {
+		t.Fatalf("CustomResourceDefinition not established: expected 1 processed and a crdNotEstablishedError, got %d %v", processed, err)
+	}
+
+	crd := &unstructured.Unstructured{}
+	crd.SetAPIVersion("apiextensions.k8s.io/v1beta1")
+	crd.SetKind("CustomResourceDefinition")
+	crd.SetName("widgets.example.com")
+	unstructured.SetNestedSlice(crd.Object, []interface{}{map[string]interface{}{"type": "Established", "status": "True"}}, "status", "conditions")
+	if err := c.Create(context.TODO(), crd); err != nil {
+		t.Fatal(err)
+	}
+
+	if processed, err := apply(); err != nil || processed != len(descriptors) {
+		t.Errorf("CustomResourceDefinition established: expected %d processed, got %d %v", len(descriptors), processed, err)
+	}
+}
+
+// names returns the kind/name of descriptors, prefixed by their wave
+func names(descriptors []descriptor) []string {
+	result := []string{}
+	for _, d := range descriptors {
+		result = append(result, strconv.Itoa(d.wave)+":"+d.String())
+	}
+	return result
+}
+
+func TestSortDescriptors(t *testing.T) {
+	descriptors := []descriptor{
+		{kind: "Route", name: "web"},
+		{kind: "Widget", name: "first"},
+		{kind: "Deployment", name: "web"},
+		{kind: "Job", name: "migrate", wave: -1},
+		{kind: "ConfigMap", name: "web"},
+		{kind: "Widget", name: "second"},
+		{kind: "Deployment", name: "worker"},
+		{kind: "Service", name: "web", wave: 2},
+		{kind: "Namespace", name: "apps", wave: 2},
+		{kind: "CustomResourceDefinition", name: "widgets.example.com"},
+	}
+	sortDescriptors(descriptors)
+
+	expected := []string{
+		"-1:Job/migrate",
+		"0:CustomResourceDefinition/widgets.example.com",
+		"0:ConfigMap/web",
+		"0:Deployment/web",
+		"0:Deployment/worker",
+		"0:Route/web",
+		"0:Widget/first",
+		"0:Widget/second",
+		"2:Namespace/apps",
+		"2:Service/web",
+	}
+	if sorted := names(descriptors); strings.Join(sorted, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected %v, got %v", expected, sorted)
+	}
+}
+
+func TestSplitWaves(t *testing.T) {
+	tests := []struct {
+		waves    []int
+		expected [][]string
+	}{
+		{expected: [][]string{}},
+		{waves: []int{0, 0}, expected: [][]string{{"0:ConfigMap/0", "0:ConfigMap/1"}}},
+		{waves: []int{-1, 0, 0, 3}, expected: [][]string{{"-1:ConfigMap/0"}, {"0:ConfigMap/1", "0:ConfigMap/2"}, {"3:ConfigMap/3"}}},
+	}
+
+	for _, test := range tests {
+		descriptors := []descriptor{}
+		for i, wave := range test.waves {
+			descriptors = append(descriptors, descriptor{kind: "ConfigMap", name: strconv.Itoa(i), wave: wave})
+		}
+		waves := splitWaves(descriptors)
+		if len(waves) != len(test.expected) {
+			t.Errorf("%v: expected %d waves, got %d", test.waves, len(test.expected), len(waves))
+			continue
+		}
+		for i, wave := range waves {
+			if split := names(wave); strings.Join(split, " ") != strings.Join(test.expected[i], " ") {
+				t.Errorf("%v: expected wave %v, got %v", test.waves, test.expected[i], split)
+			}
+		}
+	}
+}
+
+func TestWaveReady(t *testing.T) {
+	deployment := func(name string, available int32) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "apps"},
+			Spec:       appsv1.DeploymentSpec{Replicas: int32Ptr(1)},
+			Status:     appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: available},
+		}
+	}
+	r := &ReconcileConfiguration{client: newMemoryClient(deployment("ready", 1), deployment("stuck", 0))}
+	timeout := int32(60)
+	instance := &appv1alpha1.Configuration{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "apps"},
+		Spec:       appv1alpha1.ConfigurationSpec{WaveTimeoutSeconds: &timeout},
+	}
+	ready := []descriptor{{kind: "ConfigMap", name: "web", wave: 1}, {kind: "Deployment", name: "ready", wave: 1}}
+	stuck := []descriptor{{kind: "Deployment", name: "ready", wave: 1}, {kind: "Deployment", name: "stuck", wave: 1}}
+
+	if !waveReady(r, logf.Log, instance, ready, nil) || instance.Status.CurrentWave != nil {
+		t.Fatalf("healthy wave: expected ready, got %+v", instance.Status.CurrentWave)
+	}
+
+	if waveReady(r, logf.Log, instance, stuck, nil) {
+		t.Fatal("unhealthy wave: expected not ready")
+	}
+	status := instance.Status.CurrentWave
+	if status == nil || status.Wave != 1 || !strings.HasPrefix(status.BlockedBy, "Deployment/stuck: ") || status.TimedOut {
+		t.Fatalf("unhealthy wave: unexpected status %+v", status)
+	}
+
+	// Waiting for longer than the timeout marks the wave as timed out, it still blocks the next waves
+	started := metav1.NewTime(time.Now().Add(-2 * time.Minute))
+	status.StartTime = started
+	if waveReady(r, logf.Log, instance, stuck, nil) {
+		t.Fatal("timed out wave: expected not ready")
+	}
+	status = instance.Status.CurrentWave
+	if !status.TimedOut || !status.StartTime.Equal(&started) {
+		t.Fatalf("timed out wave: unexpected status %+v", status)
+	}
+
+	// Another wave restarts the timeout
+	next := []descriptor{{kind: "Deployment", name: "stuck", wave: 2}}
+	if waveReady(r, logf.Log, instance, next, nil) {
+		t.Fatal("next wave: expected not ready")
+	}
+	if status = instance.Status.CurrentWave; status.Wave != 2 || status.TimedOut || status.StartTime.Equal(&started) {
+		t.Fatalf("next wave: unexpected status %+v", status)
+	}
+
+	// The timed out wave is ready once the stuck object is healthy
+	instance.Status.CurrentWave = &appv1alpha1.WaveStatus{Wave: 1, StartTime: started, TimedOut: true}
+	r.client.Update(context.TODO(), deployment("stuck", 1))
+	if !waveReady(r, logf.Log, instance, stuck, nil) {
+		t.Errorf("recovered wave: expected ready, got %+v", instance.Status.CurrentWave)
+	}
+}
@@ -0,0 +1,204 @@
+package configuration
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	appv1alpha1 "github.com/cvicens/rocketeer-operator/pkg/apis/app/v1alpha1"
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	k8s_yaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+const BASE_FOLDER = "base"
+const OVERLAYS_FOLDER = "overlays"
+
+// descriptor is a single object read from the descriptors folder
+type descriptor struct {
+	file       string
+	apiVersion string
+	kind       string
+	name       string
+	wave       int
+	buffer     []byte
+	options    syncOptions
+}
+
+func (d descriptor) String() string {
+	return d.kind + "/" + d.name
+}
+
+// loadDescriptors returns the descriptors to apply from folder. If folder contains a base/ folder the overlay
+// selected by spec.Environment is strategic-merge patched onto it, otherwise every file in folder is returned as is.
+// Encrypted files, with sops or as SealedSecrets, are decrypted in memory.
+func loadDescriptors(logger logr.Logger, folder string, spec appv1alpha1.ConfigurationSpec, decrypter *decrypter) ([]descriptor, []appv1alpha1.OverlayStatus, error) {
+	environment := spec.Environment
+	baseFolder := filepath.Join(folder, BASE_FOLDER)
+	if info, err := os.Stat(baseFolder); err != nil || !info.IsDir() {
+		if len(environment) > 0 {
+			logger.Info("No " + BASE_FOLDER + " folder found, ignoring environment " + environment)
+		}
+		descriptors, err := readDescriptorsInFolder(logger, folder, spec, decrypter)
+		return descriptors, nil, err
+	}
+
+	base, err := readDescriptorsInFolder(logger, baseFolder, spec, decrypter)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(environment) == 0 {
+		return base, nil, nil
+	}
+
+	overlayFolder := filepath.Join(folder, OVERLAYS_FOLDER, environment)
+	if info, err := os.Stat(overlayFolder); err != nil || !info.IsDir() {
+		return nil, nil, fmt.Errorf("overlay folder %s not found for environment %s", overlayFolder, environment)
+	}
+	overlay, err := readDescriptorsInFolder(logger, overlayFolder, spec, decrypter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return applyOverlay(base, overlay)
+}
+
+// readDescriptorsInFolder reads every file in folder, sub folders are ignored. Files ending in .jsonnet are
+// evaluated and may emit several descriptors. Evaluation errors and invalid sync annotations are returned, files
+// that are not descriptors are skipped.
+func readDescriptorsInFolder(logger logr.Logger, folder string, spec appv1alpha1.ConfigurationSpec, decrypter *decrypter) ([]descriptor, error) {
+	files, err := ioutil.ReadDir(folder)
+	if err != nil {
+		return nil, err
+	}
+
+	descriptors := []descriptor{}
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		if strings.HasSuffix(f.Name(), JSONNET_EXTENSION) {
+			// Skipping a file that does not evaluate would silently drop its objects
+			rendered, err := readJsonnetDescriptors(GIT_LOCAL_FOLDER, filepath.Join(folder, f.Name()), spec.Jsonnet)
+			if err != nil {
+				return nil, fmt.Errorf("jsonnet %s: %v", f.Name(), err)
+			}
+			descriptors = append(descriptors, rendered...)
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(folder, f.Name()))
+		if err != nil {
+			logger.Info("ReadFile error: " + err.Error())
+			continue
+		}
+		if b, err = decrypter.decrypt(b); err != nil {
+			logger.Info("Decrypt descriptor " + f.Name() + " error: " + err.Error())
+			continue
+		}
+		d, err := newDescriptor(f.Name(), b)
+		if _, invalid := err.(*annotationError); invalid {
+			// Dropping the object would apply the rest without it
+			return nil, err
+		}
+		if err != nil {
+			logger.Info("Unmarshall descriptor " + f.Name() + " error: " + err.Error())
+			continue
+		}
+		descriptors = append(descriptors, d)
+	}
+
+	return descriptors, nil
+}
+
+// annotationError is an invalid sync annotation of a descriptor, it fails the sync
+type annotationError struct {
+	file string
+	err  error
+}
+
+func (e *annotationError) Error() string {
+	return e.file + ": " + e.err.Error()
+}
+
+// newDescriptor parses buffer, an *annotationError is returned for invalid sync annotations
+func newDescriptor(file string, buffer []byte) (descriptor, error) {
+	jsonBytes, err := k8s_yaml.ToJSON(buffer)
+	if err != nil {
+		return descriptor{}, err
+	}
+	object := &unstructured.Unstructured{}
+	if err := object.UnmarshalJSON(jsonBytes); err != nil {
+		return descriptor{}, err
+	}
+
+	wave, err := syncWave(object.GetAnnotations())
+	if err != nil {
+		return descriptor{}, &annotationError{file: file, err: err}
+	}
+	options, err := parseSyncOptions(object.GetAnnotations())
+	if err != nil {
+		return descriptor{}, &annotationError{file: file, err: err}
+	}
+
+	return descriptor{file: file, apiVersion: object.GetAPIVersion(), kind: object.GetKind(), name: object.GetName(), wave: wave, buffer: buffer, options: options}, nil
+}
+
+// applyOverlay patches each overlay object onto the base object with the same kind and name. Overlay objects
+// without a counterpart in base are added as they are.
+func applyOverlay(base, overlay []descriptor) ([]descriptor, []appv1alpha1.OverlayStatus, error) {
+	result := append([]descriptor{}, base...)
+	touched := map[string][]string{}
+	files := []string{}
+
+	for _, o := range overlay {
+		if _, ok := touched[o.file]; !ok {
+			files = append(files, o.file)
+		}
+
+		found := false
+		for i, b := range result {
+			if b.kind == o.kind && b.name == o.name {
+				merged, err := mergeOverlay(b.buffer, o.buffer, o.kind)
+				if err == nil {
+					result[i], err = newDescriptor(b.file, merged)
+				}
+				if err != nil {
+					return nil, nil, fmt.Errorf("could not apply overlay %s to %s: %v", o.file, b.String(), err)
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, o)
+		}
+		touched[o.file] = append(touched[o.file], o.String())
+	}
+
+	status := []appv1alpha1.OverlayStatus{}
+	for _, file := range files {
+		status = append(status, appv1alpha1.OverlayStatus{File: file, Objects: touched[file]})
+	}
+
+	return result, status, nil
+}
+
+// mergeOverlay strategic-merge patches overlay onto base, kinds without a known type are JSON merge patched
+func mergeOverlay(base, overlay []byte, kind string) ([]byte, error) {
+	baseBytes, err := k8s_yaml.ToJSON(base)
+	if err != nil {
+		return nil, err
+	}
+	overlayBytes, err := k8s_yaml.ToJSON(overlay)
+	if err != nil {
+		return nil, err
+	}
+
+	if dataStruct := newObjectForKind(kind); dataStruct != nil {
+		return strategicMergePatchBytes(baseBytes, overlayBytes, dataStruct)
+	}
+	return jsonpatch.MergePatch(baseBytes, overlayBytes)
+}
@@ -0,0 +1,120 @@
+package configuration
+
+import (
+	"github.com/go-logr/logr"
+	routev1 "github.com/openshift/api/route/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Labels the Job controller adds to the pod template of Jobs without a manual selector
+var jobSelectorLabels = []string{"controller-uid", "job-name"}
+
+// preserveFields carries over from live the fields of desired assigned by the API server, or immutable once set,
+// that desired leaves empty, so that updating with desired does not clear or reject them
+func preserveFields(r *ReconcileConfiguration, logger logr.Logger, live, desired runtime.Object) {
+	u, ok := desired.(*unstructured.Unstructured)
+	if !ok {
+		preserveTypedFields(live, desired)
+		return
+	}
+
+	typedLive, err := r.scheme.New(u.GroupVersionKind())
+	if err != nil || !hasPreservedFields(typedLive) {
+		return
+	}
+	typedDesired := typedLive.DeepCopyObject()
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(live.(*unstructured.Unstructured).Object, typedLive); err != nil {
+		logger.Info("Preserve fields err: " + err.Error())
+		return
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, typedDesired); err != nil {
+		logger.Info("Preserve fields err: " + err.Error())
+		return
+	}
+	preserveTypedFields(typedLive, typedDesired)
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(typedDesired)
+	if err != nil {
+		logger.Info("Preserve fields err: " + err.Error())
+		return
+	}
+	u.Object = object
+}
+
+// hasPreservedFields reports whether obj is of a kind with fields to preserve
+func hasPreservedFields(obj runtime.Object) bool {
+	switch obj.(type) {
+	case *corev1.Service, *routev1.Route, *corev1.PersistentVolumeClaim, *batchv1.Job:
+		return true
+	}
+	return false
+}
+
+func preserveTypedFields(live, desired runtime.Object) {
+	switch desired := desired.(type) {
+	case *corev1.Service:
+		preserveServiceFields(live.(*corev1.Service), desired)
+	case *routev1.Route:
+		if desired.Spec.Host == "" {
+			desired.Spec.Host = live.(*routev1.Route).Spec.Host
+		}
+	case *corev1.PersistentVolumeClaim:
+		if desired.Spec.VolumeName == "" {
+			desired.Spec.VolumeName = live.(*corev1.PersistentVolumeClaim).Spec.VolumeName
+		}
+	case *batchv1.Job:
+		preserveJobFields(live.(*batchv1.Job), desired)
+	}
+}
+
+func preserveServiceFields(live, desired *corev1.Service) {
+	if desired.Spec.ClusterIP == "" {
+		desired.Spec.ClusterIP = live.Spec.ClusterIP
+	}
+	if desired.Spec.HealthCheckNodePort == 0 {
+		desired.Spec.HealthCheckNodePort = live.Spec.HealthCheckNodePort
+	}
+	for i := range desired.Spec.Ports {
+		port := &desired.Spec.Ports[i]
+		if port.NodePort != 0 {
+			continue
+		}
+		for _, livePort := range live.Spec.Ports {
+			if livePort.Port == port.Port && protocolOrTCP(livePort.Protocol) == protocolOrTCP(port.Protocol) {
+				port.NodePort = livePort.NodePort
+				break
+			}
+		}
+	}
+}
+
+func protocolOrTCP(protocol corev1.Protocol) corev1.Protocol {
+	if protocol == "" {
+		return corev1.ProtocolTCP
+	}
+	return protocol
+}
+
+func preserveJobFields(live, desired *batchv1.Job) {
+	if desired.Spec.Selector != nil || live.Spec.Selector == nil {
+		return
+	}
+	desired.Spec.Selector = live.Spec.Selector.DeepCopy()
+	if desired.Spec.ManualSelector == nil {
+		desired.Spec.ManualSelector = live.Spec.ManualSelector
+	}
+	for _, label := range jobSelectorLabels {
+		value, ok := live.Spec.Template.Labels[label]
+		if !ok {
+			continue
+		}
+		if desired.Spec.Template.Labels == nil {
+			desired.Spec.Template.Labels = map[string]string{}
+		}
+		if _, ok := desired.Spec.Template.Labels[label]; !ok {
+			desired.Spec.Template.Labels[label] = value
+		}
+	}
+}
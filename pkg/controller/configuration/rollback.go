@@ -0,0 +1,111 @@
+package configuration
+
+import (
+	"fmt"
+	"time"
+
+	appv1alpha1 "github.com/cvicens/rocketeer-operator/pkg/apis/app/v1alpha1"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	git "gopkg.in/src-d/go-git.v4"
+	plumbing "gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+const DEFAULT_REVISION_HISTORY_LIMIT = 10
+
+// rollbackKinds are the kinds whose degradation triggers a rollback
+var rollbackKinds = map[string]bool{"Deployment": true, "DeploymentConfig": true}
+
+// syncRevision returns the revision to apply: head, unless head is the revision that has been rolled back, in which
+// case auto-sync stays paused on the healthy revision until a newer commit arrives
+func syncRevision(logger logr.Logger, instance *appv1alpha1.Configuration, head string) string {
+	rolledBack := instance.Status.RolledBack
+	if rolledBack == nil {
+		return head
+	}
+	if rolledBack.BadRevision == head {
+		logger.Info("Revision " + head + " is rolled back, applying " + rolledBack.Revision)
+		return rolledBack.Revision
+	}
+
+	logger.Info("New revision " + head + ", resuming auto-sync")
+	instance.Status.RolledBack = nil
+	setCondition(&instance.Status, appv1alpha1.ConfigurationRolledBack, corev1.ConditionFalse, "NewRevision", "")
+	return head
+}
+
+// checkoutRevision checks out revision in the work tree of repo, the returned function checks ref out again
+func checkoutRevision(repo *git.Repository, revision string, ref string) (func() error, error) {
+	w, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	if err := w.Checkout(&git.CheckoutOptions{Hash: plumbing.NewHash(revision), Force: true}); err != nil {
+		return nil, err
+	}
+	return func() error {
+		return w.Checkout(&git.CheckoutOptions{Branch: plumbing.ReferenceName("refs/heads/" + ref), Force: true})
+	}, nil
+}
+
+// recordRevision adds revision to the revision history when it has just been applied and marks it healthy once it is
+func recordRevision(instance *appv1alpha1.Configuration, revision string, health appv1alpha1.HealthStatus) {
+	status := &instance.Status
+	status.Revision = revision
+	if n := len(status.History); n == 0 || status.History[n-1].SHA != revision {
+		status.History = append(status.History, appv1alpha1.RevisionHistory{SHA: revision, AppliedAt: metav1.Now()})
+	}
+	if health == appv1alpha1.HealthHealthy {
+		status.History[len(status.History)-1].Healthy = true
+	}
+
+	limit := DEFAULT_REVISION_HISTORY_LIMIT
+	if instance.Spec.RevisionHistoryLimit != nil {
+		limit = int(*instance.Spec.RevisionHistoryLimit)
+	}
+	if limit > 0 && len(status.History) > limit {
+		status.History = status.History[len(status.History)-limit:]
+	}
+}
+
+// rollbackRevision returns the last healthy revision to roll back to when the revision just applied has made a
+// Deployment or DeploymentConfig Degraded within the rollback window
+func rollbackRevision(instance *appv1alpha1.Configuration, resources []appv1alpha1.ResourceHealth) (string, bool) {
+	window := instance.Spec.RollbackWindowSeconds
+	history := instance.Status.History
+	if window == nil || instance.Status.RolledBack != nil || len(history) < 2 {
+		return "", false
+	}
+	current := history[len(history)-1]
+	if time.Since(current.AppliedAt.Time) > time.Duration(*window)*time.Second {
+		return "", false
+	}
+
+	degraded := false
+	for _, resource := range resources {
+		if rollbackKinds[resource.Kind] && resource.Health == appv1alpha1.HealthDegraded {
+			degraded = true
+		}
+	}
+	if !degraded {
+		return "", false
+	}
+
+	for i := len(history) - 2; i >= 0; i-- {
+		if history[i].Healthy && history[i].SHA != current.SHA {
+			return history[i].SHA, true
+		}
+	}
+	return "", false
+}
+
+// rollBack marks instance as rolled back from badRevision to revision
+func rollBack(r *ReconcileConfiguration, logger logr.Logger, instance *appv1alpha1.Configuration, badRevision, revision string) {
+	message := fmt.Sprintf("revision %s is degraded, rolled back to %s", badRevision, revision)
+	logger.Info("Rolling back: " + message)
+	instance.Status.RolledBack = &appv1alpha1.RollbackStatus{BadRevision: badRevision, Revision: revision, Time: metav1.Now()}
+	setCondition(&instance.Status, appv1alpha1.ConfigurationRolledBack, corev1.ConditionTrue, "Degraded", message)
+	r.recorder.Event(instance, corev1.EventTypeWarning, "RolledBack", message)
+}
@@ -0,0 +1,338 @@
+package configuration
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	appv1alpha1 "github.com/cvicens/rocketeer-operator/pkg/apis/app/v1alpha1"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// RESTORE_SNAPSHOT_ANNOTATION is set on a Configuration to the key of a snapshot to restore, it is removed once the
+// snapshot has been restored
+const RESTORE_SNAPSHOT_ANNOTATION = "app.rocketeer.com/restore-snapshot"
+
+// Suffix of the name of the Secret or ConfigMap holding the snapshots of a Configuration
+const SNAPSHOT_STORAGE_SUFFIX = "-snapshots"
+
+const DEFAULT_SNAPSHOT_HISTORY_LIMIT = 20
+const DEFAULT_SNAPSHOT_MAX_BYTES = 512 * 1024
+
+// snapshotStore keeps the live manifests of the objects of a Configuration before they are overwritten, gzipped under
+// keys such as `1571476800000000000_deployment_web.json.gz` in a Secret or the binaryData of a ConfigMap
+type snapshotStore struct {
+	r        *ReconcileConfiguration
+	instance *appv1alpha1.Configuration
+	// storage is the Secret or ConfigMap once loaded, created is false until it exists
+	storage runtime.Object
+	data    map[string][]byte
+	created bool
+}
+
+func newSnapshotStore(r *ReconcileConfiguration, instance *appv1alpha1.Configuration) *snapshotStore {
+	return &snapshotStore{r: r, instance: instance}
+}
+
+func (s *snapshotStore) inSecret() bool {
+	return s.instance.Spec.SnapshotStorage != "ConfigMap"
+}
+
+// load reads the snapshots, from the API server rather than the cache as they are updated several times per sync
+func (s *snapshotStore) load() (map[string][]byte, error) {
+	if s.storage != nil {
+		return s.data, nil
+	}
+
+	var storage runtime.Object = &corev1.ConfigMap{}
+	kind := "ConfigMap"
+	if s.inSecret() {
+		storage = &corev1.Secret{}
+		kind = "Secret"
+	}
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion("v1")
+	u.SetKind(kind)
+	name := types.NamespacedName{Name: s.instance.Name + SNAPSHOT_STORAGE_SUFFIX, Namespace: s.instance.Namespace}
+	err := s.r.client.Get(context.TODO(), name, u)
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, storage); err != nil {
+			return nil, err
+		}
+		s.created = true
+	} else {
+		accessor, _ := meta.Accessor(storage)
+		accessor.SetName(name.Name)
+		accessor.SetNamespace(name.Namespace)
+		if err := controllerutil.SetControllerReference(s.instance, accessor, s.r.scheme); err != nil {
+			return nil, err
+		}
+	}
+
+	switch storage := storage.(type) {
+	case *corev1.Secret:
+		if storage.Data == nil {
+			storage.Data = map[string][]byte{}
+		}
+		s.data = storage.Data
+	case *corev1.ConfigMap:
+		if storage.BinaryData == nil {
+			storage.BinaryData = map[string][]byte{}
+		}
+		s.data = storage.BinaryData
+	}
+	s.storage = storage
+	return s.data, nil
+}
+
+// persist writes the snapshots back
+func (s *snapshotStore) persist() error {
+	if s.created {
+		return s.r.client.Update(context.TODO(), s.storage)
+	}
+	if err := s.r.client.Create(context.TODO(), s.storage); err != nil {
+		return err
+	}
+	s.created = true
+	return nil
+}
+
+// save snapshots live before it is updated or deleted, unless it is identical to the last snapshot of the object.
+// Errors are logged and do not stop the sync.
+func (s *snapshotStore) save(logger logr.Logger, live runtime.Object) {
+	if s == nil {
+		return
+	}
+	if err := s.saveObject(live); err != nil {
+		logger.Info("Snapshot err: " + err.Error())
+	}
+}
+
+func (s *snapshotStore) saveObject(live runtime.Object) error {
+	u, err := toManifest(s.r, live)
+	if err != nil {
+		return err
+	}
+	if u.GetKind() == "Secret" && !s.inSecret() {
+		return fmt.Errorf("Secret %s not snapshotted into a ConfigMap", u.GetName())
+	}
+	jsonBytes, err := u.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	compressed, err := compress(jsonBytes)
+	if err != nil {
+		return err
+	}
+
+	data, err := s.load()
+	if err != nil {
+		return err
+	}
+	suffix := snapshotSuffix(u.GetKind(), u.GetName())
+	keys := sortedKeys(data)
+	for i := len(keys) - 1; i >= 0; i-- {
+		if strings.HasSuffix(keys[i], suffix) {
+			if bytes.Equal(data[keys[i]], compressed) {
+				return nil
+			}
+			break
+		}
+	}
+
+	data[fmt.Sprintf("%d", time.Now().UnixNano())+suffix] = compressed
+	s.prune()
+	return s.persist()
+}
+
+// prune drops the oldest snapshots beyond the history limit or the size limit, the newest one is always kept
+func (s *snapshotStore) prune() {
+	limit := DEFAULT_SNAPSHOT_HISTORY_LIMIT
+	if s.instance.Spec.SnapshotHistoryLimit != nil {
+		limit = int(*s.instance.Spec.SnapshotHistoryLimit)
+	}
+	maxBytes := DEFAULT_SNAPSHOT_MAX_BYTES
+	if s.instance.Spec.SnapshotMaxBytes != nil {
+		maxBytes = int(*s.instance.Spec.SnapshotMaxBytes)
+	}
+
+	keys := sortedKeys(s.data)
+	size := 0
+	for _, key := range keys {
+		size += len(s.data[key])
+	}
+	for len(keys) > 1 && (len(keys) > limit || size > maxBytes) {
+		size -= len(s.data[keys[0]])
+		delete(s.data, keys[0])
+		keys = keys[1:]
+	}
+}
+
+// restoreSnapshot re-applies the snapshot named by the restore annotation of instance and records the object in
+// instance.Status.Restored so that syncs leave it as restored until its descriptor changes
+func restoreSnapshot(r *ReconcileConfiguration, logger logr.Logger, instance *appv1alpha1.Configuration, snapshots *snapshotStore) error {
+	key, ok := instance.Annotations[RESTORE_SNAPSHOT_ANNOTATION]
+	if !ok {
+		return nil
+	}
+	logger.Info("Restoring snapshot " + key)
+
+	data, err := snapshots.load()
+	if err != nil {
+		return err
+	}
+	var restored *unstructured.Unstructured
+	if compressed, ok := data[key]; !ok {
+		err = fmt.Errorf("snapshot %s not found", key)
+	} else {
+		restored, err = fromSnapshot(compressed)
+	}
+	if err == nil {
+		err = applySnapshot(r, logger, instance.Namespace, restored, snapshots)
+		if errors.IsConflict(err) || errors.IsServerTimeout(err) || errors.IsTimeout(err) || errors.IsTooManyRequests(err) {
+			return err
+		}
+	}
+
+	delete(instance.Annotations, RESTORE_SNAPSHOT_ANNOTATION)
+	if updateErr := r.client.Update(context.TODO(), instance); updateErr != nil {
+		return updateErr
+	}
+	if err != nil {
+		logger.Info("Restore snapshot err: " + err.Error())
+		r.recorder.Event(instance, corev1.EventTypeWarning, "RestoreFailed", fmt.Sprintf("snapshot %s not restored: %v", key, err))
+		return nil
+	}
+
+	// Status is set after the annotation is removed as the update overwrites it with the stored one
+	instance.Status.Restored = append(removeRestored(instance.Status.Restored, restored.GetKind(), restored.GetName()),
+		appv1alpha1.RestoredObject{Snapshot: key, Kind: restored.GetKind(), Name: restored.GetName(), Time: metav1.Now()})
+	r.recorder.Event(instance, corev1.EventTypeNormal, "Restored", fmt.Sprintf("Restored %s %s from snapshot %s", restored.GetKind(), restored.GetName(), key))
+	return nil
+}
+
+// applySnapshot creates the object in the snapshot or updates the live one with it, snapshotting the live one first
+func applySnapshot(r *ReconcileConfiguration, logger logr.Logger, namespace string, restored *unstructured.Unstructured, snapshots *snapshotStore) error {
+	restored.SetNamespace(namespace)
+	live := &unstructured.Unstructured{}
+	live.SetGroupVersionKind(restored.GroupVersionKind())
+	err := r.client.Get(context.TODO(), types.NamespacedName{Name: restored.GetName(), Namespace: namespace}, live)
+	if errors.IsNotFound(err) {
+		restored.SetResourceVersion("")
+		return r.client.Create(context.TODO(), restored)
+	}
+	if err != nil {
+		return err
+	}
+	snapshots.save(logger, live)
+	restored.SetResourceVersion(live.GetResourceVersion())
+	return r.client.Update(context.TODO(), restored)
+}
+
+// restoredObject returns the entry of instance.Status.Restored for the object described by d, nil if it has not been
+// restored
+func restoredObject(instance *appv1alpha1.Configuration, d descriptor) *appv1alpha1.RestoredObject {
+	for i := range instance.Status.Restored {
+		if restored := &instance.Status.Restored[i]; restored.Kind == d.kind && restored.Name == d.name {
+			return restored
+		}
+	}
+	return nil
+}
+
+func removeRestored(restored []appv1alpha1.RestoredObject, kind, name string) []appv1alpha1.RestoredObject {
+	kept := []appv1alpha1.RestoredObject{}
+	for _, object := range restored {
+		if object.Kind != kind || object.Name != name {
+			kept = append(kept, object)
+		}
+	}
+	return kept
+}
+
+// toManifest returns obj as an unstructured object with its apiVersion and kind, which typed objects read from the
+// cache lack
+func toManifest(r *ReconcileConfiguration, obj runtime.Object) (*unstructured.Unstructured, error) {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		return u, nil
+	}
+	gvk, err := apiutil.GVKForObject(obj, r.scheme)
+	if err != nil {
+		return nil, err
+	}
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{Object: object}
+	u.SetGroupVersionKind(gvk)
+	return u, nil
+}
+
+// fromSnapshot returns the object in a snapshot without the metadata assigned by the API server
+func fromSnapshot(compressed []byte) (*unstructured.Unstructured, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	jsonBytes, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{}
+	if err := u.UnmarshalJSON(jsonBytes); err != nil {
+		return nil, err
+	}
+	u.SetUID("")
+	u.SetSelfLink("")
+	u.SetCreationTimestamp(metav1.Time{})
+	u.SetGeneration(0)
+	unstructured.RemoveNestedField(u.Object, "status")
+	return u, nil
+}
+
+func compress(data []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// snapshotSuffix is the part of the keys of the snapshots of an object after the timestamp, kinds and names cannot
+// contain underscores
+func snapshotSuffix(kind, name string) string {
+	return "_" + strings.ToLower(kind) + "_" + name + ".json.gz"
+}
+
+// sortedKeys returns the keys of data, the oldest snapshot first
+func sortedKeys(data map[string][]byte) []string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
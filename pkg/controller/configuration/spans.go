@@ -0,0 +1,50 @@
+package configuration
+
+import (
+	"context"
+
+	appv1alpha1 "github.com/cvicens/rocketeer-operator/pkg/apis/app/v1alpha1"
+	objectmatch "github.com/cvicens/rocketeer-operator/pkg/objectmatcher"
+	"github.com/cvicens/rocketeer-operator/pkg/tracing"
+	"go.opencensus.io/trace"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// The handlers read, compare and write objects through these functions so that each call is a span of the
+// object's span in ctx
+
+func getObject(ctx context.Context, r *ReconcileConfiguration, key types.NamespacedName, obj runtime.Object) error {
+	ctx, span := trace.StartSpan(ctx, "get")
+	err := r.client.Get(ctx, key, obj)
+	tracing.End(span, err)
+	return err
+}
+
+func diffObjects(ctx context.Context, live, desired runtime.Object) (*objectmatch.MatchResult, error) {
+	_, span := trace.StartSpan(ctx, "diff")
+	result, err := objectMatcher.Diff(live, desired)
+	tracing.End(span, err)
+	return result, err
+}
+
+func updateObject(ctx context.Context, r *ReconcileConfiguration, obj runtime.Object) error {
+	ctx, span := trace.StartSpan(ctx, "patch")
+	err := r.client.Update(ctx, obj)
+	tracing.End(span, err)
+	return err
+}
+
+func createObject(ctx context.Context, r *ReconcileConfiguration, obj runtime.Object) error {
+	ctx, span := trace.StartSpan(ctx, "create")
+	err := r.client.Create(ctx, obj)
+	tracing.End(span, err)
+	return err
+}
+
+func updateStatus(ctx context.Context, r *ReconcileConfiguration, instance *appv1alpha1.Configuration) error {
+	ctx, span := trace.StartSpan(ctx, "status update")
+	err := r.client.Status().Update(ctx, instance)
+	tracing.End(span, err)
+	return err
+}
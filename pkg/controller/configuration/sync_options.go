@@ -0,0 +1,121 @@
+package configuration
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	k8s_yaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// SYNC_OPTIONS_ANNOTATION holds comma separated options such as `Replace,Prune=false`, it is removed before the
+// object is sent to the API server
+const SYNC_OPTIONS_ANNOTATION = "app.rocketeer.com/sync-options"
+
+const REPLACE_POLL_INTERVAL = 1 * time.Second
+const REPLACE_DELETE_TIMEOUT = 60 * time.Second
+
+// syncOptions change how a single object is applied
+type syncOptions struct {
+	// replace deletes and recreates the object when an update is rejected, e.g. because an immutable field changed
+	replace bool
+	// createOnly never updates the object once it exists
+	createOnly bool
+	// noPrune keeps the object when it is no longer in the repository. Nothing is pruned yet, the option is accepted
+	// and has no effect.
+	noPrune bool
+	// skipDryRun leaves the object out of the server-side dry run
+	skipDryRun bool
+}
+
+// parseSyncOptions parses the sync-options annotation. Boolean options may be given as `Option` or `Option=true`.
+// Unknown options and settings other than true or false are errors.
+func parseSyncOptions(annotations map[string]string) (syncOptions, error) {
+	options := syncOptions{}
+	value, ok := annotations[SYNC_OPTIONS_ANNOTATION]
+	if !ok {
+		return options, nil
+	}
+
+	for _, option := range strings.Split(value, ",") {
+		option = strings.TrimSpace(option)
+		if option == "" {
+			continue
+		}
+		name, setting := option, "true"
+		if i := strings.Index(option, "="); i >= 0 {
+			name, setting = strings.TrimSpace(option[:i]), strings.TrimSpace(option[i+1:])
+		}
+		if setting != "true" && setting != "false" {
+			return options, fmt.Errorf("invalid %s annotation: %q is neither true nor false", SYNC_OPTIONS_ANNOTATION, option)
+		}
+		enabled := setting == "true"
+		switch name {
+		case "Replace":
+			options.replace = enabled
+		case "CreateOnly":
+			options.createOnly = enabled
+		case "Prune":
+			options.noPrune = !enabled
+		case "SkipDryRun":
+			options.skipDryRun = enabled
+		default:
+			return options, fmt.Errorf("invalid %s annotation: unknown option %q", SYNC_OPTIONS_ANNOTATION, name)
+		}
+	}
+	return options, nil
+}
+
+// exists reports whether the object described by d is found in namespace
+func exists(r *ReconcileConfiguration, namespace string, d descriptor) bool {
+	_, err := getLiveObject(r, namespace, d)
+	return err == nil
+}
+
+// replaceObject snapshots and deletes the object described by d, waits for it to be gone and creates it again from
+// d.buffer
+func replaceObject(r *ReconcileConfiguration, logger logr.Logger, namespace string, d descriptor, snapshots *snapshotStore) error {
+	logger.Info("Replacing " + d.String())
+	jsonBytes, err := k8s_yaml.ToJSON(d.buffer)
+	if err != nil {
+		return err
+	}
+	desired := &unstructured.Unstructured{}
+	if err := desired.UnmarshalJSON(jsonBytes); err != nil {
+		return err
+	}
+	desired.SetNamespace(namespace)
+
+	live := &unstructured.Unstructured{}
+	live.SetGroupVersionKind(desired.GroupVersionKind())
+	live.SetNamespace(namespace)
+	live.SetName(desired.GetName())
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Name: live.GetName(), Namespace: namespace}, live); err == nil {
+		snapshots.save(logger, live)
+	}
+	if err := r.client.Delete(context.TODO(), live, client.PropagationPolicy(metav1.DeletePropagationForeground)); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+
+	err = wait.PollImmediate(REPLACE_POLL_INTERVAL, REPLACE_DELETE_TIMEOUT, func() (bool, error) {
+		// Unstructured objects are read from the API server rather than the cache
+		err := r.client.Get(context.TODO(), types.NamespacedName{Name: live.GetName(), Namespace: namespace}, live)
+		if errors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	})
+	if err != nil {
+		return fmt.Errorf("%s not deleted: %v", d.String(), err)
+	}
+
+	return r.client.Create(context.TODO(), desired)
+}
@@ -0,0 +1,103 @@
+package configuration
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	appv1alpha1 "github.com/cvicens/rocketeer-operator/pkg/apis/app/v1alpha1"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+func TestParseSyncOptions(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected syncOptions
+		valid    bool
+	}{
+		{value: "", valid: true},
+		{value: "Replace", expected: syncOptions{replace: true}, valid: true},
+		{value: "Replace=true", expected: syncOptions{replace: true}, valid: true},
+		{value: "Replace=false", valid: true},
+		{value: " CreateOnly , SkipDryRun=true ", expected: syncOptions{createOnly: true, skipDryRun: true}, valid: true},
+		{value: "Prune", valid: true},
+		{value: "Prune=false", expected: syncOptions{noPrune: true}, valid: true},
+		{value: "Replace,,Prune=false", expected: syncOptions{replace: true, noPrune: true}, valid: true},
+		{value: "Recreate"},
+		{value: "replace"},
+		{value: "Replace=yes"},
+		{value: "Replace="},
+		{value: "Replace,Prune=0"},
+	}
+
+	for _, test := range tests {
+		options, err := parseSyncOptions(map[string]string{SYNC_OPTIONS_ANNOTATION: test.value})
+		if !test.valid {
+			if err == nil {
+				t.Errorf("%q: expected an error", test.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.value, err)
+			continue
+		}
+		if options != test.expected {
+			t.Errorf("%q: expected %+v, got %+v", test.value, test.expected, options)
+		}
+	}
+
+	if options, err := parseSyncOptions(nil); err != nil || options != (syncOptions{}) {
+		t.Errorf("no annotation: %+v %v", options, err)
+	}
+}
+
+func TestReadDescriptorsInvalidAnnotations(t *testing.T) {
+	const configMap = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+`
+	tests := []struct {
+		name        string
+		annotations string
+		valid       bool
+	}{
+		{name: "none", valid: true},
+		{name: "Prune=false", annotations: "    " + SYNC_OPTIONS_ANNOTATION + ": Prune=false\n", valid: true},
+		{name: "unknown option", annotations: "    " + SYNC_OPTIONS_ANNOTATION + ": Recreate\n"},
+		{name: "bad option value", annotations: "    " + SYNC_OPTIONS_ANNOTATION + ": Replace=yes\n"},
+		{name: "bad wave", annotations: "    " + SYNC_WAVE_ANNOTATION + ": first\n"},
+	}
+
+	for _, test := range tests {
+		folder, err := ioutil.TempDir("", "descriptors")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(folder)
+		descriptor := configMap
+		if len(test.annotations) > 0 {
+			descriptor += "  annotations:\n" + test.annotations
+		}
+		writeFiles(t, folder, map[string]string{
+			"configmap.yaml": descriptor,
+			"README.md":      "# Not a descriptor",
+		})
+
+		descriptors, err := readDescriptorsInFolder(logf.Log, folder, appv1alpha1.ConfigurationSpec{}, &decrypter{})
+		if !test.valid {
+			if err == nil {
+				t.Errorf("%s: expected an error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if len(descriptors) != 1 || descriptors[0].name != "web" {
+			t.Errorf("%s: unexpected descriptors %v", test.name, descriptors)
+		}
+	}
+}
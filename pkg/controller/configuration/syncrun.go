@@ -0,0 +1,248 @@
+package configuration
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	appv1alpha1 "github.com/cvicens/rocketeer-operator/pkg/apis/app/v1alpha1"
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	git "gopkg.in/src-d/go-git.v4"
+	plumbing "gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+)
+
+// SYNC_TRIGGER_ANNOTATION is set on a Configuration to `webhook` or `manual` to record what triggered the next sync,
+// it is removed once the sync has been recorded
+const SYNC_TRIGGER_ANNOTATION = "app.rocketeer.com/sync-trigger"
+
+// CONFIGURATION_LABEL holds the name of the Configuration a SyncRun or an applied object belongs to
+const CONFIGURATION_LABEL = "app.rocketeer.com/configuration"
+
+const DEFAULT_SYNC_RUN_HISTORY_LIMIT = 10
+
+// Most commits listed in a SyncRun
+const SYNC_RUN_MAX_COMMITS = 50
+
+// syncRun collects what a sync does to each object
+type syncRun struct {
+	start   time.Time
+	objects []appv1alpha1.SyncRunObject
+}
+
+func newSyncRun() *syncRun {
+	return &syncRun{start: time.Now()}
+}
+
+func (run *syncRun) record(d descriptor, action appv1alpha1.SyncAction, err error) {
+	object := appv1alpha1.SyncRunObject{Kind: d.kind, Name: d.name, Action: action}
+	if err != nil {
+		object.Error = err.Error()
+	}
+	run.objects = append(run.objects, object)
+}
+
+// changed reports whether any object has been written or has failed
+func (run *syncRun) changed() bool {
+	for _, object := range run.objects {
+		if object.Action != appv1alpha1.SyncActionUnchanged && object.Action != appv1alpha1.SyncActionSkipped {
+			return true
+		}
+	}
+	return false
+}
+
+func (run *syncRun) failed() bool {
+	for _, object := range run.objects {
+		if object.Action == appv1alpha1.SyncActionFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// syncTrigger returns what triggered the sync of instance from revision from to revision to
+func syncTrigger(instance *appv1alpha1.Configuration, from, to string, run *syncRun) appv1alpha1.SyncTrigger {
+	switch trigger := appv1alpha1.SyncTrigger(instance.Annotations[SYNC_TRIGGER_ANNOTATION]); trigger {
+	case appv1alpha1.SyncTriggerWebhook, appv1alpha1.SyncTriggerManual:
+		return trigger
+	}
+	if from == to && run.changed() {
+		return appv1alpha1.SyncTriggerDrift
+	}
+	return appv1alpha1.SyncTriggerPoll
+}
+
+// recordSyncRun creates a SyncRun for the sync of instance from revision from to revision to and deletes the oldest
+// SyncRuns beyond the history limit. Consecutive NoChanges syncs, such as the requeues of a synced Configuration, are
+// counted in a single SyncRun.
+func recordSyncRun(r *ReconcileConfiguration, logger logr.Logger, instance *appv1alpha1.Configuration, repo *git.Repository, run *syncRun, from, to string, syncErr error) {
+	trigger := syncTrigger(instance, from, to, run)
+	_, triggered := instance.Annotations[SYNC_TRIGGER_ANNOTATION]
+
+	end := time.Now()
+	syncRun := &appv1alpha1.SyncRun{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: instance.Name + "-",
+			Namespace:    instance.Namespace,
+			Labels:       map[string]string{CONFIGURATION_LABEL: instance.Name},
+		},
+		Spec: appv1alpha1.SyncRunSpec{
+			Configuration: instance.Name,
+			Trigger:       trigger,
+			FromRevision:  from,
+			ToRevision:    to,
+			Commits:       commitsBetween(logger, repo, from, to),
+		},
+		Status: appv1alpha1.SyncRunStatus{
+			Phase:          appv1alpha1.SyncRunSucceeded,
+			StartTime:      metav1.NewTime(run.start),
+			CompletionTime: metav1.NewTime(end),
+			Duration:       metav1.Duration{Duration: end.Sub(run.start)},
+			Objects:        run.objects,
+		},
+	}
+	if syncErr != nil {
+		syncRun.Status.Phase = appv1alpha1.SyncRunFailed
+		syncRun.Status.Error = syncErr.Error()
+	} else if run.failed() {
+		syncRun.Status.Phase = appv1alpha1.SyncRunFailed
+	} else if from == to && !run.changed() {
+		syncRun.Status.Phase = appv1alpha1.SyncRunNoChanges
+		syncRun.Status.Objects = nil
+		syncRun.Status.Count = 1
+	}
+
+	if syncRun.Status.Phase != appv1alpha1.SyncRunNoChanges || !countSyncRun(r, logger, instance, syncRun) {
+		if err := controllerutil.SetControllerReference(instance, syncRun, r.scheme); err != nil {
+			logger.Info("Create SyncRun err: " + err.Error())
+			return
+		}
+		if err := r.client.Create(context.TODO(), syncRun); err != nil {
+			logger.Info("Create SyncRun err: " + err.Error())
+			return
+		}
+		logger.Info("SyncRun " + syncRun.Name + " " + string(syncRun.Status.Phase))
+	}
+
+	if triggered {
+		delete(instance.Annotations, SYNC_TRIGGER_ANNOTATION)
+		if err := r.client.Update(context.TODO(), instance); err != nil {
+			logger.Info("Update Configuration err: " + err.Error())
+		}
+	}
+
+	pruneSyncRuns(r, logger, instance)
+}
+
+// countSyncRun adds the NoChanges syncRun to the last SyncRun of instance if that one is a NoChanges run of the same
+// revision and trigger. It returns false if syncRun has to be created.
+func countSyncRun(r *ReconcileConfiguration, logger logr.Logger, instance *appv1alpha1.Configuration, syncRun *appv1alpha1.SyncRun) bool {
+	syncRuns, err := listSyncRuns(r, instance)
+	if err != nil {
+		logger.Info("List SyncRuns err: " + err.Error())
+		return false
+	}
+	if len(syncRuns) == 0 {
+		return false
+	}
+	last := &syncRuns[len(syncRuns)-1]
+	if last.Status.Phase != appv1alpha1.SyncRunNoChanges || last.Spec.ToRevision != syncRun.Spec.ToRevision || last.Spec.Trigger != syncRun.Spec.Trigger {
+		return false
+	}
+
+	if last.Status.Count == 0 {
+		last.Status.Count = 1
+	}
+	last.Status.Count++
+	last.Status.CompletionTime = syncRun.Status.CompletionTime
+	last.Status.Duration = syncRun.Status.Duration
+	if err := r.client.Update(context.TODO(), last); err != nil {
+		logger.Info("Update SyncRun err: " + err.Error())
+		return false
+	}
+	logger.Info(fmt.Sprintf("SyncRun %s %s %d times", last.Name, last.Status.Phase, last.Status.Count))
+	return true
+}
+
+// listSyncRuns returns the SyncRuns of instance, the oldest first
+func listSyncRuns(r *ReconcileConfiguration, instance *appv1alpha1.Configuration) ([]appv1alpha1.SyncRun, error) {
+	syncRuns := &appv1alpha1.SyncRunList{}
+	opts := (&client.ListOptions{}).InNamespace(instance.Namespace).MatchingLabels(map[string]string{CONFIGURATION_LABEL: instance.Name})
+	if err := r.client.List(context.TODO(), opts, syncRuns); err != nil {
+		return nil, err
+	}
+
+	items := syncRuns.Items
+	sort.Slice(items, func(i, j int) bool {
+		if !items[i].CreationTimestamp.Equal(&items[j].CreationTimestamp) {
+			return items[i].CreationTimestamp.Before(&items[j].CreationTimestamp)
+		}
+		return items[i].Name < items[j].Name
+	})
+	return items, nil
+}
+
+// commitsBetween returns the commits after from up to to, the most recent first. Only to is returned when from is
+// empty, and at most SYNC_RUN_MAX_COMMITS when from is not an ancestor of to.
+func commitsBetween(logger logr.Logger, repo *git.Repository, from, to string) []appv1alpha1.SyncRunCommit {
+	if from == to {
+		return nil
+	}
+	iter, err := repo.Log(&git.LogOptions{From: plumbing.NewHash(to)})
+	if err != nil {
+		logger.Info("Git log err: " + err.Error())
+		return nil
+	}
+	defer iter.Close()
+
+	commits := []appv1alpha1.SyncRunCommit{}
+	err = iter.ForEach(func(c *object.Commit) error {
+		if c.Hash.String() == from {
+			return storer.ErrStop
+		}
+		message := strings.TrimSpace(c.Message)
+		if i := strings.Index(message, "\n"); i >= 0 {
+			message = message[:i]
+		}
+		commits = append(commits, appv1alpha1.SyncRunCommit{SHA: c.Hash.String(), Message: message})
+		if from == "" || len(commits) >= SYNC_RUN_MAX_COMMITS {
+			return storer.ErrStop
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Info("Git log err: " + err.Error())
+	}
+	return commits
+}
+
+// pruneSyncRuns deletes the oldest SyncRuns of instance beyond its SyncRunHistoryLimit
+func pruneSyncRuns(r *ReconcileConfiguration, logger logr.Logger, instance *appv1alpha1.Configuration) {
+	limit := DEFAULT_SYNC_RUN_HISTORY_LIMIT
+	if instance.Spec.SyncRunHistoryLimit != nil {
+		limit = int(*instance.Spec.SyncRunHistoryLimit)
+	}
+
+	items, err := listSyncRuns(r, instance)
+	if err != nil {
+		logger.Info("List SyncRuns err: " + err.Error())
+		return
+	}
+	if len(items) <= limit {
+		return
+	}
+
+	for i := range items[:len(items)-limit] {
+		if err := r.client.Delete(context.TODO(), &items[i]); err != nil {
+			logger.Info("Delete SyncRun " + items[i].Name + " err: " + err.Error())
+		}
+	}
+}
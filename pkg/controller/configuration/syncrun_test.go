@@ -0,0 +1,61 @@
+package configuration
+
+import (
+	"reflect"
+	"testing"
+
+	appv1alpha1 "github.com/cvicens/rocketeer-operator/pkg/apis/app/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+func TestRecordSyncRun(t *testing.T) {
+	instance := &appv1alpha1.Configuration{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "app.rocketeer.com/v1alpha1", Kind: "Configuration"},
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "apps", UID: "1234"},
+	}
+	r := &ReconcileConfiguration{client: newMemoryClient(instance.DeepCopy()), scheme: memoryScheme}
+	changed := &syncRun{objects: []appv1alpha1.SyncRunObject{{Kind: "ConfigMap", Name: "web", Action: appv1alpha1.SyncActionUpdated}}}
+	unchanged := &syncRun{objects: []appv1alpha1.SyncRunObject{{Kind: "ConfigMap", Name: "web", Action: appv1alpha1.SyncActionUnchanged}}}
+
+	tests := []struct {
+		name     string
+		trigger  string
+		run      *syncRun
+		expected []string
+		count    int32
+	}{
+		{name: "drift", run: changed, expected: []string{"Succeeded"}},
+		{name: "first requeue", run: unchanged, expected: []string{"Succeeded", "NoChanges"}, count: 1},
+		{name: "second requeue", run: unchanged, expected: []string{"Succeeded", "NoChanges"}, count: 2},
+		{name: "third requeue", run: unchanged, expected: []string{"Succeeded", "NoChanges"}, count: 3},
+		{name: "manual", trigger: "manual", run: unchanged, expected: []string{"Succeeded", "NoChanges", "NoChanges"}, count: 1},
+		{name: "poll after manual", run: unchanged, expected: []string{"Succeeded", "NoChanges", "NoChanges", "NoChanges"}, count: 1},
+	}
+
+	for _, test := range tests {
+		if test.trigger != "" {
+			instance.Annotations = map[string]string{SYNC_TRIGGER_ANNOTATION: test.trigger}
+		}
+		recordSyncRun(r, logf.Log, instance, nil, test.run, "abc", "abc", nil)
+		if _, ok := instance.Annotations[SYNC_TRIGGER_ANNOTATION]; ok {
+			t.Errorf("%s: trigger annotation not removed", test.name)
+		}
+
+		syncRuns, err := listSyncRuns(r, instance)
+		if err != nil {
+			t.Fatal(err)
+		}
+		phases := []string{}
+		for _, syncRun := range syncRuns {
+			phases = append(phases, string(syncRun.Status.Phase))
+		}
+		if !reflect.DeepEqual(phases, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, phases)
+			continue
+		}
+		if last := syncRuns[len(syncRuns)-1]; last.Status.Count != test.count {
+			t.Errorf("%s: expected a count of %d, got %d", test.name, test.count, last.Status.Count)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package objectmatch
+
+import (
+	"encoding/json"
+
+	"github.com/goph/emperror"
+	buildv1 "github.com/openshift/api/build/v1"
+)
+
+type buildConfigMatcher struct {
+	objectMatcher ObjectMatcher
+}
+
+func NewBuildConfigMatcher(objectMatcher ObjectMatcher) *buildConfigMatcher {
+	return &buildConfigMatcher{
+		objectMatcher: objectMatcher,
+	}
+}
+
+// Match compares two buildv1.BuildConfig objects, ignoring the last version counter and the images recorded by image change triggers
+func (m buildConfigMatcher) Match(old, new *buildv1.BuildConfig) (bool, error) {
+	type BuildConfig struct {
+		ObjectMeta
+		Spec buildv1.BuildConfigSpec
+	}
+
+	oldData, err := json.Marshal(BuildConfig{
+		ObjectMeta: m.objectMatcher.GetObjectMeta(old.ObjectMeta),
+		Spec:       buildConfigSpec(old),
+	})
+	if err != nil {
+		return false, emperror.WrapWith(err, "could not marshal old object", "name", old.Name)
+	}
+	newObject := BuildConfig{
+		ObjectMeta: m.objectMatcher.GetObjectMeta(new.ObjectMeta),
+		Spec:       buildConfigSpec(new),
+	}
+	newData, err := json.Marshal(newObject)
+	if err != nil {
+		return false, emperror.WrapWith(err, "could not marshal new object", "name", new.Name)
+	}
+
+	matched, err := m.objectMatcher.MatchJSON(oldData, newData, newObject)
+	if err != nil {
+		return false, emperror.WrapWith(err, "could not match objects", "name", new.Name)
+	}
+
+	return matched, nil
+}
+
+// buildConfigSpec returns a copy of the spec without the image ids recorded by image change triggers, the last
+// version counter lives in the status and is never compared
+func buildConfigSpec(bc *buildv1.BuildConfig) buildv1.BuildConfigSpec {
+	spec := *bc.Spec.DeepCopy()
+	for i := range spec.Triggers {
+		if spec.Triggers[i].ImageChange != nil {
+			spec.Triggers[i].ImageChange.LastTriggeredImageID = ""
+		}
+	}
+	return spec
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package objectmatch
+
+import (
+	"encoding/json"
+
+	"github.com/cvicens/rocketeer-operator/pkg/redact"
+	"github.com/goph/emperror"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+type defaultMatcher struct {
+	objectMatcher ObjectMatcher
+}
+
+func NewDefaultMatcher(objectMatcher ObjectMatcher) *defaultMatcher {
+	return &defaultMatcher{
+		objectMatcher: objectMatcher,
+	}
+}
+
+// Match compares two objects of any type through their JSON representation, ignoring status, type meta and the
+// server populated metadata
+func (m defaultMatcher) Match(old, new interface{}) (bool, error) {
+	oldData, err := m.marshal(old)
+	if err != nil {
+		return false, emperror.Wrap(err, "could not marshal old object")
+	}
+	newData, err := m.marshal(new)
+	if err != nil {
+		return false, emperror.Wrap(err, "could not marshal new object")
+	}
+
+	// Compared with a JSON merge patch, the kind tells which values to redact from the diff
+	obj := &unstructured.Unstructured{}
+	obj.SetKind(redact.Kind(new))
+	matched, err := m.objectMatcher.MatchJSON(oldData, newData, obj)
+	if err != nil {
+		return false, emperror.Wrap(err, "could not match objects")
+	}
+
+	return matched, nil
+}
+
+func (m defaultMatcher) marshal(obj interface{}) ([]byte, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	object := map[string]interface{}{}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, err
+	}
+
+	delete(object, "apiVersion")
+	delete(object, "kind")
+	delete(object, "status")
+	delete(object, "metadata")
+	if accessor, err := meta.Accessor(obj); err == nil {
+		object["metadata"] = m.objectMatcher.GetObjectMeta(metav1.ObjectMeta{
+			Labels:          accessor.GetLabels(),
+			Annotations:     accessor.GetAnnotations(),
+			OwnerReferences: accessor.GetOwnerReferences(),
+		})
+	}
+
+	return json.Marshal(object)
+}
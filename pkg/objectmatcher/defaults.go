@@ -0,0 +1,399 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package objectmatch
+
+import (
+	"strings"
+
+	oappsv1 "github.com/openshift/api/apps/v1"
+	buildv1 "github.com/openshift/api/build/v1"
+	imagev1 "github.com/openshift/api/image/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// AddDefaultingFuncs registers on scheme the defaults the API server sets on the objects the matcher knows about.
+// They mirror the defaulting functions of the Kubernetes and OpenShift API servers, which are not importable.
+func AddDefaultingFuncs(scheme *runtime.Scheme) error {
+	scheme.AddTypeDefaultingFunc(&corev1.Pod{}, func(obj interface{}) { setDefaultsPodSpec(&obj.(*corev1.Pod).Spec) })
+	scheme.AddTypeDefaultingFunc(&corev1.Service{}, func(obj interface{}) { setDefaultsService(obj.(*corev1.Service)) })
+	scheme.AddTypeDefaultingFunc(&appsv1.Deployment{}, func(obj interface{}) { setDefaultsDeployment(obj.(*appsv1.Deployment)) })
+	scheme.AddTypeDefaultingFunc(&appsv1.StatefulSet{}, func(obj interface{}) { setDefaultsStatefulSet(obj.(*appsv1.StatefulSet)) })
+	scheme.AddTypeDefaultingFunc(&appsv1.DaemonSet{}, func(obj interface{}) { setDefaultsDaemonSet(obj.(*appsv1.DaemonSet)) })
+	scheme.AddTypeDefaultingFunc(&batchv1.Job{}, func(obj interface{}) { setDefaultsJob(obj.(*batchv1.Job)) })
+	scheme.AddTypeDefaultingFunc(&batchv1beta1.CronJob{}, func(obj interface{}) { setDefaultsCronJob(obj.(*batchv1beta1.CronJob)) })
+	scheme.AddTypeDefaultingFunc(&networkingv1.NetworkPolicy{}, func(obj interface{}) {
+		setDefaultsNetworkPolicy(obj.(*networkingv1.NetworkPolicy))
+	})
+	scheme.AddTypeDefaultingFunc(&oappsv1.DeploymentConfig{}, func(obj interface{}) {
+		setDefaultsDeploymentConfig(obj.(*oappsv1.DeploymentConfig))
+	})
+	scheme.AddTypeDefaultingFunc(&imagev1.ImageStream{}, func(obj interface{}) { setDefaultsImageStream(obj.(*imagev1.ImageStream)) })
+	scheme.AddTypeDefaultingFunc(&buildv1.BuildConfig{}, func(obj interface{}) { setDefaultsBuildConfig(obj.(*buildv1.BuildConfig)) })
+	return nil
+}
+
+func int32Ptr(i int32) *int32 { return &i }
+
+func int64Ptr(i int64) *int64 { return &i }
+
+func intOrStringPtr(v intstr.IntOrString) *intstr.IntOrString { return &v }
+
+func setDefaultsDeployment(obj *appsv1.Deployment) {
+	if obj.Spec.Replicas == nil {
+		obj.Spec.Replicas = int32Ptr(1)
+	}
+	if obj.Spec.Strategy.Type == "" {
+		obj.Spec.Strategy.Type = appsv1.RollingUpdateDeploymentStrategyType
+	}
+	if obj.Spec.Strategy.Type == appsv1.RollingUpdateDeploymentStrategyType {
+		if obj.Spec.Strategy.RollingUpdate == nil {
+			obj.Spec.Strategy.RollingUpdate = &appsv1.RollingUpdateDeployment{}
+		}
+		if obj.Spec.Strategy.RollingUpdate.MaxUnavailable == nil {
+			obj.Spec.Strategy.RollingUpdate.MaxUnavailable = intOrStringPtr(intstr.FromString("25%"))
+		}
+		if obj.Spec.Strategy.RollingUpdate.MaxSurge == nil {
+			obj.Spec.Strategy.RollingUpdate.MaxSurge = intOrStringPtr(intstr.FromString("25%"))
+		}
+	}
+	if obj.Spec.RevisionHistoryLimit == nil {
+		obj.Spec.RevisionHistoryLimit = int32Ptr(10)
+	}
+	if obj.Spec.ProgressDeadlineSeconds == nil {
+		obj.Spec.ProgressDeadlineSeconds = int32Ptr(600)
+	}
+	setDefaultsPodSpec(&obj.Spec.Template.Spec)
+}
+
+func setDefaultsStatefulSet(obj *appsv1.StatefulSet) {
+	if obj.Spec.PodManagementPolicy == "" {
+		obj.Spec.PodManagementPolicy = appsv1.OrderedReadyPodManagement
+	}
+	if obj.Spec.UpdateStrategy.Type == "" {
+		obj.Spec.UpdateStrategy.Type = appsv1.RollingUpdateStatefulSetStrategyType
+		obj.Spec.UpdateStrategy.RollingUpdate = &appsv1.RollingUpdateStatefulSetStrategy{Partition: int32Ptr(0)}
+	}
+	if obj.Spec.Replicas == nil {
+		obj.Spec.Replicas = int32Ptr(1)
+	}
+	if obj.Spec.RevisionHistoryLimit == nil {
+		obj.Spec.RevisionHistoryLimit = int32Ptr(10)
+	}
+	for i := range obj.Spec.VolumeClaimTemplates {
+		if spec := &obj.Spec.VolumeClaimTemplates[i].Spec; spec.VolumeMode == nil {
+			mode := corev1.PersistentVolumeFilesystem
+			spec.VolumeMode = &mode
+		}
+	}
+	setDefaultsPodSpec(&obj.Spec.Template.Spec)
+}
+
+func setDefaultsDaemonSet(obj *appsv1.DaemonSet) {
+	if obj.Spec.UpdateStrategy.Type == "" {
+		obj.Spec.UpdateStrategy.Type = appsv1.RollingUpdateDaemonSetStrategyType
+	}
+	if obj.Spec.UpdateStrategy.Type == appsv1.RollingUpdateDaemonSetStrategyType {
+		if obj.Spec.UpdateStrategy.RollingUpdate == nil {
+			obj.Spec.UpdateStrategy.RollingUpdate = &appsv1.RollingUpdateDaemonSet{}
+		}
+		if obj.Spec.UpdateStrategy.RollingUpdate.MaxUnavailable == nil {
+			obj.Spec.UpdateStrategy.RollingUpdate.MaxUnavailable = intOrStringPtr(intstr.FromInt(1))
+		}
+	}
+	if obj.Spec.RevisionHistoryLimit == nil {
+		obj.Spec.RevisionHistoryLimit = int32Ptr(10)
+	}
+	setDefaultsPodSpec(&obj.Spec.Template.Spec)
+}
+
+func setDefaultsJob(obj *batchv1.Job) {
+	if obj.Spec.Completions == nil && obj.Spec.Parallelism == nil {
+		obj.Spec.Completions = int32Ptr(1)
+		obj.Spec.Parallelism = int32Ptr(1)
+	}
+	if obj.Spec.Parallelism == nil {
+		obj.Spec.Parallelism = int32Ptr(1)
+	}
+	if obj.Spec.BackoffLimit == nil {
+		obj.Spec.BackoffLimit = int32Ptr(6)
+	}
+	setDefaultsPodSpec(&obj.Spec.Template.Spec)
+}
+
+func setDefaultsCronJob(obj *batchv1beta1.CronJob) {
+	if obj.Spec.ConcurrencyPolicy == "" {
+		obj.Spec.ConcurrencyPolicy = batchv1beta1.AllowConcurrent
+	}
+	if obj.Spec.Suspend == nil {
+		suspend := false
+		obj.Spec.Suspend = &suspend
+	}
+	if obj.Spec.SuccessfulJobsHistoryLimit == nil {
+		obj.Spec.SuccessfulJobsHistoryLimit = int32Ptr(3)
+	}
+	if obj.Spec.FailedJobsHistoryLimit == nil {
+		obj.Spec.FailedJobsHistoryLimit = int32Ptr(1)
+	}
+	setDefaultsPodSpec(&obj.Spec.JobTemplate.Spec.Template.Spec)
+}
+
+func setDefaultsDeploymentConfig(obj *oappsv1.DeploymentConfig) {
+	if obj.Spec.Triggers == nil {
+		obj.Spec.Triggers = oappsv1.DeploymentTriggerPolicies{{Type: oappsv1.DeploymentTriggerOnConfigChange}}
+	}
+	if len(obj.Spec.Selector) == 0 && obj.Spec.Template != nil {
+		obj.Spec.Selector = obj.Spec.Template.Labels
+	}
+
+	strategy := &obj.Spec.Strategy
+	if strategy.Type == "" {
+		strategy.Type = oappsv1.DeploymentStrategyTypeRolling
+	}
+	if strategy.Type == oappsv1.DeploymentStrategyTypeRolling {
+		if strategy.RollingParams == nil {
+			strategy.RollingParams = &oappsv1.RollingDeploymentStrategyParams{}
+		}
+		params := strategy.RollingParams
+		if params.UpdatePeriodSeconds == nil {
+			params.UpdatePeriodSeconds = int64Ptr(1)
+		}
+		if params.IntervalSeconds == nil {
+			params.IntervalSeconds = int64Ptr(1)
+		}
+		if params.TimeoutSeconds == nil {
+			params.TimeoutSeconds = int64Ptr(600)
+		}
+		if params.MaxUnavailable == nil && params.MaxSurge == nil {
+			params.MaxUnavailable = intOrStringPtr(intstr.FromString("25%"))
+			params.MaxSurge = intOrStringPtr(intstr.FromString("25%"))
+		}
+	}
+	if strategy.ActiveDeadlineSeconds == nil {
+		strategy.ActiveDeadlineSeconds = int64Ptr(21600)
+	}
+
+	if obj.Spec.Template != nil {
+		setDefaultsPodSpec(&obj.Spec.Template.Spec)
+	}
+}
+
+func setDefaultsImageStream(obj *imagev1.ImageStream) {
+	for i := range obj.Spec.Tags {
+		if obj.Spec.Tags[i].ReferencePolicy.Type == "" {
+			obj.Spec.Tags[i].ReferencePolicy.Type = imagev1.SourceTagReferencePolicy
+		}
+	}
+}
+
+func setDefaultsBuildConfig(obj *buildv1.BuildConfig) {
+	source := &obj.Spec.Source
+	if source.Type == "" {
+		switch {
+		case source.Git != nil:
+			source.Type = buildv1.BuildSourceGit
+		case source.Images != nil:
+			source.Type = buildv1.BuildSourceImage
+		case source.Binary != nil:
+			source.Type = buildv1.BuildSourceBinary
+		case source.Dockerfile != nil:
+			source.Type = buildv1.BuildSourceDockerfile
+		}
+	}
+
+	strategy := &obj.Spec.Strategy
+	if strategy.Type == "" {
+		switch {
+		case strategy.SourceStrategy != nil:
+			strategy.Type = buildv1.SourceBuildStrategyType
+		case strategy.CustomStrategy != nil:
+			strategy.Type = buildv1.CustomBuildStrategyType
+		case strategy.JenkinsPipelineStrategy != nil:
+			strategy.Type = buildv1.JenkinsPipelineBuildStrategyType
+		default:
+			strategy.Type = buildv1.DockerBuildStrategyType
+		}
+	}
+}
+
+func setDefaultsNetworkPolicy(obj *networkingv1.NetworkPolicy) {
+	if len(obj.Spec.PolicyTypes) == 0 {
+		obj.Spec.PolicyTypes = []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}
+		if len(obj.Spec.Egress) > 0 {
+			obj.Spec.PolicyTypes = append(obj.Spec.PolicyTypes, networkingv1.PolicyTypeEgress)
+		}
+	}
+	for i := range obj.Spec.Ingress {
+		setDefaultsNetworkPolicyPorts(obj.Spec.Ingress[i].Ports)
+	}
+	for i := range obj.Spec.Egress {
+		setDefaultsNetworkPolicyPorts(obj.Spec.Egress[i].Ports)
+	}
+}
+
+func setDefaultsNetworkPolicyPorts(ports []networkingv1.NetworkPolicyPort) {
+	for i := range ports {
+		if ports[i].Protocol == nil {
+			protocol := corev1.ProtocolTCP
+			ports[i].Protocol = &protocol
+		}
+	}
+}
+
+func setDefaultsService(obj *corev1.Service) {
+	if obj.Spec.SessionAffinity == "" {
+		obj.Spec.SessionAffinity = corev1.ServiceAffinityNone
+	}
+	if obj.Spec.Type == "" {
+		obj.Spec.Type = corev1.ServiceTypeClusterIP
+	}
+	for i := range obj.Spec.Ports {
+		port := &obj.Spec.Ports[i]
+		if port.Protocol == "" {
+			port.Protocol = corev1.ProtocolTCP
+		}
+		if port.TargetPort == intstr.FromInt(0) || port.TargetPort == intstr.FromString("") {
+			port.TargetPort = intstr.FromInt(int(port.Port))
+		}
+	}
+	if (obj.Spec.Type == corev1.ServiceTypeNodePort || obj.Spec.Type == corev1.ServiceTypeLoadBalancer) &&
+		obj.Spec.ExternalTrafficPolicy == "" {
+		obj.Spec.ExternalTrafficPolicy = corev1.ServiceExternalTrafficPolicyTypeCluster
+	}
+}
+
+func setDefaultsPodSpec(spec *corev1.PodSpec) {
+	if spec.DNSPolicy == "" {
+		spec.DNSPolicy = corev1.DNSClusterFirst
+	}
+	if spec.RestartPolicy == "" {
+		spec.RestartPolicy = corev1.RestartPolicyAlways
+	}
+	if spec.SecurityContext == nil {
+		spec.SecurityContext = &corev1.PodSecurityContext{}
+	}
+	if spec.TerminationGracePeriodSeconds == nil {
+		spec.TerminationGracePeriodSeconds = int64Ptr(corev1.DefaultTerminationGracePeriodSeconds)
+	}
+	if spec.SchedulerName == "" {
+		spec.SchedulerName = corev1.DefaultSchedulerName
+	}
+	for i := range spec.Volumes {
+		setDefaultsVolume(&spec.Volumes[i])
+	}
+	for i := range spec.InitContainers {
+		setDefaultsContainer(&spec.InitContainers[i])
+	}
+	for i := range spec.Containers {
+		setDefaultsContainer(&spec.Containers[i])
+	}
+}
+
+func setDefaultsVolume(volume *corev1.Volume) {
+	mode := int32(corev1.SecretVolumeSourceDefaultMode)
+	switch {
+	case volume.Secret != nil:
+		if volume.Secret.DefaultMode == nil {
+			volume.Secret.DefaultMode = int32Ptr(mode)
+		}
+	case volume.ConfigMap != nil:
+		if volume.ConfigMap.DefaultMode == nil {
+			volume.ConfigMap.DefaultMode = int32Ptr(mode)
+		}
+	case volume.DownwardAPI != nil:
+		if volume.DownwardAPI.DefaultMode == nil {
+			volume.DownwardAPI.DefaultMode = int32Ptr(mode)
+		}
+	case volume.Projected != nil:
+		if volume.Projected.DefaultMode == nil {
+			volume.Projected.DefaultMode = int32Ptr(mode)
+		}
+	case volume.VolumeSource == (corev1.VolumeSource{}):
+		volume.EmptyDir = &corev1.EmptyDirVolumeSource{}
+	}
+}
+
+func setDefaultsContainer(container *corev1.Container) {
+	if container.ImagePullPolicy == "" {
+		if imageTag(container.Image) == "latest" {
+			container.ImagePullPolicy = corev1.PullAlways
+		} else {
+			container.ImagePullPolicy = corev1.PullIfNotPresent
+		}
+	}
+	if container.TerminationMessagePath == "" {
+		container.TerminationMessagePath = corev1.TerminationMessagePathDefault
+	}
+	if container.TerminationMessagePolicy == "" {
+		container.TerminationMessagePolicy = corev1.TerminationMessageReadFile
+	}
+	for i := range container.Ports {
+		if container.Ports[i].Protocol == "" {
+			container.Ports[i].Protocol = corev1.ProtocolTCP
+		}
+	}
+	for i := range container.Env {
+		if ref := container.Env[i].ValueFrom; ref != nil && ref.FieldRef != nil && ref.FieldRef.APIVersion == "" {
+			ref.FieldRef.APIVersion = "v1"
+		}
+	}
+	setDefaultsProbe(container.LivenessProbe)
+	setDefaultsProbe(container.ReadinessProbe)
+}
+
+func setDefaultsProbe(probe *corev1.Probe) {
+	if probe == nil {
+		return
+	}
+	if probe.TimeoutSeconds == 0 {
+		probe.TimeoutSeconds = 1
+	}
+	if probe.PeriodSeconds == 0 {
+		probe.PeriodSeconds = 10
+	}
+	if probe.SuccessThreshold == 0 {
+		probe.SuccessThreshold = 1
+	}
+	if probe.FailureThreshold == 0 {
+		probe.FailureThreshold = 3
+	}
+	if probe.HTTPGet != nil {
+		if probe.HTTPGet.Path == "" {
+			probe.HTTPGet.Path = "/"
+		}
+		if probe.HTTPGet.Scheme == "" {
+			probe.HTTPGet.Scheme = corev1.URISchemeHTTP
+		}
+	}
+}
+
+// imageTag returns the tag of image, "latest" when it has neither a tag nor a digest
+func imageTag(image string) string {
+	if strings.Contains(image, "@") {
+		return ""
+	}
+	name := image[strings.LastIndex(image, "/")+1:]
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		return name[i+1:]
+	}
+	return "latest"
+}
@@ -0,0 +1,306 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package objectmatch
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+	"sigs.k8s.io/yaml"
+)
+
+const deploymentDescriptor = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  selector:
+    matchLabels:
+      app: web
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      containers:
+      - name: web
+        image: quay.io/example/web:1.0
+        ports:
+        - containerPort: 8080
+        readinessProbe:
+          httpGet:
+            port: 8080
+      volumes:
+      - name: config
+        configMap:
+          name: web
+`
+
+// deploymentLive is deploymentDescriptor as returned by the API server
+const deploymentLive = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  resourceVersion: "1234"
+  generation: 1
+  annotations:
+    deployment.kubernetes.io/revision: "1"
+spec:
+  replicas: 1
+  revisionHistoryLimit: 10
+  progressDeadlineSeconds: 600
+  strategy:
+    type: RollingUpdate
+    rollingUpdate:
+      maxSurge: 25%
+      maxUnavailable: 25%
+  selector:
+    matchLabels:
+      app: web
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      containers:
+      - name: web
+        image: quay.io/example/web:1.0
+        imagePullPolicy: IfNotPresent
+        ports:
+        - containerPort: 8080
+          protocol: TCP
+        readinessProbe:
+          httpGet:
+            path: /
+            port: 8080
+            scheme: HTTP
+          timeoutSeconds: 1
+          periodSeconds: 10
+          successThreshold: 1
+          failureThreshold: 3
+        terminationMessagePath: /dev/termination-log
+        terminationMessagePolicy: File
+      volumes:
+      - name: config
+        configMap:
+          name: web
+          defaultMode: 420
+      dnsPolicy: ClusterFirst
+      restartPolicy: Always
+      schedulerName: default-scheduler
+      securityContext: {}
+      terminationGracePeriodSeconds: 30
+status:
+  replicas: 1
+`
+
+const serviceDescriptor = `
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  selector:
+    app: web
+  ports:
+  - name: http
+    port: 80
+`
+
+// serviceLive is serviceDescriptor as returned by the API server
+const serviceLive = `
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  resourceVersion: "1234"
+spec:
+  type: ClusterIP
+  sessionAffinity: None
+  selector:
+    app: web
+  ports:
+  - name: http
+    port: 80
+    protocol: TCP
+    targetPort: 80
+status:
+  loadBalancer: {}
+`
+
+const podDescriptor = `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+spec:
+  containers:
+  - name: web
+    image: quay.io/example/web
+    env:
+    - name: POD_NAME
+      valueFrom:
+        fieldRef:
+          fieldPath: metadata.name
+  volumes:
+  - name: cache
+`
+
+// podLive is podDescriptor as returned by the API server
+const podLive = `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+  resourceVersion: "1234"
+spec:
+  containers:
+  - name: web
+    image: quay.io/example/web
+    imagePullPolicy: Always
+    env:
+    - name: POD_NAME
+      valueFrom:
+        fieldRef:
+          apiVersion: v1
+          fieldPath: metadata.name
+    terminationMessagePath: /dev/termination-log
+    terminationMessagePolicy: File
+  volumes:
+  - name: cache
+    emptyDir: {}
+  dnsPolicy: ClusterFirst
+  restartPolicy: Always
+  schedulerName: default-scheduler
+  securityContext: {}
+  terminationGracePeriodSeconds: 30
+status:
+  phase: Running
+`
+
+func unmarshalObject(t *testing.T, data string, obj runtime.Object) runtime.Object {
+	if err := yaml.Unmarshal([]byte(data), obj); err != nil {
+		t.Fatal(err)
+	}
+	return obj
+}
+
+func TestMatchWithDefaults(t *testing.T) {
+	defaults := runtime.NewScheme()
+	AddDefaultingFuncs(defaults)
+	om := NewWithDefaulter(logf.Log, defaults)
+
+	tests := []struct {
+		name    string
+		live    runtime.Object
+		desired runtime.Object
+		// change makes a change to desired that the API server would not undo
+		change func(obj runtime.Object)
+	}{
+		{
+			name:    "Deployment",
+			live:    unmarshalObject(t, deploymentLive, &appsv1.Deployment{}),
+			desired: unmarshalObject(t, deploymentDescriptor, &appsv1.Deployment{}),
+			change: func(obj runtime.Object) {
+				obj.(*appsv1.Deployment).Spec.Template.Spec.Containers[0].Image = "quay.io/example/web:1.1"
+			},
+		},
+		{
+			name:    "Deployment replicas",
+			live:    unmarshalObject(t, deploymentLive, &appsv1.Deployment{}),
+			desired: unmarshalObject(t, deploymentDescriptor, &appsv1.Deployment{}),
+			change: func(obj runtime.Object) {
+				obj.(*appsv1.Deployment).Spec.Replicas = int32Ptr(3)
+			},
+		},
+		{
+			name:    "Service",
+			live:    unmarshalObject(t, serviceLive, &corev1.Service{}),
+			desired: unmarshalObject(t, serviceDescriptor, &corev1.Service{}),
+			change: func(obj runtime.Object) {
+				obj.(*corev1.Service).Spec.Ports[0].Port = 8080
+			},
+		},
+		{
+			name:    "Service type",
+			live:    unmarshalObject(t, serviceLive, &corev1.Service{}),
+			desired: unmarshalObject(t, serviceDescriptor, &corev1.Service{}),
+			change: func(obj runtime.Object) {
+				obj.(*corev1.Service).Spec.Type = corev1.ServiceTypeNodePort
+			},
+		},
+		{
+			name:    "Pod",
+			live:    unmarshalObject(t, podLive, &corev1.Pod{}),
+			desired: unmarshalObject(t, podDescriptor, &corev1.Pod{}),
+			change: func(obj runtime.Object) {
+				obj.(*corev1.Pod).Spec.Containers[0].ImagePullPolicy = corev1.PullIfNotPresent
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			matched, err := om.Match(test.live, test.desired)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !matched {
+				result, _ := om.Diff(test.live, test.desired)
+				t.Fatalf("defaulted live object does not match its descriptor:\n%s", result.Diff)
+			}
+
+			changed := test.desired.DeepCopyObject()
+			test.change(changed)
+			matched, err = om.Match(test.live, changed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if matched {
+				t.Fatal("changed descriptor matches the live object")
+			}
+			result, err := om.Diff(test.live, changed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Matched || len(result.Paths) == 0 {
+				t.Fatalf("no difference found: %+v", result)
+			}
+		})
+	}
+}
+
+func TestMatchWithoutDefaults(t *testing.T) {
+	om := New(logf.Log)
+
+	// Without the defaults the lists of the descriptor replace the lists of the live object, dropping the fields
+	// set by the API server
+	live := unmarshalObject(t, deploymentLive, &appsv1.Deployment{})
+	desired := unmarshalObject(t, deploymentDescriptor, &appsv1.Deployment{})
+	matched, err := om.Match(live, desired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if matched {
+		t.Fatal("undefaulted descriptor matches the defaulted live object")
+	}
+}
@@ -21,6 +21,7 @@ import (
 
 	"github.com/goph/emperror"
 	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type deploymentMatcher struct {
@@ -40,18 +41,15 @@ func (m deploymentMatcher) Match(old, new *appsv1.Deployment) (bool, error) {
 		Spec appsv1.DeploymentSpec
 	}
 
-	delete(old.ObjectMeta.Annotations, "deployment.kubernetes.io/revision")
-	delete(old.ObjectMeta.Annotations, "control-plane.alpha.kubernetes.io/leader")
-
 	oldData, err := json.Marshal(Deployment{
-		ObjectMeta: m.objectMatcher.GetObjectMeta(old.ObjectMeta),
+		ObjectMeta: m.objectMatcher.GetObjectMeta(deploymentObjectMeta(old)),
 		Spec:       old.Spec,
 	})
 	if err != nil {
 		return false, emperror.WrapWith(err, "could not marshal old object", "name", old.Name)
 	}
 	newObject := Deployment{
-		ObjectMeta: m.objectMatcher.GetObjectMeta(new.ObjectMeta),
+		ObjectMeta: m.objectMatcher.GetObjectMeta(deploymentObjectMeta(new)),
 		Spec:       new.Spec,
 	}
 	newData, err := json.Marshal(newObject)
@@ -66,3 +64,11 @@ func (m deploymentMatcher) Match(old, new *appsv1.Deployment) (bool, error) {
 
 	return matched, nil
 }
+
+// deploymentObjectMeta returns a copy of the metadata without the annotations set by the controllers
+func deploymentObjectMeta(deployment *appsv1.Deployment) metav1.ObjectMeta {
+	objectMeta := *deployment.ObjectMeta.DeepCopy()
+	delete(objectMeta.Annotations, "deployment.kubernetes.io/revision")
+	delete(objectMeta.Annotations, "control-plane.alpha.kubernetes.io/leader")
+	return objectMeta
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package objectmatch
+
+import (
+	"encoding/json"
+
+	"github.com/goph/emperror"
+	oappsv1 "github.com/openshift/api/apps/v1"
+)
+
+type deploymentConfigMatcher struct {
+	objectMatcher ObjectMatcher
+}
+
+func NewDeploymentConfigMatcher(objectMatcher ObjectMatcher) *deploymentConfigMatcher {
+	return &deploymentConfigMatcher{
+		objectMatcher: objectMatcher,
+	}
+}
+
+// Match compares two oappsv1.DeploymentConfig objects, ignoring the images resolved by image change triggers
+func (m deploymentConfigMatcher) Match(old, new *oappsv1.DeploymentConfig) (bool, error) {
+	type DeploymentConfig struct {
+		ObjectMeta
+		Spec oappsv1.DeploymentConfigSpec
+	}
+
+	oldData, err := json.Marshal(DeploymentConfig{
+		ObjectMeta: m.objectMatcher.GetObjectMeta(old.ObjectMeta),
+		Spec:       deploymentConfigSpec(old),
+	})
+	if err != nil {
+		return false, emperror.WrapWith(err, "could not marshal old object", "name", old.Name)
+	}
+	newObject := DeploymentConfig{
+		ObjectMeta: m.objectMatcher.GetObjectMeta(new.ObjectMeta),
+		Spec:       deploymentConfigSpec(new),
+	}
+	newData, err := json.Marshal(newObject)
+	if err != nil {
+		return false, emperror.WrapWith(err, "could not marshal new object", "name", new.Name)
+	}
+
+	matched, err := m.objectMatcher.MatchJSON(oldData, newData, newObject)
+	if err != nil {
+		return false, emperror.WrapWith(err, "could not match objects", "name", new.Name)
+	}
+
+	return matched, nil
+}
+
+// deploymentConfigSpec returns a copy of the spec without the container images set by image change triggers and
+// without the last triggered images
+func deploymentConfigSpec(dc *oappsv1.DeploymentConfig) oappsv1.DeploymentConfigSpec {
+	spec := *dc.Spec.DeepCopy()
+	triggered := map[string]bool{}
+	for _, trigger := range spec.Triggers {
+		if trigger.Type != oappsv1.DeploymentTriggerOnImageChange || trigger.ImageChangeParams == nil {
+			continue
+		}
+		trigger.ImageChangeParams.LastTriggeredImage = ""
+		for _, name := range trigger.ImageChangeParams.ContainerNames {
+			triggered[name] = true
+		}
+	}
+
+	if spec.Template != nil {
+		for i := range spec.Template.Spec.Containers {
+			if triggered[spec.Template.Spec.Containers[i].Name] {
+				spec.Template.Spec.Containers[i].Image = ""
+			}
+		}
+		for i := range spec.Template.Spec.InitContainers {
+			if triggered[spec.Template.Spec.InitContainers[i].Name] {
+				spec.Template.Spec.InitContainers[i].Image = ""
+			}
+		}
+	}
+	return spec
+}
@@ -0,0 +1,190 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package objectmatch
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/cvicens/rocketeer-operator/pkg/redact"
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/goph/emperror"
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"sigs.k8s.io/yaml"
+)
+
+// MatchResult describes how two objects differ
+type MatchResult struct {
+	Matched bool
+	// Patch is the merge patch from old to new, without the null values that would delete fields of old
+	Patch []byte
+	// Paths are the dotted paths of the fields changed by Patch, e.g. spec.template.spec.containers
+	Paths []string
+	// Diff is a unified diff between the YAML of old and of old with Patch applied, with sensitive values redacted
+	Diff string
+}
+
+// Diff compares old and new with the same matcher as Match. Matchers that do not go through MatchJSON, and matchers
+// that find a difference before comparing JSON, are described with the generic JSON matcher.
+func (om *objectMatcher) Diff(old, new interface{}) (*MatchResult, error) {
+	if reflect.TypeOf(old) != reflect.TypeOf(new) {
+		return nil, emperror.With(errors.New("old and new object types mismatch"), "oldType", reflect.TypeOf(old), "newType", reflect.TypeOf(new))
+	}
+
+	new = om.withDefaults(new)
+	recorder := om.recorder()
+	matched, err := recorder.matcherFor(new).Match(old, new)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if matched {
+		return &MatchResult{Matched: true}, nil
+	}
+	if recorder.last != nil && !recorder.last.Matched {
+		return recorder.last, nil
+	}
+
+	recorder.last = nil
+	if _, err := NewDefaultMatcher(recorder).Match(old, new); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if recorder.last != nil && !recorder.last.Matched {
+		return recorder.last, nil
+	}
+	return &MatchResult{Matched: false}, nil
+}
+
+// recorder returns a matcher sharing the registrations of om whose MatchJSON records its outcome
+func (om *objectMatcher) recorder() *objectMatcher {
+	return &objectMatcher{logger: om.logger, registry: om.registry, defaulter: om.defaulter, record: true}
+}
+
+// DiffJSON compares the JSON of two objects. obj is the object used to look up the strategic merge patch metadata,
+// objects of type *unstructured.Unstructured are compared with a JSON merge patch.
+func (om *objectMatcher) DiffJSON(old, new []byte, obj interface{}) (*MatchResult, error) {
+	var patch []byte
+	var err error
+
+	_, unstructed := obj.(*unstructured.Unstructured)
+	if unstructed {
+		if old, err = sortUnorderedLists(old); err != nil {
+			return nil, emperror.Wrap(err, "could not sort lists of old object")
+		}
+		if new, err = sortUnorderedLists(new); err != nil {
+			return nil, emperror.Wrap(err, "could not sort lists of new object")
+		}
+		patch, err = jsonpatch.CreateMergePatch(old, new)
+		if err != nil {
+			return nil, emperror.Wrap(err, "could not create json merge patch")
+		}
+	} else {
+		patch, err = strategicpatch.CreateTwoWayMergePatch(old, new, obj)
+		if err != nil {
+			return nil, emperror.Wrap(err, "could not create two way merge patch")
+		}
+	}
+
+	patch, patchMap, err := om.deleteNullInJsonPatch(patch)
+	if err != nil {
+		return nil, emperror.Wrap(err, "could not remove nil values from json merge patch")
+	}
+
+	if string(patch) == "{}" {
+		return &MatchResult{Matched: true, Patch: patch}, nil
+	}
+
+	patched, err := applyPatch(old, patch, obj, unstructed)
+	if err != nil {
+		om.logger.V(1).Info("could not apply filtered patch, diffing against new", "error", err.Error())
+		patched = new
+	}
+	// The diff is logged and recorded in events, sensitive values only show as changed
+	kind := redact.Kind(obj)
+	diff, err := yamlDiff(redact.JSON(kind, old), redact.JSON(kind, patched))
+	if err != nil {
+		return nil, emperror.Wrap(err, "could not diff objects")
+	}
+
+	return &MatchResult{
+		Matched: false,
+		Patch:   patch,
+		Paths:   patchPaths("", patchMap),
+		Diff:    diff,
+	}, nil
+}
+
+func applyPatch(old, patch []byte, obj interface{}, unstructed bool) ([]byte, error) {
+	if unstructed {
+		return jsonpatch.MergePatch(old, patch)
+	}
+	return strategicpatch.StrategicMergePatch(old, patch, obj)
+}
+
+// patchPaths returns the paths of the leaves of patch, lists are not descended into. Strategic merge patch
+// directives such as $setElementOrder are skipped.
+func patchPaths(prefix string, patch map[string]interface{}) []string {
+	keys := []string{}
+	for key := range patch {
+		if !strings.HasPrefix(key, "$") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	paths := []string{}
+	for _, key := range keys {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		sub, ok := patch[key].(map[string]interface{})
+		if !ok {
+			paths = append(paths, path)
+			continue
+		}
+		if subPaths := patchPaths(path, sub); len(subPaths) > 0 {
+			paths = append(paths, subPaths...)
+		} else {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// yamlDiff returns a unified diff from the YAML of old to the YAML of new
+func yamlDiff(old, new []byte) (string, error) {
+	oldYAML, err := yaml.JSONToYAML(old)
+	if err != nil {
+		return "", err
+	}
+	newYAML, err := yaml.JSONToYAML(new)
+	if err != nil {
+		return "", err
+	}
+
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(oldYAML)),
+		B:        difflib.SplitLines(string(newYAML)),
+		FromFile: "live",
+		ToFile:   "desired",
+		Context:  3,
+	})
+}
@@ -0,0 +1,563 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package objectmatch
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/goph/emperror"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/third_party/forked/golang/template"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// REPLICAS_POINTER is the field ignored on the objects scaled by a HorizontalPodAutoscaler
+const REPLICAS_POINTER = "/spec/replicas"
+
+// IgnoreFields sets the fields of desired selected by jsonPointers and jsonPaths to their value in live, and removes
+// those live does not have, so that they neither differ from live nor overwrite it when desired is applied.
+//
+// JSON pointers address list items by position. JSONPaths are those of kubectl, the list items they select are
+// matched between live and desired by name when they have one.
+func IgnoreFields(live, desired runtime.Object, jsonPointers, jsonPaths []string) error {
+	liveObj, err := toUnstructuredMap(live)
+	if err != nil {
+		return emperror.Wrap(err, "could not convert live object")
+	}
+	desiredObj, err := toUnstructuredMap(desired)
+	if err != nil {
+		return emperror.Wrap(err, "could not convert desired object")
+	}
+
+	paths := map[string]fieldPath{}
+	inLive := map[string]bool{}
+	add := func(fromLive bool, found ...fieldPath) {
+		for _, path := range found {
+			paths[path.String()] = path
+			if fromLive {
+				inLive[path.String()] = true
+			}
+		}
+	}
+	for _, pointer := range jsonPointers {
+		tokens, err := parseJSONPointer(pointer)
+		if err != nil {
+			return err
+		}
+		if path, ok := pointerPath(liveObj, tokens); ok {
+			add(true, path)
+		}
+		if path, ok := pointerPath(desiredObj, tokens); ok {
+			add(false, path)
+		}
+	}
+	for _, expression := range jsonPaths {
+		steps, err := parseJSONPath(expression)
+		if err != nil {
+			return err
+		}
+		add(true, expandJSONPath(liveObj, steps, nil)...)
+		add(false, expandJSONPath(desiredObj, steps, nil)...)
+	}
+
+	removals := []fieldPath{}
+	for key, path := range paths {
+		if !inLive[key] {
+			removals = append(removals, path)
+			continue
+		}
+		value, _ := getAt(liveObj, path)
+		desiredObj = setAt(desiredObj, path, runtime.DeepCopyJSONValue(value)).(map[string]interface{})
+	}
+	// Later list items go first so that removing them does not shift the position of the others
+	sort.Slice(removals, func(i, j int) bool { return removals[i].after(removals[j]) })
+	for _, path := range removals {
+		desiredObj = removeAt(desiredObj, path).(map[string]interface{})
+	}
+
+	return fromUnstructuredMap(desiredObj, desired)
+}
+
+// ValidateIgnoreFields returns an error if one of jsonPointers or jsonPaths cannot be parsed
+func ValidateIgnoreFields(jsonPointers, jsonPaths []string) error {
+	for _, pointer := range jsonPointers {
+		if _, err := parseJSONPointer(pointer); err != nil {
+			return err
+		}
+	}
+	for _, expression := range jsonPaths {
+		if _, err := parseJSONPath(expression); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func toUnstructuredMap(obj runtime.Object) (map[string]interface{}, error) {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		return u.Object, nil
+	}
+	return runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+}
+
+func fromUnstructuredMap(m map[string]interface{}, obj runtime.Object) error {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		u.Object = m
+		return nil
+	}
+	fresh := reflect.New(reflect.TypeOf(obj).Elem())
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(m, fresh.Interface()); err != nil {
+		return emperror.Wrap(err, "could not convert desired object back")
+	}
+	reflect.ValueOf(obj).Elem().Set(fresh.Elem())
+	return nil
+}
+
+// pathElem is a map key, a list item by position or a list item by name
+type pathElem struct {
+	key     string
+	name    string
+	index   int
+	isKey   bool
+	isIndex bool
+}
+
+func (e pathElem) String() string {
+	switch {
+	case e.isKey:
+		return "." + e.key
+	case e.isIndex:
+		return "[" + strconv.Itoa(e.index) + "]"
+	}
+	return "[name=" + e.name + "]"
+}
+
+type fieldPath []pathElem
+
+func (p fieldPath) String() string {
+	var b strings.Builder
+	for _, e := range p {
+		b.WriteString(e.String())
+	}
+	return b.String()
+}
+
+// after orders paths so that list items at higher positions come first
+func (p fieldPath) after(other fieldPath) bool {
+	for i := 0; i < len(p) && i < len(other); i++ {
+		if p[i].isIndex && other[i].isIndex && p[i].index != other[i].index {
+			return p[i].index > other[i].index
+		}
+	}
+	return len(p) > len(other)
+}
+
+func keyElem(key string) pathElem { return pathElem{key: key, isKey: true} }
+
+func indexElem(index int) pathElem { return pathElem{index: index, isIndex: true} }
+
+// itemElem identifies a list item by its name, by its position when it has none
+func itemElem(item interface{}, index int) pathElem {
+	if m, ok := item.(map[string]interface{}); ok {
+		if name, ok := m["name"].(string); ok && name != "" {
+			return pathElem{name: name}
+		}
+	}
+	return indexElem(index)
+}
+
+func findItem(list []interface{}, e pathElem) (int, bool) {
+	if e.isIndex {
+		return e.index, e.index >= 0 && e.index < len(list)
+	}
+	for i, item := range list {
+		if m, ok := item.(map[string]interface{}); ok && m["name"] == e.name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func getAt(value interface{}, path fieldPath) (interface{}, bool) {
+	for _, e := range path {
+		switch typedVal := value.(type) {
+		case map[string]interface{}:
+			if !e.isKey {
+				return nil, false
+			}
+			child, ok := typedVal[e.key]
+			if !ok {
+				return nil, false
+			}
+			value = child
+		case []interface{}:
+			i, ok := findItem(typedVal, e)
+			if e.isKey || !ok {
+				return nil, false
+			}
+			value = typedVal[i]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// setAt sets the field at path to v, creating the maps leading to it. List items by name are appended when missing,
+// paths through missing list items by position are left alone.
+func setAt(value interface{}, path fieldPath, v interface{}) interface{} {
+	if len(path) == 0 {
+		return v
+	}
+	e := path[0]
+	switch typedVal := value.(type) {
+	case nil:
+		if e.isKey {
+			return setAt(map[string]interface{}{}, path, v)
+		}
+		return value
+	case map[string]interface{}:
+		if !e.isKey {
+			return typedVal
+		}
+		child, ok := typedVal[e.key]
+		if !ok && len(path) > 1 && !path[1].isKey {
+			return typedVal
+		}
+		typedVal[e.key] = setAt(child, path[1:], v)
+		return typedVal
+	case []interface{}:
+		if e.isKey {
+			return typedVal
+		}
+		if i, ok := findItem(typedVal, e); ok {
+			typedVal[i] = setAt(typedVal[i], path[1:], v)
+		} else if !e.isIndex && len(path) == 1 {
+			typedVal = append(typedVal, v)
+		}
+		return typedVal
+	}
+	return value
+}
+
+func removeAt(value interface{}, path fieldPath) interface{} {
+	if len(path) == 0 {
+		return value
+	}
+	e := path[0]
+	switch typedVal := value.(type) {
+	case map[string]interface{}:
+		if !e.isKey {
+			return typedVal
+		}
+		if child, ok := typedVal[e.key]; ok {
+			if len(path) == 1 {
+				delete(typedVal, e.key)
+			} else {
+				typedVal[e.key] = removeAt(child, path[1:])
+			}
+		}
+		return typedVal
+	case []interface{}:
+		i, ok := findItem(typedVal, e)
+		if e.isKey || !ok {
+			return typedVal
+		}
+		if len(path) == 1 {
+			return append(typedVal[:i:i], typedVal[i+1:]...)
+		}
+		typedVal[i] = removeAt(typedVal[i], path[1:])
+		return typedVal
+	}
+	return value
+}
+
+// parseJSONPointer splits an RFC 6901 JSON pointer into its unescaped tokens
+func parseJSONPointer(pointer string) ([]string, error) {
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, errors.Errorf("invalid JSON pointer %q: it must start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+	}
+	return tokens, nil
+}
+
+// pointerPath returns the path of the field of obj addressed by tokens, if there is one
+func pointerPath(obj map[string]interface{}, tokens []string) (fieldPath, bool) {
+	path := fieldPath{}
+	var value interface{} = obj
+	for _, token := range tokens {
+		switch typedVal := value.(type) {
+		case map[string]interface{}:
+			child, ok := typedVal[token]
+			if !ok {
+				return nil, false
+			}
+			path = append(path, keyElem(token))
+			value = child
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(typedVal) {
+				return nil, false
+			}
+			path = append(path, indexElem(i))
+			value = typedVal[i]
+		default:
+			return nil, false
+		}
+	}
+	return path, true
+}
+
+// parseJSONPath parses expression with the JSONPath parser of client-go, the one kubectl and the health rules use.
+// The braces and the leading $ of kubectl templates are optional, e.g. `spec.template.spec.containers[0].image`.
+// The fields selected are found by expandJSONPath, as client-go only returns their values.
+func parseJSONPath(expression string) ([]jsonpath.Node, error) {
+	text := strings.TrimSpace(expression)
+	if !strings.HasPrefix(text, "{") {
+		text = strings.TrimPrefix(text, "$")
+		if !strings.HasPrefix(text, "[") {
+			text = "." + strings.TrimPrefix(text, ".")
+		}
+		text = "{" + text + "}"
+	}
+	parser, err := jsonpath.Parse("ignore", text)
+	if err != nil {
+		return nil, errors.Errorf("invalid JSONPath %q: %v", expression, err)
+	}
+	if len(parser.Root.Nodes) != 1 || parser.Root.Nodes[0].Type() != jsonpath.NodeList {
+		return nil, errors.Errorf("invalid JSONPath %q: it must be a single path", expression)
+	}
+	nodes := parser.Root.Nodes[0].(*jsonpath.ListNode).Nodes
+	if len(nodes) == 0 {
+		return nil, errors.Errorf("invalid JSONPath %q: empty path", expression)
+	}
+	if err := checkJSONPathNodes(nodes); err != nil {
+		return nil, errors.Errorf("invalid JSONPath %q: %v", expression, err)
+	}
+	return nodes, nil
+}
+
+func checkJSONPathNodes(nodes []jsonpath.Node) error {
+	for _, node := range nodes {
+		switch node := node.(type) {
+		case *jsonpath.FieldNode, *jsonpath.ArrayNode, *jsonpath.WildcardNode, *jsonpath.RecursiveNode:
+		case *jsonpath.ListNode:
+			if err := checkJSONPathNodes(node.Nodes); err != nil {
+				return err
+			}
+		case *jsonpath.UnionNode:
+			for _, list := range node.Nodes {
+				if err := checkJSONPathNodes(list.Nodes); err != nil {
+					return err
+				}
+			}
+		case *jsonpath.FilterNode:
+			if err := checkJSONPathNodes(node.Left.Nodes); err != nil {
+				return err
+			}
+			for _, operand := range node.Right.Nodes {
+				switch operand.(type) {
+				case *jsonpath.TextNode, *jsonpath.IntNode, *jsonpath.FloatNode, *jsonpath.BoolNode:
+				default:
+					if err := checkJSONPathNodes([]jsonpath.Node{operand}); err != nil {
+						return err
+					}
+				}
+			}
+		default:
+			return errors.Errorf("unsupported %s", node.Type())
+		}
+	}
+	return nil
+}
+
+// expandJSONPath returns the paths of the fields of value selected by nodes, missing fields select nothing
+func expandJSONPath(value interface{}, nodes []jsonpath.Node, prefix fieldPath) []fieldPath {
+	if len(nodes) == 0 {
+		return []fieldPath{prefix}
+	}
+
+	paths := []fieldPath{}
+	switch node := nodes[0].(type) {
+	case *jsonpath.ListNode:
+		for _, path := range expandJSONPath(value, node.Nodes, prefix) {
+			child, _ := getAt(value, path[len(prefix):])
+			paths = append(paths, expandJSONPath(child, nodes[1:], path)...)
+		}
+	case *jsonpath.FieldNode:
+		if node.Value == "" {
+			return expandJSONPath(value, nodes[1:], prefix)
+		}
+		for _, child := range children(value, prefix) {
+			if e := child.path[len(child.path)-1]; e.isKey && e.key == node.Value {
+				paths = append(paths, expandJSONPath(child.value, nodes[1:], child.path)...)
+			}
+		}
+	case *jsonpath.WildcardNode:
+		for _, child := range children(value, prefix) {
+			paths = append(paths, expandJSONPath(child.value, nodes[1:], child.path)...)
+		}
+	case *jsonpath.ArrayNode:
+		if list, ok := value.([]interface{}); ok {
+			items := children(list, prefix)
+			for _, i := range arrayIndexes(node.Params, len(list)) {
+				paths = append(paths, expandJSONPath(items[i].value, nodes[1:], items[i].path)...)
+			}
+		}
+	case *jsonpath.UnionNode:
+		for _, list := range node.Nodes {
+			paths = append(paths, expandJSONPath(value, append([]jsonpath.Node{list}, nodes[1:]...), prefix)...)
+		}
+	case *jsonpath.RecursiveNode:
+		// The value itself and every value below it go on with the nodes after the recursion
+		paths = append(paths, expandJSONPath(value, nodes[1:], prefix)...)
+		for _, child := range children(value, prefix) {
+			paths = append(paths, expandJSONPath(child.value, nodes, child.path)...)
+		}
+	case *jsonpath.FilterNode:
+		if _, ok := value.([]interface{}); ok {
+			for _, child := range children(value, prefix) {
+				if matchesFilter(child.value, node) {
+					paths = append(paths, expandJSONPath(child.value, nodes[1:], child.path)...)
+				}
+			}
+		}
+	}
+	return paths
+}
+
+type child struct {
+	path  fieldPath
+	value interface{}
+}
+
+// children returns the values of the map or list value at prefix, with their paths
+func children(value interface{}, prefix fieldPath) []child {
+	elem := func(e pathElem) fieldPath {
+		return append(append(fieldPath{}, prefix...), e)
+	}
+	result := []child{}
+	switch typedVal := value.(type) {
+	case map[string]interface{}:
+		for _, key := range sortedKeys(typedVal) {
+			result = append(result, child{path: elem(keyElem(key)), value: typedVal[key]})
+		}
+	case []interface{}:
+		for i, item := range typedVal {
+			result = append(result, child{path: elem(itemElem(item, i)), value: item})
+		}
+	}
+	return result
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// arrayIndexes returns the positions selected by the [start:end:step] params of an ArrayNode in a list of length n,
+// out of bounds positions select nothing
+func arrayIndexes(params [3]jsonpath.ParamsEntry, n int) []int {
+	start, end, step := 0, n, 1
+	if params[0].Known {
+		start = params[0].Value
+	}
+	if params[1].Known {
+		end = params[1].Value
+	}
+	if params[2].Known && params[2].Value > 0 {
+		step = params[2].Value
+	}
+	if start < 0 {
+		start += n
+	}
+	if end < 0 {
+		end += n
+	}
+	indexes := []int{}
+	for i := start; i < end && i < n; i += step {
+		if i >= 0 {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// matchesFilter evaluates a [?(...)] filter on a list item, comparing operands the way client-go does
+func matchesFilter(item interface{}, filter *jsonpath.FilterNode) bool {
+	lefts := filterOperand(item, filter.Left)
+	if filter.Operator == "exists" {
+		return len(lefts) > 0
+	}
+	rights := filterOperand(item, filter.Right)
+	if len(lefts) != 1 || len(rights) != 1 {
+		return false
+	}
+
+	var pass bool
+	var err error
+	switch filter.Operator {
+	case "==":
+		pass, err = template.Equal(lefts[0], rights[0])
+	case "!=":
+		pass, err = template.NotEqual(lefts[0], rights[0])
+	case "<":
+		pass, err = template.Less(lefts[0], rights[0])
+	case "<=":
+		pass, err = template.LessEqual(lefts[0], rights[0])
+	case ">":
+		pass, err = template.Greater(lefts[0], rights[0])
+	case ">=":
+		pass, err = template.GreaterEqual(lefts[0], rights[0])
+	}
+	return err == nil && pass
+}
+
+// filterOperand returns the values of a filter operand, a literal or a path from the item
+func filterOperand(item interface{}, operand *jsonpath.ListNode) []interface{} {
+	if len(operand.Nodes) == 1 {
+		switch literal := operand.Nodes[0].(type) {
+		case *jsonpath.TextNode:
+			return []interface{}{literal.Text}
+		case *jsonpath.IntNode:
+			return []interface{}{literal.Value}
+		case *jsonpath.FloatNode:
+			return []interface{}{literal.Value}
+		case *jsonpath.BoolNode:
+			return []interface{}{literal.Value}
+		}
+	}
+	values := []interface{}{}
+	for _, path := range expandJSONPath(item, operand.Nodes, nil) {
+		if value, ok := getAt(item, path); ok {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,238 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package objectmatch
+
+import (
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+const ignoreLive = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  annotations:
+    sidecar.istio.io/status: injected
+    example.com/a~b: live
+spec:
+  replicas: 5
+  template:
+    spec:
+      containers:
+      - name: web
+        image: web:live
+      - name: proxy
+        image: proxy:live
+      - name: metrics
+        image: metrics:live
+`
+
+const ignoreDesired = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  annotations:
+    example.com/a~b: desired
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+      - name: proxy
+        image: proxy:desired
+      - name: web
+        image: web:desired
+        imagePullPolicy: Always
+`
+
+// unstructuredFromYAML decodes data as JSON does, with numbers as float64
+func unstructuredFromYAML(t *testing.T, data string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	if err := yaml.Unmarshal([]byte(data), &obj.Object); err != nil {
+		t.Fatal(err)
+	}
+	return obj
+}
+
+func TestIgnoreFields(t *testing.T) {
+	tests := []struct {
+		name         string
+		jsonPointers []string
+		jsonPaths    []string
+		// expected holds the fields of desired after IgnoreFields, nil for the fields removed
+		expected map[string]interface{}
+	}{
+		{
+			name:         "pointer with ~1 escape",
+			jsonPointers: []string{"/metadata/annotations/sidecar.istio.io~1status"},
+			expected:     map[string]interface{}{"{.metadata.annotations.sidecar\\.istio\\.io/status}": "injected"},
+		},
+		{
+			name:         "pointer with ~0 escape",
+			jsonPointers: []string{"/metadata/annotations/example.com~1a~0b"},
+			expected:     map[string]interface{}{"{.metadata.annotations.example\\.com/a~b}": "live"},
+		},
+		{
+			name:         "pointer to a list item by position",
+			jsonPointers: []string{"/spec/template/spec/containers/0/image"},
+			expected: map[string]interface{}{
+				"{.spec.template.spec.containers[0].name}":  "proxy",
+				"{.spec.template.spec.containers[0].image}": "web:live",
+				"{.spec.template.spec.containers[1].image}": "web:desired",
+			},
+		},
+		{
+			name:         "missing pointer",
+			jsonPointers: []string{"/spec/paused", "/spec/template/spec/containers/5/image"},
+			expected:     map[string]interface{}{"{.spec.replicas}": float64(2)},
+		},
+		{
+			name:         "pointer missing from live",
+			jsonPointers: []string{"/spec/template/spec/containers/1/imagePullPolicy"},
+			expected:     map[string]interface{}{"{.spec.template.spec.containers[1].imagePullPolicy}": nil},
+		},
+		{
+			name:         "HorizontalPodAutoscaler preset",
+			jsonPointers: []string{REPLICAS_POINTER},
+			expected:     map[string]interface{}{"{.spec.replicas}": float64(5)},
+		},
+		{
+			name:      "array wildcard matches items by name",
+			jsonPaths: []string{"spec.template.spec.containers[*].image"},
+			expected: map[string]interface{}{
+				"{.spec.template.spec.containers[0].image}": "proxy:live",
+				"{.spec.template.spec.containers[1].image}": "web:live",
+				// Items only in live are left out of desired
+				"{.spec.template.spec.containers[2].image}": nil,
+			},
+		},
+		{
+			name:      "filter",
+			jsonPaths: []string{`$.spec.template.spec.containers[?(@.name=="web")].image`},
+			expected: map[string]interface{}{
+				"{.spec.template.spec.containers[0].image}": "proxy:desired",
+				"{.spec.template.spec.containers[1].image}": "web:live",
+			},
+		},
+		{
+			name:      "kubectl template",
+			jsonPaths: []string{"{.spec.replicas}"},
+			expected:  map[string]interface{}{"{.spec.replicas}": float64(5)},
+		},
+		{
+			name:      "wildcard missing from live",
+			jsonPaths: []string{"spec.template.spec.containers[*].imagePullPolicy"},
+			expected:  map[string]interface{}{"{.spec.template.spec.containers[1].imagePullPolicy}": nil},
+		},
+		{
+			name:      "missing path",
+			jsonPaths: []string{"spec.strategy.rollingUpdate", "spec.template.spec.initContainers[*].image"},
+			expected:  map[string]interface{}{"{.spec.replicas}": float64(2)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			live := unstructuredFromYAML(t, ignoreLive)
+			desired := unstructuredFromYAML(t, ignoreDesired)
+			if err := IgnoreFields(live, desired, test.jsonPointers, test.jsonPaths); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(live, unstructuredFromYAML(t, ignoreLive)) {
+				t.Error("live modified")
+			}
+			for path, expected := range test.expected {
+				values, err := evaluate(path, desired.Object)
+				if err != nil {
+					t.Fatal(err)
+				}
+				switch {
+				case expected == nil && len(values) > 0:
+					t.Errorf("%s: expected removed, got %v", path, values)
+				case expected != nil && (len(values) != 1 || values[0] != expected):
+					t.Errorf("%s: expected %v, got %v", path, expected, values)
+				}
+			}
+		})
+	}
+}
+
+func TestIgnoreFieldsTyped(t *testing.T) {
+	live := &appsv1.Deployment{}
+	if err := yaml.Unmarshal([]byte(ignoreLive), live); err != nil {
+		t.Fatal(err)
+	}
+	desired := &appsv1.Deployment{}
+	if err := yaml.Unmarshal([]byte(ignoreDesired), desired); err != nil {
+		t.Fatal(err)
+	}
+	if err := IgnoreFields(live, desired, []string{REPLICAS_POINTER}, []string{"spec.template.spec.containers[*].image"}); err != nil {
+		t.Fatal(err)
+	}
+	if *desired.Spec.Replicas != 5 {
+		t.Errorf("replicas not ignored: %d", *desired.Spec.Replicas)
+	}
+	if len(desired.Spec.Template.Spec.Containers) != 2 || desired.Spec.Template.Spec.Containers[1].Image != "web:live" ||
+		desired.Spec.Template.Spec.Containers[1].ImagePullPolicy != "Always" {
+		t.Errorf("unexpected containers: %+v", desired.Spec.Template.Spec.Containers)
+	}
+}
+
+func TestValidateIgnoreFields(t *testing.T) {
+	tests := []struct {
+		jsonPointers []string
+		jsonPaths    []string
+		valid        bool
+	}{
+		{jsonPointers: []string{"/spec/replicas", "/metadata/annotations/a~1b"}, valid: true},
+		{jsonPointers: []string{"spec/replicas"}},
+		{jsonPaths: []string{"spec.template.spec.containers[*].image", "{.spec.replicas}", "$.webhooks[0:2].clientConfig"}, valid: true},
+		{jsonPaths: []string{`spec.containers[?(@.name=="web")].image`, "metadata..name"}, valid: true},
+		{jsonPaths: []string{"spec.containers[0"}},
+		{jsonPaths: []string{"{.spec.replicas} {.spec.paused}"}},
+	}
+
+	for _, test := range tests {
+		err := ValidateIgnoreFields(test.jsonPointers, test.jsonPaths)
+		if test.valid && err != nil {
+			t.Errorf("%v %v: %v", test.jsonPointers, test.jsonPaths, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%v %v: expected an error", test.jsonPointers, test.jsonPaths)
+		}
+	}
+}
+
+// evaluate returns the values of the JSONPath template path in obj
+func evaluate(path string, obj map[string]interface{}) ([]interface{}, error) {
+	nodes, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	values := []interface{}{}
+	for _, found := range expandJSONPath(obj, nodes, nil) {
+		value, _ := getAt(obj, found)
+		values = append(values, value)
+	}
+	return values, nil
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package objectmatch
+
+import (
+	"encoding/json"
+
+	"github.com/goph/emperror"
+	imagev1 "github.com/openshift/api/image/v1"
+)
+
+type imageStreamMatcher struct {
+	objectMatcher ObjectMatcher
+}
+
+func NewImageStreamMatcher(objectMatcher ObjectMatcher) *imageStreamMatcher {
+	return &imageStreamMatcher{
+		objectMatcher: objectMatcher,
+	}
+}
+
+// Match compares two imagev1.ImageStream objects, ignoring the generation of the tags
+func (m imageStreamMatcher) Match(old, new *imagev1.ImageStream) (bool, error) {
+	type ImageStream struct {
+		ObjectMeta
+		Spec imagev1.ImageStreamSpec
+	}
+
+	oldData, err := json.Marshal(ImageStream{
+		ObjectMeta: m.objectMatcher.GetObjectMeta(old.ObjectMeta),
+		Spec:       imageStreamSpec(old),
+	})
+	if err != nil {
+		return false, emperror.WrapWith(err, "could not marshal old object", "name", old.Name)
+	}
+	newObject := ImageStream{
+		ObjectMeta: m.objectMatcher.GetObjectMeta(new.ObjectMeta),
+		Spec:       imageStreamSpec(new),
+	}
+	newData, err := json.Marshal(newObject)
+	if err != nil {
+		return false, emperror.WrapWith(err, "could not marshal new object", "name", new.Name)
+	}
+
+	matched, err := m.objectMatcher.MatchJSON(oldData, newData, newObject)
+	if err != nil {
+		return false, emperror.WrapWith(err, "could not match objects", "name", new.Name)
+	}
+
+	return matched, nil
+}
+
+// imageStreamSpec returns a copy of the spec without the tag generations set by the server
+func imageStreamSpec(imageStream *imagev1.ImageStream) imagev1.ImageStreamSpec {
+	spec := *imageStream.Spec.DeepCopy()
+	for i := range spec.Tags {
+		spec.Tags[i].Generation = nil
+	}
+	return spec
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package objectmatch
+
+import (
+	"encoding/json"
+
+	"github.com/goph/emperror"
+	batchv1 "k8s.io/api/batch/v1"
+)
+
+// Labels and selector keys added by the job controller unless the selector is set manually
+const jobControllerUIDLabel = "controller-uid"
+const jobNameLabel = "job-name"
+
+type jobMatcher struct {
+	objectMatcher ObjectMatcher
+}
+
+func NewJobMatcher(objectMatcher ObjectMatcher) *jobMatcher {
+	return &jobMatcher{
+		objectMatcher: objectMatcher,
+	}
+}
+
+// Match compares two batchv1.Job objects, ignoring the selector and labels generated by the job controller
+func (m jobMatcher) Match(old, new *batchv1.Job) (bool, error) {
+	type Job struct {
+		ObjectMeta
+		Spec batchv1.JobSpec
+	}
+
+	oldData, err := json.Marshal(Job{
+		ObjectMeta: m.objectMatcher.GetObjectMeta(old.ObjectMeta),
+		Spec:       jobSpec(old),
+	})
+	if err != nil {
+		return false, emperror.WrapWith(err, "could not marshal old object", "name", old.Name)
+	}
+	newObject := Job{
+		ObjectMeta: m.objectMatcher.GetObjectMeta(new.ObjectMeta),
+		Spec:       jobSpec(new),
+	}
+	newData, err := json.Marshal(newObject)
+	if err != nil {
+		return false, emperror.WrapWith(err, "could not marshal new object", "name", new.Name)
+	}
+
+	matched, err := m.objectMatcher.MatchJSON(oldData, newData, newObject)
+	if err != nil {
+		return false, emperror.WrapWith(err, "could not match objects", "name", new.Name)
+	}
+
+	return matched, nil
+}
+
+// jobSpec returns a copy of the spec without the generated selector and template labels
+func jobSpec(job *batchv1.Job) batchv1.JobSpec {
+	spec := *job.Spec.DeepCopy()
+	if spec.ManualSelector != nil && *spec.ManualSelector {
+		return spec
+	}
+
+	spec.Selector = nil
+	delete(spec.Template.Labels, jobControllerUIDLabel)
+	delete(spec.Template.Labels, jobNameLabel)
+	if len(spec.Template.Labels) == 0 {
+		spec.Template.Labels = nil
+	}
+	return spec
+}
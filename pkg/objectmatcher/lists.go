@@ -0,0 +1,101 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package objectmatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/goph/emperror"
+)
+
+// unorderedLists are the fields holding lists whose order does not matter to the API server, with the candidate merge
+// keys of their items. Strategic merge patches already match these lists by key, JSON merge patches compare them
+// item by item.
+var unorderedLists = map[string][]string{
+	"env":          {"name"},
+	"ports":        {"containerPort", "port", "name"},
+	"volumes":      {"name"},
+	"volumeMounts": {"mountPath"},
+}
+
+// sortUnorderedLists returns the JSON object with the items of its unordered lists sorted by their merge key
+func sortUnorderedLists(data []byte) ([]byte, error) {
+	var obj map[string]interface{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, emperror.Wrap(err, "could not unmarshal object")
+	}
+	sortListsInValue(obj)
+	return json.Marshal(obj)
+}
+
+func sortListsInValue(value interface{}) {
+	switch typedVal := value.(type) {
+	case map[string]interface{}:
+		for key, val := range typedVal {
+			if list, ok := val.([]interface{}); ok {
+				if keys, ok := unorderedLists[key]; ok {
+					sortByMergeKey(list, keys)
+				}
+			}
+			sortListsInValue(val)
+		}
+	case []interface{}:
+		for _, item := range typedVal {
+			sortListsInValue(item)
+		}
+	}
+}
+
+// sortByMergeKey sorts list by the first of keys that all of its items have, lists without such a key are left alone
+func sortByMergeKey(list []interface{}, keys []string) {
+	for _, key := range keys {
+		if values, ok := mergeKeyValues(list, key); ok {
+			sort.Stable(byMergeKey{list: list, values: values})
+			return
+		}
+	}
+}
+
+func mergeKeyValues(list []interface{}, key string) ([]string, bool) {
+	values := make([]string, len(list))
+	for i, item := range list {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		value, ok := m[key]
+		if !ok {
+			return nil, false
+		}
+		values[i] = fmt.Sprint(value)
+	}
+	return values, true
+}
+
+type byMergeKey struct {
+	list   []interface{}
+	values []string
+}
+
+func (b byMergeKey) Len() int           { return len(b.list) }
+func (b byMergeKey) Less(i, j int) bool { return b.values[i] < b.values[j] }
+func (b byMergeKey) Swap(i, j int) {
+	b.list[i], b.list[j] = b.list[j], b.list[i]
+	b.values[i], b.values[j] = b.values[j], b.values[i]
+}
@@ -0,0 +1,759 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package objectmatch
+
+import (
+	"testing"
+
+	oappsv1 "github.com/openshift/api/apps/v1"
+	buildv1 "github.com/openshift/api/build/v1"
+	imagev1 "github.com/openshift/api/image/v1"
+	routev1 "github.com/openshift/api/route/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+// matcherTest is a descriptor and the object the API server returns for it
+type matcherTest struct {
+	name    string
+	live    runtime.Object
+	desired runtime.Object
+	// change makes a change to desired that the API server would not undo
+	change func(obj runtime.Object)
+}
+
+// testMatchers checks that the live object of each test matches its descriptor, and no longer matches it once changed
+func testMatchers(t *testing.T, tests []matcherTest) {
+	defaults := runtime.NewScheme()
+	AddDefaultingFuncs(defaults)
+	om := NewWithDefaulter(logf.Log, defaults)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			matched, err := om.Match(test.live, test.desired)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !matched {
+				result, _ := om.Diff(test.live, test.desired)
+				t.Fatalf("live object does not match its descriptor:\n%s", result.Diff)
+			}
+
+			changed := test.desired.DeepCopyObject()
+			test.change(changed)
+			if matched, err = om.Match(test.live, changed); err != nil {
+				t.Fatal(err)
+			}
+			if matched {
+				t.Fatal("changed descriptor matches the live object")
+			}
+		})
+	}
+}
+
+const secretDescriptor = `
+apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+stringData:
+  password: hunter2
+data:
+  user: YWRtaW4=
+`
+
+// secretLive is secretDescriptor as returned by the API server, StringData is folded into Data
+const secretLive = `
+apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+  resourceVersion: "1234"
+type: Opaque
+data:
+  password: aHVudGVyMg==
+  user: YWRtaW4=
+`
+
+const jobDescriptor = `
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+spec:
+  template:
+    metadata:
+      labels:
+        app: migrate
+    spec:
+      restartPolicy: Never
+      containers:
+      - name: migrate
+        image: quay.io/example/migrate:1.0
+`
+
+// jobLive is jobDescriptor as returned by the API server, with the selector and labels of the job controller
+const jobLive = `
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  resourceVersion: "1234"
+  labels:
+    app: migrate
+    controller-uid: 6c1d3b2a-8f52-11e9-bc42-526af7764f64
+    job-name: migrate
+spec:
+  backoffLimit: 6
+  completions: 1
+  parallelism: 1
+  selector:
+    matchLabels:
+      controller-uid: 6c1d3b2a-8f52-11e9-bc42-526af7764f64
+  template:
+    metadata:
+      labels:
+        app: migrate
+        controller-uid: 6c1d3b2a-8f52-11e9-bc42-526af7764f64
+        job-name: migrate
+    spec:
+      restartPolicy: Never
+      containers:
+      - name: migrate
+        image: quay.io/example/migrate:1.0
+        imagePullPolicy: IfNotPresent
+        terminationMessagePath: /dev/termination-log
+        terminationMessagePolicy: File
+      dnsPolicy: ClusterFirst
+      schedulerName: default-scheduler
+      securityContext: {}
+      terminationGracePeriodSeconds: 30
+status:
+  succeeded: 1
+  startTime: "2019-06-12T10:00:00Z"
+  completionTime: "2019-06-12T10:01:00Z"
+`
+
+const cronJobDescriptor = `
+apiVersion: batch/v1beta1
+kind: CronJob
+metadata:
+  name: report
+spec:
+  schedule: "0 * * * *"
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          restartPolicy: OnFailure
+          containers:
+          - name: report
+            image: quay.io/example/report:1.0
+`
+
+// cronJobLive is cronJobDescriptor as returned by the API server after it has scheduled a job
+const cronJobLive = `
+apiVersion: batch/v1beta1
+kind: CronJob
+metadata:
+  name: report
+  resourceVersion: "1234"
+spec:
+  schedule: "0 * * * *"
+  concurrencyPolicy: Allow
+  suspend: false
+  successfulJobsHistoryLimit: 3
+  failedJobsHistoryLimit: 1
+  jobTemplate:
+    metadata:
+      creationTimestamp: null
+    spec:
+      template:
+        metadata:
+          creationTimestamp: null
+        spec:
+          restartPolicy: OnFailure
+          containers:
+          - name: report
+            image: quay.io/example/report:1.0
+            imagePullPolicy: IfNotPresent
+            terminationMessagePath: /dev/termination-log
+            terminationMessagePolicy: File
+          dnsPolicy: ClusterFirst
+          schedulerName: default-scheduler
+          securityContext: {}
+          terminationGracePeriodSeconds: 30
+status:
+  lastScheduleTime: "2019-06-12T10:00:00Z"
+  active:
+  - kind: Job
+    name: report-1560333600
+    namespace: apps
+`
+
+const statefulSetDescriptor = `
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: db
+spec:
+  serviceName: db
+  selector:
+    matchLabels:
+      app: db
+  template:
+    metadata:
+      labels:
+        app: db
+    spec:
+      containers:
+      - name: db
+        image: quay.io/example/db:1.0
+        volumeMounts:
+        - name: data
+          mountPath: /var/lib/db
+  volumeClaimTemplates:
+  - metadata:
+      name: data
+    spec:
+      accessModes:
+      - ReadWriteOnce
+      resources:
+        requests:
+          storage: 1Gi
+`
+
+// statefulSetLive is statefulSetDescriptor as returned by the API server, with the status of the claim templates
+const statefulSetLive = `
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: db
+  resourceVersion: "1234"
+  generation: 1
+spec:
+  replicas: 1
+  revisionHistoryLimit: 10
+  podManagementPolicy: OrderedReady
+  serviceName: db
+  updateStrategy:
+    type: RollingUpdate
+    rollingUpdate:
+      partition: 0
+  selector:
+    matchLabels:
+      app: db
+  template:
+    metadata:
+      labels:
+        app: db
+    spec:
+      containers:
+      - name: db
+        image: quay.io/example/db:1.0
+        imagePullPolicy: IfNotPresent
+        volumeMounts:
+        - name: data
+          mountPath: /var/lib/db
+        terminationMessagePath: /dev/termination-log
+        terminationMessagePolicy: File
+      dnsPolicy: ClusterFirst
+      restartPolicy: Always
+      schedulerName: default-scheduler
+      securityContext: {}
+      terminationGracePeriodSeconds: 30
+  volumeClaimTemplates:
+  - metadata:
+      name: data
+      creationTimestamp: null
+    spec:
+      accessModes:
+      - ReadWriteOnce
+      resources:
+        requests:
+          storage: 1Gi
+      volumeMode: Filesystem
+    status:
+      phase: Pending
+status:
+  replicas: 1
+  readyReplicas: 1
+  currentRevision: db-5d4f8b7c9
+`
+
+const ingressDescriptor = `
+apiVersion: extensions/v1beta1
+kind: Ingress
+metadata:
+  name: web
+spec:
+  rules:
+  - host: web.example.com
+    http:
+      paths:
+      - path: /
+        backend:
+          serviceName: web
+          servicePort: 80
+`
+
+// ingressLive is ingressDescriptor as returned by the API server once the ingress controller has set its address
+const ingressLive = `
+apiVersion: extensions/v1beta1
+kind: Ingress
+metadata:
+  name: web
+  resourceVersion: "1234"
+  generation: 1
+spec:
+  rules:
+  - host: web.example.com
+    http:
+      paths:
+      - path: /
+        backend:
+          serviceName: web
+          servicePort: 80
+status:
+  loadBalancer:
+    ingress:
+    - ip: 192.0.2.10
+`
+
+const networkPolicyDescriptor = `
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: web
+spec:
+  podSelector:
+    matchLabels:
+      app: web
+  ingress:
+  - from:
+    - podSelector:
+        matchLabels:
+          app: router
+    ports:
+    - port: 8080
+`
+
+// networkPolicyLive is networkPolicyDescriptor as returned by the API server, with its policy types and protocols
+const networkPolicyLive = `
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: web
+  resourceVersion: "1234"
+  generation: 1
+spec:
+  podSelector:
+    matchLabels:
+      app: web
+  ingress:
+  - from:
+    - podSelector:
+        matchLabels:
+          app: router
+    ports:
+    - port: 8080
+      protocol: TCP
+  policyTypes:
+  - Ingress
+`
+
+func TestMatchLiveObjects(t *testing.T) {
+	testMatchers(t, []matcherTest{
+		{
+			name:    "Secret",
+			live:    unmarshalObject(t, secretLive, &corev1.Secret{}),
+			desired: unmarshalObject(t, secretDescriptor, &corev1.Secret{}),
+			change: func(obj runtime.Object) {
+				obj.(*corev1.Secret).StringData["password"] = "correct horse"
+			},
+		},
+		{
+			name:    "Secret type",
+			live:    unmarshalObject(t, secretLive, &corev1.Secret{}),
+			desired: unmarshalObject(t, secretDescriptor, &corev1.Secret{}),
+			change: func(obj runtime.Object) {
+				obj.(*corev1.Secret).Type = corev1.SecretTypeBasicAuth
+			},
+		},
+		{
+			name:    "Job",
+			live:    unmarshalObject(t, jobLive, &batchv1.Job{}),
+			desired: unmarshalObject(t, jobDescriptor, &batchv1.Job{}),
+			change: func(obj runtime.Object) {
+				obj.(*batchv1.Job).Spec.Template.Spec.Containers[0].Image = "quay.io/example/migrate:1.1"
+			},
+		},
+		{
+			name:    "Job labels",
+			live:    unmarshalObject(t, jobLive, &batchv1.Job{}),
+			desired: unmarshalObject(t, jobDescriptor, &batchv1.Job{}),
+			change: func(obj runtime.Object) {
+				obj.(*batchv1.Job).Spec.Template.Labels["app"] = "report"
+			},
+		},
+		{
+			name:    "CronJob",
+			live:    unmarshalObject(t, cronJobLive, &batchv1beta1.CronJob{}),
+			desired: unmarshalObject(t, cronJobDescriptor, &batchv1beta1.CronJob{}),
+			change: func(obj runtime.Object) {
+				obj.(*batchv1beta1.CronJob).Spec.Schedule = "0 0 * * *"
+			},
+		},
+		{
+			name:    "StatefulSet",
+			live:    unmarshalObject(t, statefulSetLive, &appsv1.StatefulSet{}),
+			desired: unmarshalObject(t, statefulSetDescriptor, &appsv1.StatefulSet{}),
+			change: func(obj runtime.Object) {
+				obj.(*appsv1.StatefulSet).Spec.Template.Spec.Containers[0].Image = "quay.io/example/db:1.1"
+			},
+		},
+		{
+			name:    "StatefulSet claim templates",
+			live:    unmarshalObject(t, statefulSetLive, &appsv1.StatefulSet{}),
+			desired: unmarshalObject(t, statefulSetDescriptor, &appsv1.StatefulSet{}),
+			change: func(obj runtime.Object) {
+				obj.(*appsv1.StatefulSet).Spec.VolumeClaimTemplates[0].Spec.AccessModes[0] = corev1.ReadWriteMany
+			},
+		},
+		{
+			name:    "Ingress",
+			live:    unmarshalObject(t, ingressLive, &extensionsv1beta1.Ingress{}),
+			desired: unmarshalObject(t, ingressDescriptor, &extensionsv1beta1.Ingress{}),
+			change: func(obj runtime.Object) {
+				obj.(*extensionsv1beta1.Ingress).Spec.Rules[0].HTTP.Paths[0].Backend.ServicePort = intstr.FromInt(8080)
+			},
+		},
+		{
+			name:    "NetworkPolicy",
+			live:    unmarshalObject(t, networkPolicyLive, &networkingv1.NetworkPolicy{}),
+			desired: unmarshalObject(t, networkPolicyDescriptor, &networkingv1.NetworkPolicy{}),
+			change: func(obj runtime.Object) {
+				port := intstr.FromInt(9090)
+				obj.(*networkingv1.NetworkPolicy).Spec.Ingress[0].Ports[0].Port = &port
+			},
+		},
+		{
+			name:    "NetworkPolicy egress",
+			live:    unmarshalObject(t, networkPolicyLive, &networkingv1.NetworkPolicy{}),
+			desired: unmarshalObject(t, networkPolicyDescriptor, &networkingv1.NetworkPolicy{}),
+			change: func(obj runtime.Object) {
+				obj.(*networkingv1.NetworkPolicy).Spec.Egress = []networkingv1.NetworkPolicyEgressRule{{}}
+			},
+		},
+	})
+}
+
+const deploymentConfigDescriptor = `
+apiVersion: apps.openshift.io/v1
+kind: DeploymentConfig
+metadata:
+  name: web
+spec:
+  replicas: 2
+  selector:
+    app: web
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      containers:
+      - name: web
+        image: web:latest
+  triggers:
+  - type: ConfigChange
+  - type: ImageChange
+    imageChangeParams:
+      automatic: true
+      containerNames:
+      - web
+      from:
+        kind: ImageStreamTag
+        name: web:latest
+`
+
+// deploymentConfigLive is deploymentConfigDescriptor as returned by the API server once its image trigger fired
+const deploymentConfigLive = `
+apiVersion: apps.openshift.io/v1
+kind: DeploymentConfig
+metadata:
+  name: web
+  resourceVersion: "1234"
+  generation: 3
+spec:
+  replicas: 2
+  revisionHistoryLimit: 10
+  selector:
+    app: web
+  strategy:
+    type: Rolling
+    activeDeadlineSeconds: 21600
+    rollingParams:
+      intervalSeconds: 1
+      maxSurge: 25%
+      maxUnavailable: 25%
+      timeoutSeconds: 600
+      updatePeriodSeconds: 1
+    resources: {}
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      containers:
+      - name: web
+        image: docker-registry.default.svc:5000/apps/web@sha256:0d5d7bc3fb4bdc4ad4e5a2aeb1ae5f7ea7f6bc7de4e0d8e7a1d0d3a8b0f6c5e4
+        imagePullPolicy: Always
+        terminationMessagePath: /dev/termination-log
+        terminationMessagePolicy: File
+      dnsPolicy: ClusterFirst
+      restartPolicy: Always
+      schedulerName: default-scheduler
+      securityContext: {}
+      terminationGracePeriodSeconds: 30
+  triggers:
+  - type: ConfigChange
+  - type: ImageChange
+    imageChangeParams:
+      automatic: true
+      containerNames:
+      - web
+      from:
+        kind: ImageStreamTag
+        name: web:latest
+      lastTriggeredImage: docker-registry.default.svc:5000/apps/web@sha256:0d5d7bc3fb4bdc4ad4e5a2aeb1ae5f7ea7f6bc7de4e0d8e7a1d0d3a8b0f6c5e4
+status:
+  latestVersion: 2
+  observedGeneration: 3
+  replicas: 2
+  availableReplicas: 2
+`
+
+const routeDescriptor = `
+apiVersion: route.openshift.io/v1
+kind: Route
+metadata:
+  name: web
+spec:
+  to:
+    kind: Service
+    name: web
+  port:
+    targetPort: http
+`
+
+// routeLive is routeDescriptor as returned by the API server, with the host generated by the router
+const routeLive = `
+apiVersion: route.openshift.io/v1
+kind: Route
+metadata:
+  name: web
+  resourceVersion: "1234"
+  annotations:
+    openshift.io/host.generated: "true"
+spec:
+  host: web-apps.apps.example.com
+  to:
+    kind: Service
+    name: web
+    weight: 100
+  port:
+    targetPort: http
+  wildcardPolicy: None
+status:
+  ingress:
+  - host: web-apps.apps.example.com
+    routerName: router
+    wildcardPolicy: None
+    conditions:
+    - type: Admitted
+      status: "True"
+      lastTransitionTime: "2019-06-12T10:00:00Z"
+`
+
+const imageStreamDescriptor = `
+apiVersion: image.openshift.io/v1
+kind: ImageStream
+metadata:
+  name: web
+spec:
+  tags:
+  - name: "1.0"
+    from:
+      kind: DockerImage
+      name: quay.io/example/web:1.0
+`
+
+// imageStreamLive is imageStreamDescriptor as returned by the API server after its tag has been imported
+const imageStreamLive = `
+apiVersion: image.openshift.io/v1
+kind: ImageStream
+metadata:
+  name: web
+  resourceVersion: "1234"
+  generation: 2
+spec:
+  lookupPolicy:
+    local: false
+  tags:
+  - name: "1.0"
+    annotations: null
+    from:
+      kind: DockerImage
+      name: quay.io/example/web:1.0
+    generation: 2
+    importPolicy: {}
+    referencePolicy:
+      type: Source
+status:
+  dockerImageRepository: docker-registry.default.svc:5000/apps/web
+  tags:
+  - tag: "1.0"
+    items:
+    - created: "2019-06-12T10:00:00Z"
+      dockerImageReference: quay.io/example/web@sha256:0d5d7bc3fb4bdc4ad4e5a2aeb1ae5f7ea7f6bc7de4e0d8e7a1d0d3a8b0f6c5e4
+      image: sha256:0d5d7bc3fb4bdc4ad4e5a2aeb1ae5f7ea7f6bc7de4e0d8e7a1d0d3a8b0f6c5e4
+      generation: 2
+`
+
+const buildConfigDescriptor = `
+apiVersion: build.openshift.io/v1
+kind: BuildConfig
+metadata:
+  name: web
+spec:
+  source:
+    git:
+      uri: https://github.com/example/web.git
+  strategy:
+    sourceStrategy:
+      from:
+        kind: ImageStreamTag
+        name: nodejs:10
+        namespace: openshift
+  output:
+    to:
+      kind: ImageStreamTag
+      name: web:latest
+  triggers:
+  - type: ConfigChange
+  - type: ImageChange
+    imageChange: {}
+`
+
+// buildConfigLive is buildConfigDescriptor as returned by the API server after a few builds
+const buildConfigLive = `
+apiVersion: build.openshift.io/v1
+kind: BuildConfig
+metadata:
+  name: web
+  resourceVersion: "1234"
+spec:
+  runPolicy: Serial
+  successfulBuildsHistoryLimit: 5
+  failedBuildsHistoryLimit: 5
+  nodeSelector: null
+  postCommit: {}
+  resources: {}
+  source:
+    type: Git
+    git:
+      uri: https://github.com/example/web.git
+  strategy:
+    type: Source
+    sourceStrategy:
+      from:
+        kind: ImageStreamTag
+        name: nodejs:10
+        namespace: openshift
+  output:
+    to:
+      kind: ImageStreamTag
+      name: web:latest
+  triggers:
+  - type: ConfigChange
+  - type: ImageChange
+    imageChange:
+      lastTriggeredImageID: docker-registry.default.svc:5000/openshift/nodejs@sha256:0d5d7bc3fb4bdc4ad4e5a2aeb1ae5f7ea7f6bc7de4e0d8e7a1d0d3a8b0f6c5e4
+status:
+  lastVersion: 3
+`
+
+func TestMatchLiveOpenShiftObjects(t *testing.T) {
+	testMatchers(t, []matcherTest{
+		{
+			name:    "DeploymentConfig",
+			live:    unmarshalObject(t, deploymentConfigLive, &oappsv1.DeploymentConfig{}),
+			desired: unmarshalObject(t, deploymentConfigDescriptor, &oappsv1.DeploymentConfig{}),
+			change: func(obj runtime.Object) {
+				obj.(*oappsv1.DeploymentConfig).Spec.Replicas = 3
+			},
+		},
+		{
+			name:    "DeploymentConfig trigger",
+			live:    unmarshalObject(t, deploymentConfigLive, &oappsv1.DeploymentConfig{}),
+			desired: unmarshalObject(t, deploymentConfigDescriptor, &oappsv1.DeploymentConfig{}),
+			change: func(obj runtime.Object) {
+				obj.(*oappsv1.DeploymentConfig).Spec.Triggers[1].ImageChangeParams.From.Name = "web:1.0"
+			},
+		},
+		{
+			name:    "Route",
+			live:    unmarshalObject(t, routeLive, &routev1.Route{}),
+			desired: unmarshalObject(t, routeDescriptor, &routev1.Route{}),
+			change: func(obj runtime.Object) {
+				obj.(*routev1.Route).Spec.To.Name = "api"
+			},
+		},
+		{
+			name:    "Route host",
+			live:    unmarshalObject(t, routeLive, &routev1.Route{}),
+			desired: unmarshalObject(t, routeDescriptor, &routev1.Route{}),
+			change: func(obj runtime.Object) {
+				obj.(*routev1.Route).Spec.Host = "web.example.com"
+			},
+		},
+		{
+			name:    "ImageStream",
+			live:    unmarshalObject(t, imageStreamLive, &imagev1.ImageStream{}),
+			desired: unmarshalObject(t, imageStreamDescriptor, &imagev1.ImageStream{}),
+			change: func(obj runtime.Object) {
+				obj.(*imagev1.ImageStream).Spec.Tags[0].From.Name = "quay.io/example/web:1.1"
+			},
+		},
+		{
+			name:    "BuildConfig",
+			live:    unmarshalObject(t, buildConfigLive, &buildv1.BuildConfig{}),
+			desired: unmarshalObject(t, buildConfigDescriptor, &buildv1.BuildConfig{}),
+			change: func(obj runtime.Object) {
+				obj.(*buildv1.BuildConfig).Spec.Source.Git.Ref = "release"
+			},
+		},
+	})
+}
@@ -40,11 +40,9 @@ func (m mutatingWebhookConfigurationMatcher) Match(old, new *admissionv1beta1.Mu
 		Webhooks []admissionv1beta1.Webhook `json:"webhooks,omitempty" patchStrategy:"merge" patchMergeKey:"name"`
 	}
 
-	old.Webhooks = nullCABundleConditionally(old.Webhooks, new.Webhooks)
-
 	oldData, err := json.Marshal(MutatingWebhookConfiguration{
 		ObjectMeta: m.objectMatcher.GetObjectMeta(old.ObjectMeta),
-		Webhooks:   old.Webhooks,
+		Webhooks:   nullCABundleConditionally(old.Webhooks, new.Webhooks),
 	})
 	if err != nil {
 		return false, emperror.WrapWith(err, "could not marshal old object", "name", old.Name)
@@ -67,18 +65,24 @@ func (m mutatingWebhookConfigurationMatcher) Match(old, new *admissionv1beta1.Mu
 	return matched, nil
 }
 
-// nullCABundleConditionally nils ClientConfig.CABundle value in the old object if it is nil in the new to avoid conflict
+// nullCABundleConditionally returns a copy of the old webhooks with ClientConfig.CABundle nil where it is nil in the
+// new ones to avoid conflict
 func nullCABundleConditionally(oldWebhooks, newWebhooks []admissionv1beta1.Webhook) []admissionv1beta1.Webhook {
-	for i, wh := range oldWebhooks {
+	if oldWebhooks == nil {
+		return nil
+	}
+
+	webhooks := make([]admissionv1beta1.Webhook, len(oldWebhooks))
+	copy(webhooks, oldWebhooks)
+	for i, wh := range webhooks {
 		nwh := getWebhookByName(wh.Name, newWebhooks)
 		if nwh == nil || nwh.ClientConfig.CABundle != nil {
 			continue
 		}
-		wh.ClientConfig.CABundle = nil
-		oldWebhooks[i] = wh
+		webhooks[i].ClientConfig.CABundle = nil
 	}
 
-	return oldWebhooks
+	return webhooks
 }
 
 // getWebhookByName gets webhook from webhooks by its name
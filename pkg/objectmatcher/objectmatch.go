@@ -19,208 +19,238 @@ package objectmatch
 import (
 	"encoding/json"
 	"reflect"
+	"strings"
+	"sync"
 
-	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/cvicens/rocketeer-operator/pkg/redact"
 	"github.com/go-logr/logr"
 	"github.com/goph/emperror"
+	oappsv1 "github.com/openshift/api/apps/v1"
+	buildv1 "github.com/openshift/api/build/v1"
+	imagev1 "github.com/openshift/api/image/v1"
+	routev1 "github.com/openshift/api/route/v1"
 	"github.com/pkg/errors"
 	admissionv1beta1 "k8s.io/api/admissionregistration/v1beta1"
 	appsv1 "k8s.io/api/apps/v1"
 	autoscalev2beta1 "k8s.io/api/autoscaling/v2beta1"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
 	corev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	networkingv1 "k8s.io/api/networking/v1"
 	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	extensionsobj "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
+const setElementOrderDirectivePrefix = "$setElementOrder/"
+
 type ObjectMatcher interface {
 	Match(old, new interface{}) (bool, error)
 	GetObjectMeta(objectMeta metav1.ObjectMeta) ObjectMeta
 	MatchJSON(old, new []byte, obj interface{}) (bool, error)
+	// Diff compares old and new like Match and describes how they differ
+	Diff(old, new interface{}) (*MatchResult, error)
+	DiffJSON(old, new []byte, obj interface{}) (*MatchResult, error)
+	// RegisterMatcher sets the matcher used for objects of type objType, e.g. reflect.TypeOf(&corev1.Secret{})
+	RegisterMatcher(objType reflect.Type, matcher Matcher)
+	// RegisterKindMatcher sets the matcher used for objects reporting gvk, e.g. unstructured objects
+	RegisterKindMatcher(gvk schema.GroupVersionKind, matcher Matcher)
+}
+
+// Matcher compares two objects of the type or kind it has been registered for
+type Matcher interface {
+	Match(old, new interface{}) (bool, error)
+}
+
+// MatcherFunc is a function implementing Matcher
+type MatcherFunc func(old, new interface{}) (bool, error)
+
+func (f MatcherFunc) Match(old, new interface{}) (bool, error) {
+	return f(old, new)
 }
 
 type objectMatcher struct {
 	logger logr.Logger
+	*registry
+
+	// defaulter fills in the defaults of the desired object before it is compared, it may be nil
+	defaulter runtime.ObjectDefaulter
+
+	// record makes MatchJSON keep its outcome in last, it is only set on the matchers created by Diff
+	record bool
+	last   *MatchResult
+}
+
+// registry holds the registered matchers, it is shared by an objectMatcher and the recorders of its diffs
+type registry struct {
+	mu           sync.RWMutex
+	typeMatchers map[reflect.Type]Matcher
+	kindMatchers map[schema.GroupVersionKind]Matcher
 }
 
 func New(logger logr.Logger) ObjectMatcher {
 	return &objectMatcher{
 		logger: logger,
+		registry: &registry{
+			typeMatchers: map[reflect.Type]Matcher{},
+			kindMatchers: map[schema.GroupVersionKind]Matcher{},
+		},
 	}
 }
 
+// NewWithDefaulter returns an ObjectMatcher that runs the defaulting functions of defaulter on a copy of the desired
+// object before comparing, so that fields left to the API server's defaults do not show up as differences
+func NewWithDefaulter(logger logr.Logger, defaulter runtime.ObjectDefaulter) ObjectMatcher {
+	om := New(logger).(*objectMatcher)
+	om.defaulter = defaulter
+	return om
+}
+
+func (om *objectMatcher) RegisterMatcher(objType reflect.Type, matcher Matcher) {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	om.typeMatchers[objType] = matcher
+}
+
+func (om *objectMatcher) RegisterKindMatcher(gvk schema.GroupVersionKind, matcher Matcher) {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	om.kindMatchers[gvk] = matcher
+}
+
+// Match compares old and new with the matcher registered for their kind, then for their type. Objects without a
+// registered matcher are compared with the generic JSON matcher.
 func (om *objectMatcher) Match(old, new interface{}) (bool, error) {
 	if reflect.TypeOf(old) != reflect.TypeOf(new) {
 		return false, emperror.With(errors.New("old and new object types mismatch"), "oldType", reflect.TypeOf(old), "newType", reflect.TypeOf(new))
 	}
 
-	switch old.(type) {
-	default:
-		return false, nil
-	case *unstructured.Unstructured:
-		oldObject := old.(*unstructured.Unstructured)
-		newObject := new.(*unstructured.Unstructured)
-
-		m := NewUnstructuredMatcher(om)
-		ok, err := m.Match(oldObject, newObject)
-		if err != nil {
-			return false, errors.WithStack(err)
-		}
-		return ok, nil
-	case *corev1.ServiceAccount:
-		oldObject := old.(*corev1.ServiceAccount)
-		newObject := new.(*corev1.ServiceAccount)
-
-		m := NewServiceAccountMatcher(om)
-		ok, err := m.Match(oldObject, newObject)
-		if err != nil {
-			return false, errors.WithStack(err)
-		}
-		return ok, nil
-	case *rbacv1.ClusterRole:
-		oldObject := old.(*rbacv1.ClusterRole)
-		newObject := new.(*rbacv1.ClusterRole)
-
-		m := NewClusterRoleMatcher(om)
-		ok, err := m.Match(oldObject, newObject)
-		if err != nil {
-			return false, errors.WithStack(err)
-		}
-		return ok, nil
-	case *rbacv1.ClusterRoleBinding:
-		oldObject := old.(*rbacv1.ClusterRoleBinding)
-		newObject := new.(*rbacv1.ClusterRoleBinding)
-
-		m := NewClusterRoleBindingMatcher(om)
-		ok, err := m.Match(oldObject, newObject)
-		if err != nil {
-			return false, errors.WithStack(err)
-		}
-		return ok, nil
-	case *appsv1.Deployment:
-		oldObject := old.(*appsv1.Deployment)
-		newObject := new.(*appsv1.Deployment)
-
-		m := NewDeploymentMatcher(om)
-		ok, err := m.Match(oldObject, newObject)
-		if err != nil {
-			return false, errors.WithStack(err)
-		}
-		return ok, nil
-	case *corev1.Service:
-		oldObject := old.(*corev1.Service)
-		newObject := new.(*corev1.Service)
-
-		m := NewServiceMatcher(om)
-		ok, err := m.Match(oldObject, newObject)
-		if err != nil {
-			return false, errors.WithStack(err)
-		}
-		return ok, nil
-	case *corev1.Pod:
-		oldObject := old.(*corev1.Pod)
-		newObject := new.(*corev1.Pod)
-
-		m := NewPodMatcher(om)
-		ok, err := m.Match(oldObject, newObject)
-		if err != nil {
-			return false, errors.WithStack(err)
-		}
-		return ok, nil
-	case *corev1.PersistentVolumeClaim:
-		oldObject := old.(*corev1.PersistentVolumeClaim)
-		newObject := new.(*corev1.PersistentVolumeClaim)
-
-		m := NewPvcMatcher(om)
-		ok, err := m.Match(oldObject, newObject)
-		if err != nil {
-			return false, errors.WithStack(err)
-		}
-		return ok, nil
-	case *corev1.ConfigMap:
-		oldObject := old.(*corev1.ConfigMap)
-		newObject := new.(*corev1.ConfigMap)
-
-		m := NewConfigMapMatcher(om)
-		ok, err := m.Match(oldObject, newObject)
-		if err != nil {
-			return false, errors.WithStack(err)
-		}
-		return ok, nil
-	case *extensionsobj.CustomResourceDefinition:
-		oldObject := old.(*extensionsobj.CustomResourceDefinition)
-		newObject := new.(*extensionsobj.CustomResourceDefinition)
-
-		m := NewCRDMatcher(om)
-		ok, err := m.Match(oldObject, newObject)
-		if err != nil {
-			return false, errors.WithStack(err)
-		}
-		return ok, nil
-	case *autoscalev2beta1.HorizontalPodAutoscaler:
-		oldObject := old.(*autoscalev2beta1.HorizontalPodAutoscaler)
-		newObject := new.(*autoscalev2beta1.HorizontalPodAutoscaler)
-
-		m := NewHorizontalPodAutoscalerMatcher(om)
-		ok, err := m.Match(oldObject, newObject)
-		if err != nil {
-			return false, errors.WithStack(err)
-		}
-		return ok, nil
-	case *admissionv1beta1.MutatingWebhookConfiguration:
-		oldObject := old.(*admissionv1beta1.MutatingWebhookConfiguration)
-		newObject := new.(*admissionv1beta1.MutatingWebhookConfiguration)
-
-		m := NewMutatingWebhookConfigurationMatcher(om)
-		ok, err := m.Match(oldObject, newObject)
-		if err != nil {
-			return false, errors.WithStack(err)
-		}
-		return ok, nil
-	case *policyv1beta1.PodDisruptionBudget:
-		oldObject := old.(*policyv1beta1.PodDisruptionBudget)
-		newObject := new.(*policyv1beta1.PodDisruptionBudget)
-
-		m := NewPodDisruptionBudgetMatcher(om)
-		ok, err := m.Match(oldObject, newObject)
-		if err != nil {
-			return false, errors.WithStack(err)
-		}
-		return ok, nil
-	case *appsv1.DaemonSet:
-		oldObject := old.(*appsv1.DaemonSet)
-		newObject := new.(*appsv1.DaemonSet)
-
-		m := NewDaemonSetMatcher(om)
-		ok, err := m.Match(oldObject, newObject)
-		if err != nil {
-			return false, errors.WithStack(err)
-		}
-		return ok, nil
-	case *rbacv1.Role:
-		oldObject := old.(*rbacv1.Role)
-		newObject := new.(*rbacv1.Role)
-
-		m := NewRoleMatcher(om)
-		ok, err := m.Match(oldObject, newObject)
-		if err != nil {
-			return false, errors.WithStack(err)
-		}
-		return ok, nil
-	case *rbacv1.RoleBinding:
-		oldObject := old.(*rbacv1.RoleBinding)
-		newObject := new.(*rbacv1.RoleBinding)
-
-		m := NewRoleBindingMatcher(om)
-		ok, err := m.Match(oldObject, newObject)
-		if err != nil {
-			return false, errors.WithStack(err)
+	new = om.withDefaults(new)
+	ok, err := om.matcherFor(new).Match(old, new)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	return ok, nil
+}
+
+// withDefaults returns a copy of obj with the defaults of om.defaulter set, or obj itself without a defaulter
+func (om *objectMatcher) withDefaults(obj interface{}) interface{} {
+	object, ok := obj.(runtime.Object)
+	if om.defaulter == nil || !ok {
+		return obj
+	}
+	defaulted := object.DeepCopyObject()
+	om.defaulter.Default(defaulted)
+	return defaulted
+}
+
+func (om *objectMatcher) matcherFor(obj interface{}) Matcher {
+	om.mu.RLock()
+	defer om.mu.RUnlock()
+
+	if object, ok := obj.(runtime.Object); ok {
+		if gvk := object.GetObjectKind().GroupVersionKind(); !gvk.Empty() {
+			if matcher, ok := om.kindMatchers[gvk]; ok {
+				return matcher
+			}
 		}
-		return ok, nil
 	}
+	if matcher, ok := om.typeMatchers[reflect.TypeOf(obj)]; ok {
+		return matcher
+	}
+	if match, ok := defaultMatchers[reflect.TypeOf(obj)]; ok {
+		return MatcherFunc(func(old, new interface{}) (bool, error) {
+			return match(om, old, new)
+		})
+	}
+	return NewDefaultMatcher(om)
+}
+
+// defaultMatchers compare the types known to the package, with the ObjectMatcher they are looked up from
+var defaultMatchers = map[reflect.Type]func(om ObjectMatcher, old, new interface{}) (bool, error){
+	reflect.TypeOf(&unstructured.Unstructured{}): func(om ObjectMatcher, old, new interface{}) (bool, error) {
+		return NewUnstructuredMatcher(om).Match(old.(*unstructured.Unstructured), new.(*unstructured.Unstructured))
+	},
+	reflect.TypeOf(&corev1.ServiceAccount{}): func(om ObjectMatcher, old, new interface{}) (bool, error) {
+		return NewServiceAccountMatcher(om).Match(old.(*corev1.ServiceAccount), new.(*corev1.ServiceAccount))
+	},
+	reflect.TypeOf(&rbacv1.ClusterRole{}): func(om ObjectMatcher, old, new interface{}) (bool, error) {
+		return NewClusterRoleMatcher(om).Match(old.(*rbacv1.ClusterRole), new.(*rbacv1.ClusterRole))
+	},
+	reflect.TypeOf(&rbacv1.ClusterRoleBinding{}): func(om ObjectMatcher, old, new interface{}) (bool, error) {
+		return NewClusterRoleBindingMatcher(om).Match(old.(*rbacv1.ClusterRoleBinding), new.(*rbacv1.ClusterRoleBinding))
+	},
+	reflect.TypeOf(&appsv1.Deployment{}): func(om ObjectMatcher, old, new interface{}) (bool, error) {
+		return NewDeploymentMatcher(om).Match(old.(*appsv1.Deployment), new.(*appsv1.Deployment))
+	},
+	reflect.TypeOf(&corev1.Service{}): func(om ObjectMatcher, old, new interface{}) (bool, error) {
+		return NewServiceMatcher(om).Match(old.(*corev1.Service), new.(*corev1.Service))
+	},
+	reflect.TypeOf(&corev1.Pod{}): func(om ObjectMatcher, old, new interface{}) (bool, error) {
+		return NewPodMatcher(om).Match(old.(*corev1.Pod), new.(*corev1.Pod))
+	},
+	reflect.TypeOf(&corev1.PersistentVolumeClaim{}): func(om ObjectMatcher, old, new interface{}) (bool, error) {
+		return NewPvcMatcher(om).Match(old.(*corev1.PersistentVolumeClaim), new.(*corev1.PersistentVolumeClaim))
+	},
+	reflect.TypeOf(&corev1.ConfigMap{}): func(om ObjectMatcher, old, new interface{}) (bool, error) {
+		return NewConfigMapMatcher(om).Match(old.(*corev1.ConfigMap), new.(*corev1.ConfigMap))
+	},
+	reflect.TypeOf(&extensionsobj.CustomResourceDefinition{}): func(om ObjectMatcher, old, new interface{}) (bool, error) {
+		return NewCRDMatcher(om).Match(old.(*extensionsobj.CustomResourceDefinition), new.(*extensionsobj.CustomResourceDefinition))
+	},
+	reflect.TypeOf(&autoscalev2beta1.HorizontalPodAutoscaler{}): func(om ObjectMatcher, old, new interface{}) (bool, error) {
+		return NewHorizontalPodAutoscalerMatcher(om).Match(old.(*autoscalev2beta1.HorizontalPodAutoscaler), new.(*autoscalev2beta1.HorizontalPodAutoscaler))
+	},
+	reflect.TypeOf(&admissionv1beta1.MutatingWebhookConfiguration{}): func(om ObjectMatcher, old, new interface{}) (bool, error) {
+		return NewMutatingWebhookConfigurationMatcher(om).Match(old.(*admissionv1beta1.MutatingWebhookConfiguration), new.(*admissionv1beta1.MutatingWebhookConfiguration))
+	},
+	reflect.TypeOf(&policyv1beta1.PodDisruptionBudget{}): func(om ObjectMatcher, old, new interface{}) (bool, error) {
+		return NewPodDisruptionBudgetMatcher(om).Match(old.(*policyv1beta1.PodDisruptionBudget), new.(*policyv1beta1.PodDisruptionBudget))
+	},
+	reflect.TypeOf(&appsv1.DaemonSet{}): func(om ObjectMatcher, old, new interface{}) (bool, error) {
+		return NewDaemonSetMatcher(om).Match(old.(*appsv1.DaemonSet), new.(*appsv1.DaemonSet))
+	},
+	reflect.TypeOf(&rbacv1.Role{}): func(om ObjectMatcher, old, new interface{}) (bool, error) {
+		return NewRoleMatcher(om).Match(old.(*rbacv1.Role), new.(*rbacv1.Role))
+	},
+	reflect.TypeOf(&rbacv1.RoleBinding{}): func(om ObjectMatcher, old, new interface{}) (bool, error) {
+		return NewRoleBindingMatcher(om).Match(old.(*rbacv1.RoleBinding), new.(*rbacv1.RoleBinding))
+	},
+	reflect.TypeOf(&corev1.Secret{}): func(om ObjectMatcher, old, new interface{}) (bool, error) {
+		return NewSecretMatcher(om).Match(old.(*corev1.Secret), new.(*corev1.Secret))
+	},
+	reflect.TypeOf(&appsv1.StatefulSet{}): func(om ObjectMatcher, old, new interface{}) (bool, error) {
+		return NewStatefulSetMatcher(om).Match(old.(*appsv1.StatefulSet), new.(*appsv1.StatefulSet))
+	},
+	reflect.TypeOf(&batchv1.Job{}): func(om ObjectMatcher, old, new interface{}) (bool, error) {
+		return NewJobMatcher(om).Match(old.(*batchv1.Job), new.(*batchv1.Job))
+	},
+	reflect.TypeOf(&batchv1beta1.CronJob{}): func(om ObjectMatcher, old, new interface{}) (bool, error) {
+		return NewCronJobMatcher(om).Match(old.(*batchv1beta1.CronJob), new.(*batchv1beta1.CronJob))
+	},
+	reflect.TypeOf(&extensionsv1beta1.Ingress{}): func(om ObjectMatcher, old, new interface{}) (bool, error) {
+		return NewIngressMatcher(om).Match(old.(*extensionsv1beta1.Ingress), new.(*extensionsv1beta1.Ingress))
+	},
+	reflect.TypeOf(&networkingv1.NetworkPolicy{}): func(om ObjectMatcher, old, new interface{}) (bool, error) {
+		return NewNetworkPolicyMatcher(om).Match(old.(*networkingv1.NetworkPolicy), new.(*networkingv1.NetworkPolicy))
+	},
+	reflect.TypeOf(&oappsv1.DeploymentConfig{}): func(om ObjectMatcher, old, new interface{}) (bool, error) {
+		return NewDeploymentConfigMatcher(om).Match(old.(*oappsv1.DeploymentConfig), new.(*oappsv1.DeploymentConfig))
+	},
+	reflect.TypeOf(&routev1.Route{}): func(om ObjectMatcher, old, new interface{}) (bool, error) {
+		return NewRouteMatcher(om).Match(old.(*routev1.Route), new.(*routev1.Route))
+	},
+	reflect.TypeOf(&imagev1.ImageStream{}): func(om ObjectMatcher, old, new interface{}) (bool, error) {
+		return NewImageStreamMatcher(om).Match(old.(*imagev1.ImageStream), new.(*imagev1.ImageStream))
+	},
+	reflect.TypeOf(&buildv1.BuildConfig{}): func(om ObjectMatcher, old, new interface{}) (bool, error) {
+		return NewBuildConfigMatcher(om).Match(old.(*buildv1.BuildConfig), new.(*buildv1.BuildConfig))
+	},
 }
 
 type ObjectMeta struct {
@@ -246,34 +276,20 @@ func (om *objectMatcher) GetObjectMeta(objectMeta metav1.ObjectMeta) ObjectMeta
 }
 
 func (om *objectMatcher) MatchJSON(old, new []byte, obj interface{}) (bool, error) {
-	var patch []byte
-	var err error
-
-	_, unstructed := obj.(*unstructured.Unstructured)
-	if unstructed {
-		patch, err = jsonpatch.CreateMergePatch(old, new)
-		if err != nil {
-			return false, emperror.Wrap(err, "could not create json merge patch")
-		}
-	} else {
-		patch, err = strategicpatch.CreateTwoWayMergePatch(old, new, obj)
-		if err != nil {
-			return false, emperror.Wrap(err, "could not create two way merge patch")
-		}
-	}
-
-	patch, _, err = om.deleteNullInJsonPatch(patch)
+	result, err := om.DiffJSON(old, new, obj)
 	if err != nil {
-		return false, emperror.Wrap(err, "could not remove nil values from json merge patch")
+		return false, err
 	}
-
-	if string(patch) == "{}" {
-		return true, nil
+	if om.record {
+		om.last = result
 	}
 
-	om.logger.V(1).Info("objects differs", "diff", string(patch), "old", string(old), "new", string(new))
+	if !result.Matched {
+		kind := redact.Kind(obj)
+		om.logger.V(1).Info("objects differs", "diff", string(redact.JSON(kind, result.Patch)), "old", string(redact.JSON(kind, old)), "new", string(redact.JSON(kind, new)))
+	}
 
-	return false, nil
+	return result.Matched, nil
 }
 
 func (om *objectMatcher) deleteNullInJsonPatch(patch []byte) ([]byte, map[string]interface{}, error) {
@@ -328,5 +344,14 @@ func (om *objectMatcher) deleteNullInObj(m map[string]interface{}) (map[string]i
 			}
 		}
 	}
+
+	// A $setElementOrder directive without its list only reorders a merge list, whose items are matched by key
+	for key := range filteredMap {
+		if strings.HasPrefix(key, setElementOrderDirectivePrefix) {
+			if _, ok := filteredMap[strings.TrimPrefix(key, setElementOrderDirectivePrefix)]; !ok {
+				delete(filteredMap, key)
+			}
+		}
+	}
 	return filteredMap, nil
 }
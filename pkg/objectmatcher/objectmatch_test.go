@@ -0,0 +1,322 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package objectmatch
+
+import (
+	"encoding/json"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	fuzz "github.com/google/gofuzz"
+	corev1 "k8s.io/api/core/v1"
+	extensionsobj "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+// Number of random objects generated per registered type
+const fuzzIterations = 50
+
+const fuzzSeed = 1
+
+// newFuzzer returns a fuzzer producing objects that marshal to JSON the way objects read from the API server do
+func newFuzzer(seed int64) *fuzz.Fuzzer {
+	return fuzz.New().
+		RandSource(rand.NewSource(seed)).
+		NilChance(0.5).
+		NumElements(0, 2).
+		MaxDepth(10).
+		Funcs(
+			// Merge keys, such as container and volume names, are never empty in objects read from the API server
+			func(s *string, c fuzz.Continue) {
+				*s = randName(c)
+			},
+			// Include the keys some matchers ignore, such as the annotations set by controllers
+			func(m *map[string]string, c fuzz.Continue) {
+				*m = map[string]string{}
+				for i := c.Intn(3); i > 0; i-- {
+					(*m)[randName(c)] = randName(c)
+				}
+				if c.RandBool() {
+					(*m)[ignoredKeys[c.Intn(len(ignoredKeys))]] = randName(c)
+				}
+			},
+			// Mount a service account token the way the service account admission plugin does
+			func(spec *corev1.PodSpec, c fuzz.Continue) {
+				c.FuzzNoCustom(spec)
+				if c.RandBool() {
+					token := spec.ServiceAccountName + "-token-" + randName(c)
+					spec.Volumes = append(spec.Volumes, corev1.Volume{Name: token})
+					spec.Containers = append(spec.Containers, corev1.Container{
+						Name:         randName(c),
+						VolumeMounts: []corev1.VolumeMount{{Name: token, MountPath: randName(c)}},
+					})
+				}
+			},
+			func(q *resource.Quantity, c fuzz.Continue) {
+				*q = *resource.NewQuantity(c.Int63n(1000), resource.DecimalSI)
+			},
+			func(v *intstr.IntOrString, c fuzz.Continue) {
+				if c.RandBool() {
+					*v = intstr.FromInt(c.Intn(1000))
+				} else {
+					*v = intstr.FromString(randName(c))
+				}
+			},
+			func(j *extensionsobj.JSON, c fuzz.Continue) {
+				j.Raw = []byte(strconv.Quote(randName(c)))
+			},
+			// Schemas nest without bound, a single level is enough to exercise the CRD matcher
+			func(v *extensionsobj.CustomResourceValidation, c fuzz.Continue) {
+				v.OpenAPIV3Schema = &extensionsobj.JSONSchemaProps{Type: randName(c), Description: randName(c)}
+			},
+			func(u *unstructured.Unstructured, c fuzz.Continue) {
+				u.Object = map[string]interface{}{
+					"apiVersion": "example.com/v1",
+					"kind":       "Example",
+					"metadata": map[string]interface{}{
+						"name":   randName(c),
+						"labels": map[string]interface{}{randName(c): randName(c)},
+					},
+					"spec": map[string]interface{}{
+						randName(c): randName(c),
+						randName(c): c.Int63n(1000),
+						randName(c): []interface{}{randName(c), c.RandBool()},
+					},
+				}
+			},
+		)
+}
+
+// ignoredKeys are annotations and labels that matchers leave out of the comparison
+var ignoredKeys = []string{
+	"deployment.kubernetes.io/revision",
+	"control-plane.alpha.kubernetes.io/leader",
+	"volume.beta.kubernetes.io/storage-provisioner",
+	"pv.kubernetes.io/bind-completed",
+	"pv.kubernetes.io/bound-by-controller",
+	"controller-uid",
+	"job-name",
+}
+
+const nameCharacters = "abcdefghijklmnopqrstuvwxyz0123456789-"
+
+// randName returns a random non empty name
+func randName(c fuzz.Continue) string {
+	b := make([]byte, 1+c.Intn(12))
+	for i := range b {
+		b[i] = nameCharacters[c.Intn(len(nameCharacters))]
+	}
+	return string(b)
+}
+
+// registeredTypes returns the types the object matcher has a built-in matcher for, in a stable order so that runs
+// with the same seed generate the same objects
+func registeredTypes() []reflect.Type {
+	types := []reflect.Type{}
+	for objType := range defaultMatchers {
+		types = append(types, objType)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		return types[i].String() < types[j].String()
+	})
+	return types
+}
+
+func newFuzzedObject(f *fuzz.Fuzzer, objType reflect.Type) runtime.Object {
+	obj := reflect.New(objType.Elem()).Interface().(runtime.Object)
+	f.Fuzz(obj)
+	return obj
+}
+
+// fuzzContent fuzzes every field of obj but its type and object metadata
+func fuzzContent(f *fuzz.Fuzzer, obj runtime.Object) {
+	if _, ok := obj.(*unstructured.Unstructured); ok {
+		f.Fuzz(obj)
+		return
+	}
+	v := reflect.ValueOf(obj).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if name := v.Type().Field(i).Name; name != "TypeMeta" && name != "ObjectMeta" {
+			f.Fuzz(v.Field(i).Addr().Interface())
+		}
+	}
+}
+
+func TestMatchDoesNotModifyInputs(t *testing.T) {
+	om := New(logf.Log).(*objectMatcher)
+	f := newFuzzer(fuzzSeed)
+
+	for _, objType := range registeredTypes() {
+		for i := 0; i < fuzzIterations; i++ {
+			old := newFuzzedObject(f, objType)
+			new := newFuzzedObject(f, objType)
+			if i%2 == 0 {
+				// Compare objects that only differ outside their metadata half of the time
+				new = old.DeepCopyObject()
+				fuzzContent(f, new)
+			}
+			oldCopy := old.DeepCopyObject()
+			newCopy := new.DeepCopyObject()
+
+			om.Match(old, new)
+			if !reflect.DeepEqual(old, oldCopy) {
+				t.Fatalf("%s: Match modified old", objType)
+			}
+			if !reflect.DeepEqual(new, newCopy) {
+				t.Fatalf("%s: Match modified new", objType)
+			}
+
+			om.Diff(old, new)
+			if !reflect.DeepEqual(old, oldCopy) {
+				t.Fatalf("%s: Diff modified old", objType)
+			}
+			if !reflect.DeepEqual(new, newCopy) {
+				t.Fatalf("%s: Diff modified new", objType)
+			}
+		}
+	}
+}
+
+func TestMatchIsReflexive(t *testing.T) {
+	om := New(logf.Log).(*objectMatcher)
+	f := newFuzzer(fuzzSeed)
+
+	for _, objType := range registeredTypes() {
+		for i := 0; i < fuzzIterations; i++ {
+			obj := newFuzzedObject(f, objType)
+
+			matched, err := om.Match(obj, obj)
+			if err != nil {
+				t.Fatalf("%s: Match(x, x) failed: %v", objType, err)
+			}
+			if !matched {
+				t.Fatalf("%s: Match(x, x) is false", objType)
+			}
+
+			matched, err = om.Match(obj, obj.DeepCopyObject())
+			if err != nil {
+				t.Fatalf("%s: Match(x, copy of x) failed: %v", objType, err)
+			}
+			if !matched {
+				t.Fatalf("%s: Match(x, copy of x) is false", objType)
+			}
+		}
+	}
+}
+
+func TestDiffRedactsSecrets(t *testing.T) {
+	om := New(logf.Log)
+	// A matcher that does not go through MatchJSON, Diff then describes the difference with the generic JSON matcher
+	om.RegisterMatcher(reflect.TypeOf(&corev1.Secret{}), MatcherFunc(func(old, new interface{}) (bool, error) {
+		return false, nil
+	}))
+
+	live := &corev1.Secret{Data: map[string][]byte{"password": []byte("hunter2")}}
+	desired := &corev1.Secret{Data: map[string][]byte{"password": []byte("correct horse")}}
+	result, err := om.Diff(live, desired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Matched || len(result.Diff) == 0 {
+		t.Fatalf("no difference found: %+v", result)
+	}
+	for _, value := range [][]byte{live.Data["password"], desired.Data["password"]} {
+		if encoded, _ := json.Marshal(value); strings.Contains(result.Diff, strings.Trim(string(encoded), `"`)) {
+			t.Errorf("Secret value not redacted:\n%s", result.Diff)
+		}
+	}
+}
+
+func TestRegisteredMatchers(t *testing.T) {
+	om := New(logf.Log)
+	calls := map[string]int{}
+	// differ reports objects as different, counting its calls under name
+	differ := func(name string) Matcher {
+		return MatcherFunc(func(old, new interface{}) (bool, error) {
+			calls[name]++
+			return false, nil
+		})
+	}
+	widget := schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}
+	om.RegisterMatcher(reflect.TypeOf(&corev1.ConfigMap{}), differ("ConfigMap"))
+	om.RegisterMatcher(reflect.TypeOf(&unstructured.Unstructured{}), differ("Unstructured"))
+	om.RegisterKindMatcher(widget, differ("Widget"))
+
+	configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "web"}, Data: map[string]string{"color": "blue"}}
+	newUnstructured := func(gvk schema.GroupVersionKind) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: map[string]interface{}{"spec": map[string]interface{}{"size": "large"}}}
+		u.SetGroupVersionKind(gvk)
+		u.SetName("web")
+		return u
+	}
+	endpoints := &corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "web"}, Subsets: []corev1.EndpointSubset{{Addresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}}}}}
+	changedEndpoints := endpoints.DeepCopy()
+	changedEndpoints.Subsets[0].Addresses[0].IP = "10.0.0.2"
+
+	tests := []struct {
+		name     string
+		old, new interface{}
+		matched  bool
+		// matcher is the registered matcher expected to compare the objects, none for the generic JSON matcher
+		matcher string
+	}{
+		{name: "type matcher overrides the built-in one", old: configMap, new: configMap.DeepCopy(), matcher: "ConfigMap"},
+		{name: "kind matcher", old: newUnstructured(widget), new: newUnstructured(widget), matcher: "Widget"},
+		{name: "type matcher for other kinds", old: newUnstructured(widget.GroupVersion().WithKind("Gadget")), new: newUnstructured(widget.GroupVersion().WithKind("Gadget")), matcher: "Unstructured"},
+		{name: "unregistered type", old: endpoints, new: endpoints.DeepCopy(), matched: true},
+		{name: "unregistered type changed", old: endpoints, new: changedEndpoints},
+	}
+
+	for _, test := range tests {
+		calls = map[string]int{}
+		matched, err := om.Match(test.old, test.new)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if matched != test.matched {
+			t.Errorf("%s: expected %v, got %v", test.name, test.matched, matched)
+		}
+		if len(test.matcher) > 0 && (len(calls) != 1 || calls[test.matcher] != 1) {
+			t.Errorf("%s: expected the %s matcher, got %v", test.name, test.matcher, calls)
+		}
+		if len(test.matcher) == 0 && len(calls) > 0 {
+			t.Errorf("%s: expected the generic JSON matcher, got %v", test.name, calls)
+		}
+
+		// Diff compares with the same matchers
+		calls = map[string]int{}
+		result, err := om.Diff(test.old, test.new)
+		if err != nil {
+			t.Errorf("%s: diff: %v", test.name, err)
+			continue
+		}
+		if result.Matched != test.matched || (len(test.matcher) > 0 && calls[test.matcher] == 0) {
+			t.Errorf("%s: diff: expected %v with the %q matcher, got %v with %v", test.name, test.matched, test.matcher, result.Matched, calls)
+		}
+	}
+}
@@ -41,18 +41,48 @@ func (m podMatcher) Match(old, new *corev1.Pod) (bool, error) {
 		Spec corev1.PodSpec
 	}
 
+	oldData, err := json.Marshal(Pod{
+		ObjectMeta: m.objectMatcher.GetObjectMeta(old.ObjectMeta),
+		Spec:       podSpec(old),
+	})
+
+	if err != nil {
+		return false, emperror.WrapWith(err, "could not marshal old object", "name", old.Name)
+	}
+	newObject := Pod{
+		ObjectMeta: m.objectMatcher.GetObjectMeta(new.ObjectMeta),
+		Spec:       podSpec(new),
+	}
+	newData, err := json.Marshal(newObject)
+	if err != nil {
+		return false, emperror.WrapWith(err, "could not marshal new object", "name", new.Name)
+	}
+
+	matched, err := m.objectMatcher.MatchJSON(oldData, newData, newObject)
+	if err != nil {
+		return false, emperror.WrapWith(err, "could not match objects", "name", new.Name)
+	}
+
+	return matched, nil
+}
+
+// podSpec returns a copy of the spec without the service account token volume and its mounts
+func podSpec(pod *corev1.Pod) corev1.PodSpec {
+	spec := *pod.Spec.DeepCopy()
+
 	generatedTokenName := ""
 	tmpVolume := []corev1.Volume{}
-	for _, volume := range old.Spec.Volumes {
-		if !strings.HasPrefix(volume.Name, old.Spec.ServiceAccountName + "-token-") {
+	for _, volume := range spec.Volumes {
+		if !strings.HasPrefix(volume.Name, spec.ServiceAccountName+"-token-") {
 			tmpVolume = append(tmpVolume, volume)
+		} else {
+			generatedTokenName = volume.Name
 		}
-		generatedTokenName = volume.Name
 	}
-	old.Spec.Volumes = tmpVolume
+	spec.Volumes = tmpVolume
 
 	tmpInitContainers := []corev1.Container{}
-	for _, initContainer := range old.Spec.InitContainers {
+	for _, initContainer := range spec.InitContainers {
 		tmpVolumeMounts := []corev1.VolumeMount{}
 		for _, volumeMount := range initContainer.VolumeMounts {
 			if volumeMount.Name != generatedTokenName {
@@ -62,10 +92,10 @@ func (m podMatcher) Match(old, new *corev1.Pod) (bool, error) {
 		initContainer.VolumeMounts = tmpVolumeMounts
 		tmpInitContainers = append(tmpInitContainers, initContainer)
 	}
-	old.Spec.InitContainers = tmpInitContainers
+	spec.InitContainers = tmpInitContainers
 
 	tmpContainers := []corev1.Container{}
-	for _, container := range old.Spec.Containers {
+	for _, container := range spec.Containers {
 		tmpVolumeMounts := []corev1.VolumeMount{}
 		for _, volumeMount := range container.VolumeMounts {
 			if volumeMount.Name != generatedTokenName {
@@ -75,29 +105,7 @@ func (m podMatcher) Match(old, new *corev1.Pod) (bool, error) {
 		container.VolumeMounts = tmpVolumeMounts
 		tmpContainers = append(tmpContainers, container)
 	}
-	old.Spec.Containers = tmpContainers
-
-	oldData, err := json.Marshal(Pod{
-		ObjectMeta: m.objectMatcher.GetObjectMeta(old.ObjectMeta),
-		Spec:       old.Spec,
-	})
-
-	if err != nil {
-		return false, emperror.WrapWith(err, "could not marshal old object", "name", old.Name)
-	}
-	newObject := Pod{
-		ObjectMeta: m.objectMatcher.GetObjectMeta(new.ObjectMeta),
-		Spec:       new.Spec,
-	}
-	newData, err := json.Marshal(newObject)
-	if err != nil {
-		return false, emperror.WrapWith(err, "could not marshal new object", "name", new.Name)
-	}
+	spec.Containers = tmpContainers
 
-	matched, err := m.objectMatcher.MatchJSON(oldData, newData, newObject)
-	if err != nil {
-		return false, emperror.WrapWith(err, "could not match objects", "name", new.Name)
-	}
-
-	return matched, nil
+	return spec
 }
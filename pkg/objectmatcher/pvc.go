@@ -17,10 +17,11 @@ limitations under the License.
 package objectmatch
 
 import (
-"encoding/json"
+	"encoding/json"
 
-"github.com/goph/emperror"
-corev1 "k8s.io/api/core/v1"
+	"github.com/goph/emperror"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type pvcMatcher struct {
@@ -40,22 +41,19 @@ func (m pvcMatcher) Match(old, new *corev1.PersistentVolumeClaim) (bool, error)
 		Spec corev1.PersistentVolumeClaimSpec
 	}
 
-	delete(new.ObjectMeta.Annotations, "volume.beta.kubernetes.io/storage-provisioner")
-	delete(new.ObjectMeta.Annotations, "pv.kubernetes.io/bind-completed")
-	delete(new.ObjectMeta.Annotations, "pv.kubernetes.io/bound-by-controller")
-
-	old.Spec.VolumeName = new.Spec.VolumeName
+	oldSpec := *old.Spec.DeepCopy()
+	oldSpec.VolumeName = new.Spec.VolumeName
 
 	oldData, err := json.Marshal(Pvc{
-		ObjectMeta: m.objectMatcher.GetObjectMeta(old.ObjectMeta),
-		Spec:       old.Spec,
+		ObjectMeta: m.objectMatcher.GetObjectMeta(pvcObjectMeta(old)),
+		Spec:       oldSpec,
 	})
 
 	if err != nil {
 		return false, emperror.WrapWith(err, "could not marshal old object", "name", old.Name)
 	}
 	newObject := Pvc{
-		ObjectMeta: m.objectMatcher.GetObjectMeta(new.ObjectMeta),
+		ObjectMeta: m.objectMatcher.GetObjectMeta(pvcObjectMeta(new)),
 		Spec:       new.Spec,
 	}
 	newData, err := json.Marshal(newObject)
@@ -71,3 +69,11 @@ func (m pvcMatcher) Match(old, new *corev1.PersistentVolumeClaim) (bool, error)
 	return matched, nil
 }
 
+// pvcObjectMeta returns a copy of the metadata without the annotations set when the claim is bound
+func pvcObjectMeta(pvc *corev1.PersistentVolumeClaim) metav1.ObjectMeta {
+	objectMeta := *pvc.ObjectMeta.DeepCopy()
+	delete(objectMeta.Annotations, "volume.beta.kubernetes.io/storage-provisioner")
+	delete(objectMeta.Annotations, "pv.kubernetes.io/bind-completed")
+	delete(objectMeta.Annotations, "pv.kubernetes.io/bound-by-controller")
+	return objectMeta
+}
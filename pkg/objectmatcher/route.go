@@ -0,0 +1,74 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package objectmatch
+
+import (
+	"encoding/json"
+
+	"github.com/goph/emperror"
+	routev1 "github.com/openshift/api/route/v1"
+)
+
+type routeMatcher struct {
+	objectMatcher ObjectMatcher
+}
+
+func NewRouteMatcher(objectMatcher ObjectMatcher) *routeMatcher {
+	return &routeMatcher{
+		objectMatcher: objectMatcher,
+	}
+}
+
+// Match compares two routev1.Route objects, ignoring the generated host when new does not set one
+func (m routeMatcher) Match(old, new *routev1.Route) (bool, error) {
+	type Route struct {
+		ObjectMeta
+		Spec routev1.RouteSpec
+	}
+
+	oldData, err := json.Marshal(Route{
+		ObjectMeta: m.objectMatcher.GetObjectMeta(old.ObjectMeta),
+		Spec:       routeSpec(old, new),
+	})
+	if err != nil {
+		return false, emperror.WrapWith(err, "could not marshal old object", "name", old.Name)
+	}
+	newObject := Route{
+		ObjectMeta: m.objectMatcher.GetObjectMeta(new.ObjectMeta),
+		Spec:       new.Spec,
+	}
+	newData, err := json.Marshal(newObject)
+	if err != nil {
+		return false, emperror.WrapWith(err, "could not marshal new object", "name", new.Name)
+	}
+
+	matched, err := m.objectMatcher.MatchJSON(oldData, newData, newObject)
+	if err != nil {
+		return false, emperror.WrapWith(err, "could not match objects", "name", new.Name)
+	}
+
+	return matched, nil
+}
+
+// routeSpec returns a copy of the spec of old without its host if the host of new is empty
+func routeSpec(old, new *routev1.Route) routev1.RouteSpec {
+	spec := *old.Spec.DeepCopy()
+	if new.Spec.Host == "" {
+		spec.Host = ""
+	}
+	return spec
+}
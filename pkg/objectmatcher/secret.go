@@ -0,0 +1,87 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package objectmatch
+
+import (
+	"encoding/json"
+
+	"github.com/goph/emperror"
+	corev1 "k8s.io/api/core/v1"
+)
+
+type secretMatcher struct {
+	objectMatcher ObjectMatcher
+}
+
+func NewSecretMatcher(objectMatcher ObjectMatcher) *secretMatcher {
+	return &secretMatcher{
+		objectMatcher: objectMatcher,
+	}
+}
+
+// Match compares two corev1.Secret objects, StringData is folded into Data the way the API server does it
+func (m secretMatcher) Match(old, new *corev1.Secret) (bool, error) {
+	type Secret struct {
+		ObjectMeta
+		Data map[string][]byte `json:"data"`
+		Type corev1.SecretType `json:"type"`
+	}
+
+	oldData, err := json.Marshal(Secret{
+		ObjectMeta: m.objectMatcher.GetObjectMeta(old.ObjectMeta),
+		Data:       secretData(old),
+		Type:       secretType(old),
+	})
+	if err != nil {
+		return false, emperror.WrapWith(err, "could not marshal old object", "name", old.Name)
+	}
+	newObject := Secret{
+		ObjectMeta: m.objectMatcher.GetObjectMeta(new.ObjectMeta),
+		Data:       secretData(new),
+		Type:       secretType(new),
+	}
+	newData, err := json.Marshal(newObject)
+	if err != nil {
+		return false, emperror.WrapWith(err, "could not marshal new object", "name", new.Name)
+	}
+
+	matched, err := m.objectMatcher.MatchJSON(oldData, newData, newObject)
+	if err != nil {
+		return false, emperror.WrapWith(err, "could not match objects", "name", new.Name)
+	}
+
+	return matched, nil
+}
+
+// secretData returns a copy of Data with StringData merged in, StringData wins on conflicts
+func secretData(secret *corev1.Secret) map[string][]byte {
+	data := map[string][]byte{}
+	for key, value := range secret.Data {
+		data[key] = value
+	}
+	for key, value := range secret.StringData {
+		data[key] = []byte(value)
+	}
+	return data
+}
+
+func secretType(secret *corev1.Secret) corev1.SecretType {
+	if secret.Type == "" {
+		return corev1.SecretTypeOpaque
+	}
+	return secret.Type
+}
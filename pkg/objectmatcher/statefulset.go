@@ -0,0 +1,77 @@
+/*
+Copyright 2019 Banzai Cloud.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package objectmatch
+
+import (
+	"encoding/json"
+
+	"github.com/goph/emperror"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type statefulSetMatcher struct {
+	objectMatcher ObjectMatcher
+}
+
+func NewStatefulSetMatcher(objectMatcher ObjectMatcher) *statefulSetMatcher {
+	return &statefulSetMatcher{
+		objectMatcher: objectMatcher,
+	}
+}
+
+// Match compares two appsv1.StatefulSet objects, ignoring the status of the volume claim templates
+func (m statefulSetMatcher) Match(old, new *appsv1.StatefulSet) (bool, error) {
+	type StatefulSet struct {
+		ObjectMeta
+		Spec appsv1.StatefulSetSpec
+	}
+
+	oldData, err := json.Marshal(StatefulSet{
+		ObjectMeta: m.objectMatcher.GetObjectMeta(old.ObjectMeta),
+		Spec:       statefulSetSpec(old),
+	})
+	if err != nil {
+		return false, emperror.WrapWith(err, "could not marshal old object", "name", old.Name)
+	}
+	newObject := StatefulSet{
+		ObjectMeta: m.objectMatcher.GetObjectMeta(new.ObjectMeta),
+		Spec:       statefulSetSpec(new),
+	}
+	newData, err := json.Marshal(newObject)
+	if err != nil {
+		return false, emperror.WrapWith(err, "could not marshal new object", "name", new.Name)
+	}
+
+	matched, err := m.objectMatcher.MatchJSON(oldData, newData, newObject)
+	if err != nil {
+		return false, emperror.WrapWith(err, "could not match objects", "name", new.Name)
+	}
+
+	return matched, nil
+}
+
+// statefulSetSpec returns a copy of the spec without the server populated fields of the volume claim templates
+func statefulSetSpec(statefulSet *appsv1.StatefulSet) appsv1.StatefulSetSpec {
+	spec := *statefulSet.Spec.DeepCopy()
+	for i := range spec.VolumeClaimTemplates {
+		spec.VolumeClaimTemplates[i].Status = corev1.PersistentVolumeClaimStatus{}
+		spec.VolumeClaimTemplates[i].CreationTimestamp = metav1.Time{}
+	}
+	return spec
+}
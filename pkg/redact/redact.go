@@ -0,0 +1,252 @@
+// Package redact masks sensitive values in objects and patches before they are logged or recorded: the data and
+// stringData of Secrets, the ConfigMap keys and the environment variables whose names match the patterns in
+// SENSITIVE_CONFIGMAP_KEYS and SENSITIVE_ENV_VARS. Masked values are replaced with a keyed hash, random per process,
+// so that diffs still show which values changed without disclosing them.
+package redact
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Comma separated glob patterns, matched case-insensitively, replacing DefaultSensitiveNames
+const SENSITIVE_CONFIGMAP_KEYS_ENV = "SENSITIVE_CONFIGMAP_KEYS"
+const SENSITIVE_ENV_VARS_ENV = "SENSITIVE_ENV_VARS"
+
+// Annotations holding a copy of the whole object
+var lastAppliedAnnotations = []string{"kubectl.kubernetes.io/last-applied-configuration"}
+
+// DefaultSensitiveNames are the names of ConfigMap keys and environment variables masked by default
+var DefaultSensitiveNames = []string{"*password*", "*passwd*", "*secret*", "*token*", "*credential*", "*private*key*", "*api*key*"}
+
+// Redactor masks sensitive values
+type Redactor struct {
+	configMapKeys []string
+	envVars       []string
+	key           []byte
+}
+
+// New returns a Redactor masking the ConfigMap keys and environment variables matching the given patterns
+func New(configMapKeys, envVars []string) *Redactor {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return &Redactor{configMapKeys: lower(configMapKeys), envVars: lower(envVars), key: key}
+}
+
+// FromEnv returns a Redactor configured by SENSITIVE_CONFIGMAP_KEYS and SENSITIVE_ENV_VARS
+func FromEnv() *Redactor {
+	return New(patternsFromEnv(SENSITIVE_CONFIGMAP_KEYS_ENV), patternsFromEnv(SENSITIVE_ENV_VARS_ENV))
+}
+
+var defaultRedactor = FromEnv()
+
+// Object returns the JSON of obj with sensitive values masked
+func Object(obj interface{}) string {
+	return defaultRedactor.Object(obj)
+}
+
+// JSON returns data, the JSON of an object or patch of the given kind, with sensitive values masked
+func JSON(kind string, data []byte) []byte {
+	return defaultRedactor.JSON(kind, data)
+}
+
+// Value returns obj as generic JSON values with the sensitive values of an object of the given kind masked
+func Value(kind string, obj interface{}) interface{} {
+	return defaultRedactor.Value(kind, obj)
+}
+
+// Kind returns the kind of obj, taken from its type for typed objects that lack their TypeMeta
+func Kind(obj interface{}) string {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		return u.GetKind()
+	}
+	t := reflect.TypeOf(obj)
+	if t == nil {
+		return ""
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
+}
+
+func (r *Redactor) Object(obj interface{}) string {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return "<not logged: " + err.Error() + ">"
+	}
+	return string(r.JSON(Kind(obj), data))
+}
+
+func (r *Redactor) JSON(kind string, data []byte) []byte {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return []byte(`"<not logged: invalid JSON>"`)
+	}
+	redacted, err := marshal(r.redact(kind, value))
+	if err != nil {
+		return []byte(`"<not logged: invalid JSON>"`)
+	}
+	return redacted
+}
+
+func (r *Redactor) Value(kind string, obj interface{}) interface{} {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return "<not logged: " + err.Error() + ">"
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return "<not logged: invalid JSON>"
+	}
+	return r.redact(kind, value)
+}
+
+// redact masks the sensitive values of value, the generic JSON of an object or patch, in place
+func (r *Redactor) redact(kind string, value interface{}) interface{} {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return value
+	}
+	if k, ok := object["kind"].(string); ok && k != "" {
+		kind = k
+	}
+
+	switch kind {
+	case "Secret":
+		r.maskValues(object["data"], matchAll)
+		r.maskValues(object["stringData"], matchAll)
+		r.maskLastApplied(object)
+	case "ConfigMap":
+		sensitive := func(key string) bool { return matches(r.configMapKeys, key) }
+		masked := r.maskValues(object["data"], sensitive)
+		masked = r.maskValues(object["binaryData"], sensitive) || masked
+		if masked {
+			r.maskLastApplied(object)
+		}
+	}
+	r.maskEnv(object)
+	return object
+}
+
+func matchAll(string) bool { return true }
+
+// maskValues masks the values of the keys of data for which sensitive is true and reports whether any was masked
+func (r *Redactor) maskValues(data interface{}, sensitive func(string) bool) bool {
+	values, ok := data.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	masked := false
+	for key, value := range values {
+		if s, ok := value.(string); ok && sensitive(key) {
+			values[key] = r.mask(s)
+			masked = true
+		}
+	}
+	return masked
+}
+
+func (r *Redactor) maskLastApplied(object map[string]interface{}) {
+	metadata, _ := object["metadata"].(map[string]interface{})
+	annotations, _ := metadata["annotations"].(map[string]interface{})
+	for _, annotation := range lastAppliedAnnotations {
+		if value, ok := annotations[annotation].(string); ok {
+			annotations[annotation] = r.mask(value)
+		}
+	}
+}
+
+// maskEnv masks the value of the sensitive environment variables found anywhere in value
+func (r *Redactor) maskEnv(value interface{}) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, child := range value {
+			if key == "env" {
+				r.maskEnvList(child)
+			}
+			r.maskEnv(child)
+		}
+	case []interface{}:
+		for _, child := range value {
+			r.maskEnv(child)
+		}
+	}
+}
+
+func (r *Redactor) maskEnvList(list interface{}) {
+	vars, ok := list.([]interface{})
+	if !ok {
+		return
+	}
+	for _, v := range vars {
+		envVar, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := envVar["name"].(string)
+		if value, ok := envVar["value"].(string); ok && matches(r.envVars, name) {
+			envVar["value"] = r.mask(value)
+		}
+	}
+}
+
+// mask returns the marker logged instead of value
+func (r *Redactor) mask(value string) string {
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(value))
+	return "<redacted " + hex.EncodeToString(mac.Sum(nil))[:8] + ">"
+}
+
+// marshal returns the JSON of value without escaping the brackets of the markers
+func marshal(value interface{}) ([]byte, error) {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buffer.Bytes(), []byte("\n")), nil
+}
+
+func matches(patterns []string, name string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func patternsFromEnv(env string) []string {
+	value, ok := os.LookupEnv(env)
+	if !ok {
+		return DefaultSensitiveNames
+	}
+	patterns := []string{}
+	for _, pattern := range strings.Split(value, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
+
+func lower(patterns []string) []string {
+	lowered := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		lowered[i] = strings.ToLower(pattern)
+	}
+	return lowered
+}
@@ -0,0 +1,81 @@
+package redact
+
+import (
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRedact(t *testing.T) {
+	r := New(DefaultSensitiveNames, DefaultSensitiveNames)
+
+	tests := []struct {
+		name   string
+		obj    interface{}
+		hidden []string
+		shown  []string
+	}{
+		{
+			name: "secret",
+			obj: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "db", Annotations: map[string]string{
+					"kubectl.kubernetes.io/last-applied-configuration": `{"stringData":{"user":"admin"}}`}},
+				Data:       map[string][]byte{"password": []byte("hunter2")},
+				StringData: map[string]string{"user": "admin"},
+			},
+			hidden: []string{"aHVudGVyMg==", "admin"},
+			shown:  []string{`"password":"<redacted `, `"name":"db"`},
+		},
+		{
+			name: "configmap",
+			obj: &corev1.ConfigMap{
+				Data: map[string]string{"DB_PASSWORD": "hunter2", "LOG_LEVEL": "debug"},
+			},
+			hidden: []string{"hunter2"},
+			shown:  []string{`"LOG_LEVEL":"debug"`},
+		},
+		{
+			name: "env",
+			obj: &appsv1.Deployment{Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "app", Env: []corev1.EnvVar{
+					{Name: "API_TOKEN", Value: "s3cr3t"},
+					{Name: "PORT", Value: "8080"},
+				}}},
+			}}}},
+			hidden: []string{"s3cr3t"},
+			shown:  []string{`"value":"8080"`},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			redacted := r.Object(test.obj)
+			for _, value := range test.hidden {
+				if strings.Contains(redacted, value) {
+					t.Errorf("%s not redacted: %s", value, redacted)
+				}
+			}
+			for _, value := range test.shown {
+				if !strings.Contains(redacted, value) {
+					t.Errorf("%s not found: %s", value, redacted)
+				}
+			}
+		})
+	}
+}
+
+func TestRedactPatchShowsChanges(t *testing.T) {
+	r := New(nil, nil)
+	old := string(r.JSON("Secret", []byte(`{"data":{"a":"b25l","b":"dHdv"}}`)))
+	new := string(r.JSON("Secret", []byte(`{"data":{"a":"b25l","b":"dGhyZWU="}}`)))
+
+	if old == new {
+		t.Errorf("changed value not visible: %s", new)
+	}
+	if strings.Split(old, ",")[0] != strings.Split(new, ",")[0] {
+		t.Errorf("unchanged value masked differently: %s, %s", old, new)
+	}
+}
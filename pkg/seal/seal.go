@@ -0,0 +1,159 @@
+// Package seal encrypts Secret values to a public key of the operator so that they can be committed to git. Each
+// value is encrypted with ChaCha20-Poly1305 under a key agreed with X25519 between a one-off key and the operator
+// key, and authenticated with the namespace and name of its Secret, so that it cannot be decrypted into any other
+// Secret. The operator keeps its old keys when it rotates them, sealed values name the key they were sealed to.
+package seal
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+const SEAL_LABEL = "rocketeer.com/seal/v1"
+
+// KEY_ID_SIZE is the size of the prefix of sealed values identifying the key they were sealed to
+const KEY_ID_SIZE = 8
+
+// sealedSize is the size of a sealed empty value: the key ID, the X25519 share and the Poly1305 tag
+const sealedSize = KEY_ID_SIZE + 32 + 16
+
+// PublicKey is the key values are sealed to
+type PublicKey [32]byte
+
+// ParsePublicKey reads a public key in the base64 form returned by String
+func ParsePublicKey(s string) (PublicKey, error) {
+	key := PublicKey{}
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(b) != len(key) {
+		return key, errors.New("invalid sealing public key")
+	}
+	copy(key[:], b)
+	return key, nil
+}
+
+func (k PublicKey) String() string {
+	return base64.StdEncoding.EncodeToString(k[:])
+}
+
+// ID identifies the key in the values sealed to it
+func (k PublicKey) ID() []byte {
+	sum := sha256.Sum256(k[:])
+	return sum[:KEY_ID_SIZE]
+}
+
+// PrivateKey is an operator key
+type PrivateKey struct {
+	secret  [32]byte
+	public  PublicKey
+	Created time.Time
+}
+
+// GenerateKey returns a new random key
+func GenerateKey(created time.Time) (*PrivateKey, error) {
+	secret := [32]byte{}
+	if _, err := io.ReadFull(rand.Reader, secret[:]); err != nil {
+		return nil, err
+	}
+	return newPrivateKey(secret, created), nil
+}
+
+func newPrivateKey(secret [32]byte, created time.Time) *PrivateKey {
+	key := &PrivateKey{secret: secret, Created: created}
+	curve25519.ScalarBaseMult((*[32]byte)(&key.public), &key.secret)
+	return key
+}
+
+// Public returns the public key values are sealed to
+func (k *PrivateKey) Public() PublicKey {
+	return k.public
+}
+
+// Seal encrypts value for the Secret name in namespace
+func Seal(public PublicKey, namespace, name string, value []byte) (string, error) {
+	ephemeral := [32]byte{}
+	if _, err := io.ReadFull(rand.Reader, ephemeral[:]); err != nil {
+		return "", err
+	}
+	share := [32]byte{}
+	curve25519.ScalarBaseMult(&share, &ephemeral)
+	shared := [32]byte{}
+	curve25519.ScalarMult(&shared, &ephemeral, (*[32]byte)(&public))
+
+	aead, err := chacha20poly1305.New(sealingKey(shared, share, public))
+	if err != nil {
+		return "", err
+	}
+	sealed := append(append(public.ID(), share[:]...), aead.Seal(nil, make([]byte, aead.NonceSize()), value, scope(namespace, name))...)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Keys are the operator keys, the newest first
+type Keys []*PrivateKey
+
+// Newest returns the key new values are sealed to, nil if there is none
+func (keys Keys) Newest() *PrivateKey {
+	if len(keys) == 0 {
+		return nil
+	}
+	return keys[0]
+}
+
+func (keys Keys) sort() {
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i].Created.After(keys[j].Created) })
+}
+
+// Unseal decrypts a value sealed for the Secret name in namespace
+func (keys Keys) Unseal(namespace, name, sealed string) ([]byte, error) {
+	b, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil || len(b) < sealedSize {
+		return nil, errors.New("not a sealed value")
+	}
+	id, share, ciphertext := b[:KEY_ID_SIZE], b[KEY_ID_SIZE:KEY_ID_SIZE+32], b[KEY_ID_SIZE+32:]
+
+	for _, key := range keys {
+		if !bytes.Equal(key.public.ID(), id) {
+			continue
+		}
+		ephemeral := [32]byte{}
+		copy(ephemeral[:], share)
+		shared := [32]byte{}
+		curve25519.ScalarMult(&shared, &key.secret, &ephemeral)
+
+		aead, err := chacha20poly1305.New(sealingKey(shared, ephemeral, key.public))
+		if err != nil {
+			return nil, err
+		}
+		value, err := aead.Open(nil, make([]byte, aead.NonceSize()), ciphertext, scope(namespace, name))
+		if err != nil {
+			return nil, fmt.Errorf("not sealed for %s/%s", namespace, name)
+		}
+		return value, nil
+	}
+	return nil, fmt.Errorf("sealed to unknown key %x", id)
+}
+
+// sealingKey derives the ChaCha20-Poly1305 key from the X25519 shared secret
+func sealingKey(shared, share [32]byte, public PublicKey) []byte {
+	key := make([]byte, chacha20poly1305.KeySize)
+	salt := append(append([]byte{}, share[:]...), public[:]...)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared[:], salt, []byte(SEAL_LABEL)), key); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func scope(namespace, name string) []byte {
+	return []byte(namespace + "/" + name)
+}
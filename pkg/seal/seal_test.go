@@ -0,0 +1,93 @@
+package seal
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSeal(t *testing.T) {
+	key, err := GenerateKey(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := ParsePublicKey(key.Public().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := Seal(public, "shop", "db", []byte("hunter2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := Keys{key}
+	if value, err := keys.Unseal("shop", "db", sealed); err != nil || string(value) != "hunter2" {
+		t.Errorf("unsealed %q, %v", value, err)
+	}
+	if _, err := keys.Unseal("shop-dev", "db", sealed); err == nil {
+		t.Error("unsealed in another namespace")
+	}
+	if _, err := keys.Unseal("shop", "cache", sealed); err == nil {
+		t.Error("unsealed into another Secret")
+	}
+
+	other, _ := GenerateKey(time.Now())
+	if _, err := (Keys{other}).Unseal("shop", "db", sealed); err == nil {
+		t.Error("unsealed with another key")
+	}
+}
+
+func TestRotatedKeysUnseal(t *testing.T) {
+	old, _ := GenerateKey(time.Now().Add(-48 * time.Hour))
+	sealed, _ := Seal(old.Public(), "shop", "db", []byte("hunter2"))
+	newest, _ := GenerateKey(time.Now())
+
+	secret := &corev1.Secret{Data: map[string][]byte{
+		strconv.FormatInt(old.Created.Unix(), 10) + PRIVATE_KEY_SUFFIX:    old.secret[:],
+		strconv.FormatInt(newest.Created.Unix(), 10) + PRIVATE_KEY_SUFFIX: newest.secret[:],
+	}}
+	keys, err := keysFromSecret(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys.Newest().Public() != newest.Public() {
+		t.Error("newest key not first")
+	}
+	if value, err := keys.Unseal("shop", "db", sealed); err != nil || string(value) != "hunter2" {
+		t.Errorf("value sealed to the old key unsealed %q, %v", value, err)
+	}
+}
+
+func TestSealSecret(t *testing.T) {
+	key, _ := GenerateKey(time.Now())
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Labels: map[string]string{"app": "shop"}},
+		Type:       corev1.SecretTypeOpaque,
+		Data:       map[string][]byte{"user": []byte("admin")},
+		StringData: map[string]string{"password": "hunter2"},
+	}
+
+	sealed, err := SealSecret(key.Public(), "shop", secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsSealedSecret(sealed.APIVersion, sealed.Kind) || len(sealed.Spec.EncryptedData) != 2 {
+		t.Fatalf("unexpected SealedSecret: %+v", sealed)
+	}
+
+	unsealed, err := Keys{key}.UnsealSecret("shop", sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unsealed.Kind != "Secret" || unsealed.Name != "db" || unsealed.Labels["app"] != "shop" || unsealed.Type != corev1.SecretTypeOpaque ||
+		string(unsealed.Data["user"]) != "admin" || string(unsealed.Data["password"]) != "hunter2" {
+		t.Errorf("unexpected Secret: %+v", unsealed)
+	}
+
+	if _, err := (Keys{key}).UnsealSecret("other", sealed); err == nil {
+		t.Error("unsealed in another namespace")
+	}
+}
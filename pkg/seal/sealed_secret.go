@@ -0,0 +1,91 @@
+package seal
+
+import (
+	"fmt"
+	"sort"
+
+	appv1alpha1 "github.com/cvicens/rocketeer-operator/pkg/apis/app/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const SEALED_SECRET_KIND = "SealedSecret"
+
+// SealedSecret is a descriptor holding the values of a Secret sealed to the operator key. It only exists in the
+// repository, the operator applies the Secret it unseals to.
+type SealedSecret struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec SealedSecretSpec `json:"spec"`
+}
+
+// SealedSecretSpec holds the type and the sealed values of the Secret
+type SealedSecretSpec struct {
+	Type          corev1.SecretType `json:"type,omitempty"`
+	EncryptedData map[string]string `json:"encryptedData"`
+}
+
+// IsSealedSecret reports whether the apiVersion and kind of a descriptor are those of a SealedSecret
+func IsSealedSecret(apiVersion, kind string) bool {
+	return apiVersion == appv1alpha1.SchemeGroupVersion.String() && kind == SEALED_SECRET_KIND
+}
+
+// SealSecret returns secret, with its stringData merged into its data, as a SealedSecret for namespace
+func SealSecret(public PublicKey, namespace string, secret *corev1.Secret) (*SealedSecret, error) {
+	sealed := &SealedSecret{
+		TypeMeta: metav1.TypeMeta{APIVersion: appv1alpha1.SchemeGroupVersion.String(), Kind: SEALED_SECRET_KIND},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        secret.Name,
+			Namespace:   secret.Namespace,
+			Labels:      secret.Labels,
+			Annotations: secret.Annotations,
+		},
+		Spec: SealedSecretSpec{Type: secret.Type, EncryptedData: map[string]string{}},
+	}
+
+	data := map[string][]byte{}
+	for key, value := range secret.Data {
+		data[key] = value
+	}
+	for key, value := range secret.StringData {
+		data[key] = []byte(value)
+	}
+	for key, value := range data {
+		s, err := Seal(public, namespace, secret.Name, value)
+		if err != nil {
+			return nil, err
+		}
+		sealed.Spec.EncryptedData[key] = s
+	}
+	return sealed, nil
+}
+
+// UnsealSecret returns the Secret sealed for namespace
+func (keys Keys) UnsealSecret(namespace string, sealed *SealedSecret) (*corev1.Secret, error) {
+	secret := &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        sealed.Name,
+			Namespace:   sealed.Namespace,
+			Labels:      sealed.Labels,
+			Annotations: sealed.Annotations,
+		},
+		Type: sealed.Spec.Type,
+		Data: map[string][]byte{},
+	}
+
+	names := []string{}
+	for key := range sealed.Spec.EncryptedData {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	for _, key := range names {
+		value, err := keys.Unseal(namespace, sealed.Name, sealed.Spec.EncryptedData[key])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", key, err)
+		}
+		secret.Data[key] = value
+	}
+	return secret, nil
+}
@@ -0,0 +1,143 @@
+package seal
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// The operator keys are kept in a Secret of the operator namespace, one entry <creation unix time>.key each, and
+// the newest public key is published in a ConfigMap next to it
+const SEALING_KEYS_SECRET = "rocketeer-sealing-keys"
+const SEALING_PUBLIC_KEY_CONFIGMAP = "rocketeer-sealing-public-key"
+const PUBLIC_KEY_KEY = "public-key"
+const PRIVATE_KEY_SUFFIX = ".key"
+
+// KEY_CHECK_INTERVAL is how often the KeyRotator checks the age of the newest key
+const KEY_CHECK_INTERVAL = time.Hour
+
+// ReadKeys returns the operator keys stored in namespace, the newest first
+func ReadKeys(c client.Reader, namespace string) (Keys, error) {
+	secret := &corev1.Secret{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: SEALING_KEYS_SECRET, Namespace: namespace}, secret); err != nil {
+		return nil, err
+	}
+	return keysFromSecret(secret)
+}
+
+func keysFromSecret(secret *corev1.Secret) (Keys, error) {
+	keys := Keys{}
+	for name, value := range secret.Data {
+		if !strings.HasSuffix(name, PRIVATE_KEY_SUFFIX) {
+			continue
+		}
+		created, err := strconv.ParseInt(strings.TrimSuffix(name, PRIVATE_KEY_SUFFIX), 10, 64)
+		if err != nil || len(value) != 32 {
+			return nil, fmt.Errorf("invalid sealing key %s", name)
+		}
+		secretKey := [32]byte{}
+		copy(secretKey[:], value)
+		keys = append(keys, newPrivateKey(secretKey, time.Unix(created, 0)))
+	}
+	keys.sort()
+	return keys, nil
+}
+
+// ReadPublicKey returns the public key published in namespace, the one to seal new values to
+func ReadPublicKey(c client.Client, namespace string) (PublicKey, error) {
+	configMap := &corev1.ConfigMap{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: SEALING_PUBLIC_KEY_CONFIGMAP, Namespace: namespace}, configMap); err != nil {
+		return PublicKey{}, err
+	}
+	return ParsePublicKey(configMap.Data[PUBLIC_KEY_KEY])
+}
+
+// RotateKeys generates a key if there is none or if the newest one is older than maxAge, 0 never rotates. The old
+// keys are kept to unseal the values sealed to them. The newest public key is published in any case.
+func RotateKeys(c client.Client, logger logr.Logger, namespace string, maxAge time.Duration) error {
+	secret := &corev1.Secret{}
+	err := c.Get(context.TODO(), types.NamespacedName{Name: SEALING_KEYS_SECRET, Namespace: namespace}, secret)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	exists := err == nil
+
+	keys, err := keysFromSecret(secret)
+	if err != nil {
+		return err
+	}
+	newest := keys.Newest()
+	if newest == nil || (maxAge > 0 && time.Since(newest.Created) > maxAge) {
+		if newest, err = GenerateKey(time.Now()); err != nil {
+			return err
+		}
+		if secret.Data == nil {
+			secret.Data = map[string][]byte{}
+		}
+		secret.Data[strconv.FormatInt(newest.Created.Unix(), 10)+PRIVATE_KEY_SUFFIX] = newest.secret[:]
+		if exists {
+			err = c.Update(context.TODO(), secret)
+		} else {
+			secret.ObjectMeta = metav1.ObjectMeta{Name: SEALING_KEYS_SECRET, Namespace: namespace}
+			err = c.Create(context.TODO(), secret)
+		}
+		if err != nil {
+			return err
+		}
+		logger.Info(fmt.Sprintf("Generated sealing key %x, %d kept", newest.Public().ID(), len(keys)+1))
+	}
+
+	return publishPublicKey(c, namespace, newest.Public())
+}
+
+func publishPublicKey(c client.Client, namespace string, public PublicKey) error {
+	configMap := &corev1.ConfigMap{}
+	err := c.Get(context.TODO(), types.NamespacedName{Name: SEALING_PUBLIC_KEY_CONFIGMAP, Namespace: namespace}, configMap)
+	if errors.IsNotFound(err) {
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: SEALING_PUBLIC_KEY_CONFIGMAP, Namespace: namespace},
+			Data:       map[string]string{PUBLIC_KEY_KEY: public.String()},
+		}
+		return c.Create(context.TODO(), configMap)
+	}
+	if err != nil || configMap.Data[PUBLIC_KEY_KEY] == public.String() {
+		return err
+	}
+	if configMap.Data == nil {
+		configMap.Data = map[string]string{}
+	}
+	configMap.Data[PUBLIC_KEY_KEY] = public.String()
+	return c.Update(context.TODO(), configMap)
+}
+
+// KeyRotator is a manager Runnable that rotates the keys in Namespace when the newest one is older than MaxAge
+type KeyRotator struct {
+	Client    client.Client
+	Logger    logr.Logger
+	Namespace string
+	MaxAge    time.Duration
+}
+
+func (k *KeyRotator) Start(stop <-chan struct{}) error {
+	ticker := time.NewTicker(KEY_CHECK_INTERVAL)
+	defer ticker.Stop()
+	for {
+		if err := RotateKeys(k.Client, k.Logger, k.Namespace, k.MaxAge); err != nil {
+			k.Logger.Info("Rotate sealing keys err: " + err.Error())
+		}
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
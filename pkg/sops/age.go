@@ -0,0 +1,308 @@
+package sops
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// The age v1 format, https://age-encryption.org/v1, restricted to X25519 recipients
+
+const AGE_INTRO = "age-encryption.org/v1"
+const AGE_X25519_LABEL = "age-encryption.org/v1/X25519"
+const AGE_SECRET_KEY_HRP = "age-secret-key-"
+const AGE_ARMOR_BEGIN = "-----BEGIN AGE ENCRYPTED FILE-----"
+const AGE_ARMOR_END = "-----END AGE ENCRYPTED FILE-----"
+
+const ageChunkSize = 64 * 1024
+const ageStanzaColumns = 64
+
+// ageIdentity is an X25519 private key
+type ageIdentity struct {
+	secret    [32]byte
+	recipient [32]byte
+}
+
+// parseAgeIdentities reads the AGE-SECRET-KEY-1... lines of an age key file, blank lines and # comments are skipped
+func parseAgeIdentities(data []byte) ([]ageIdentity, error) {
+	identities := []ageIdentity{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hrp, key, err := bech32Decode(line)
+		if err != nil || hrp != AGE_SECRET_KEY_HRP || len(key) != 32 {
+			return nil, fmt.Errorf("line %d is not an age X25519 identity", n)
+		}
+		identity := ageIdentity{}
+		copy(identity.secret[:], key)
+		curve25519.ScalarBaseMult(&identity.recipient, &identity.secret)
+		identities = append(identities, identity)
+	}
+	return identities, scanner.Err()
+}
+
+// ageStanza is a recipient stanza of an age header
+type ageStanza struct {
+	kind string
+	args []string
+	body []byte
+}
+
+// ageDecrypt decrypts an armored age file with the first identity it was encrypted to
+func ageDecrypt(armored string, identities []ageIdentity) ([]byte, error) {
+	data, err := ageDearmor(armored)
+	if err != nil {
+		return nil, err
+	}
+	reader := bufio.NewReader(bytes.NewReader(data))
+	stanzas, header, mac, err := ageReadHeader(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	fileKey, err := ageUnwrap(stanzas, identities)
+	if err != nil {
+		return nil, err
+	}
+
+	h := hmac.New(sha256.New, ageKey(fileKey, nil, "header"))
+	h.Write(header)
+	if !hmac.Equal(h.Sum(nil), mac) {
+		return nil, errors.New("age header MAC mismatch")
+	}
+
+	payload, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	return ageOpenPayload(fileKey, payload)
+}
+
+// ageDearmor decodes the PEM-like armor sops wraps age files in
+func ageDearmor(armored string) ([]byte, error) {
+	armored = strings.TrimSpace(armored)
+	if !strings.HasPrefix(armored, AGE_ARMOR_BEGIN) || !strings.HasSuffix(armored, AGE_ARMOR_END) {
+		return nil, errors.New("not an armored age file")
+	}
+	encoded := strings.Join(strings.Fields(armored[len(AGE_ARMOR_BEGIN):len(armored)-len(AGE_ARMOR_END)]), "")
+	return base64.StdEncoding.DecodeString(encoded)
+}
+
+// ageReadHeader returns the stanzas of the header, the header bytes covered by its MAC and the MAC
+func ageReadHeader(reader *bufio.Reader) ([]ageStanza, []byte, []byte, error) {
+	header := &bytes.Buffer{}
+	readLine := func() (string, error) {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return "", errors.New("truncated age header")
+		}
+		header.WriteString(line)
+		return strings.TrimSuffix(line, "\n"), nil
+	}
+
+	intro, err := readLine()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if intro != AGE_INTRO {
+		return nil, nil, nil, fmt.Errorf("unsupported age version %q", intro)
+	}
+
+	stanzas := []ageStanza{}
+	for {
+		line, err := readLine()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if strings.HasPrefix(line, "--- ") {
+			// The MAC covers the header up to and including the dashes
+			covered := header.Bytes()[:header.Len()-len(line)-1+len("---")]
+			mac, err := base64.RawStdEncoding.DecodeString(line[len("--- "):])
+			if err != nil {
+				return nil, nil, nil, errors.New("invalid age header MAC")
+			}
+			return stanzas, covered, mac, nil
+		}
+		if !strings.HasPrefix(line, "-> ") {
+			return nil, nil, nil, errors.New("invalid age header")
+		}
+		fields := strings.Fields(line[len("-> "):])
+		if len(fields) == 0 {
+			return nil, nil, nil, errors.New("invalid age stanza")
+		}
+		stanza := ageStanza{kind: fields[0], args: fields[1:]}
+		for {
+			line, err := readLine()
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			chunk, err := base64.RawStdEncoding.DecodeString(line)
+			if err != nil || len(line) > ageStanzaColumns {
+				return nil, nil, nil, errors.New("invalid age stanza body")
+			}
+			stanza.body = append(stanza.body, chunk...)
+			if len(line) < ageStanzaColumns {
+				break
+			}
+		}
+		stanzas = append(stanzas, stanza)
+	}
+}
+
+// ageUnwrap returns the file key of the first X25519 stanza one of identities can open
+func ageUnwrap(stanzas []ageStanza, identities []ageIdentity) ([]byte, error) {
+	for _, stanza := range stanzas {
+		if stanza.kind != "X25519" || len(stanza.args) != 1 {
+			continue
+		}
+		share, err := base64.RawStdEncoding.DecodeString(stanza.args[0])
+		if err != nil || len(share) != 32 {
+			return nil, errors.New("invalid age X25519 stanza")
+		}
+		var ephemeral [32]byte
+		copy(ephemeral[:], share)
+
+		for _, identity := range identities {
+			var shared [32]byte
+			curve25519.ScalarMult(&shared, &identity.secret, &ephemeral)
+			if shared == [32]byte{} {
+				continue
+			}
+			salt := append(append([]byte{}, ephemeral[:]...), identity.recipient[:]...)
+			aead, err := chacha20poly1305.New(ageKey(shared[:], salt, AGE_X25519_LABEL))
+			if err != nil {
+				return nil, err
+			}
+			if fileKey, err := aead.Open(nil, make([]byte, chacha20poly1305.NonceSize), stanza.body, nil); err == nil {
+				return fileKey, nil
+			}
+		}
+	}
+	return nil, errNoKey
+}
+
+// ageOpenPayload decrypts the STREAM of 64KiB chunks that follows the nonce of the payload
+func ageOpenPayload(fileKey, payload []byte) ([]byte, error) {
+	if len(payload) < 16 {
+		return nil, errors.New("truncated age payload")
+	}
+	aead, err := chacha20poly1305.New(ageKey(fileKey, payload[:16], "payload"))
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext := []byte{}
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	ciphertext := payload[16:]
+	for counter := uint64(0); ; counter++ {
+		size := ageChunkSize + aead.Overhead()
+		last := len(ciphertext) <= size
+		if last {
+			size = len(ciphertext)
+			nonce[len(nonce)-1] = 1
+		}
+		binary.BigEndian.PutUint64(nonce[3:11], counter)
+		chunk, err := aead.Open(nil, nonce, ciphertext[:size], nil)
+		if err != nil {
+			return nil, errors.New("age payload authentication failed")
+		}
+		plaintext = append(plaintext, chunk...)
+		ciphertext = ciphertext[size:]
+		if last {
+			return plaintext, nil
+		}
+	}
+}
+
+// ageKey derives a 32 bytes key with HKDF-SHA256
+func ageKey(secret, salt []byte, info string) []byte {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, []byte(info)), key); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// bech32Decode returns the lowercase human-readable part and the data of a Bech32 string
+func bech32Decode(s string) (string, []byte, error) {
+	if strings.ToLower(s) != s && strings.ToUpper(s) != s {
+		return "", nil, errors.New("mixed case")
+	}
+	s = strings.ToLower(s)
+	separator := strings.LastIndex(s, "1")
+	if separator < 1 || separator+7 > len(s) {
+		return "", nil, errors.New("invalid separator")
+	}
+	hrp := s[:separator]
+	values := []byte{}
+	for _, c := range s[separator+1:] {
+		v := strings.IndexRune(bech32Charset, c)
+		if v < 0 {
+			return "", nil, errors.New("invalid character")
+		}
+		values = append(values, byte(v))
+	}
+	if bech32Polymod(append(bech32ExpandHRP(hrp), values...)) != 1 {
+		return "", nil, errors.New("invalid checksum")
+	}
+
+	// Regroup the 5 bits values, without the checksum, into bytes
+	data := []byte{}
+	acc, bits := 0, uint(0)
+	for _, v := range values[:len(values)-6] {
+		acc = acc<<5 | int(v)
+		bits += 5
+		for bits >= 8 {
+			bits -= 8
+			data = append(data, byte(acc>>bits))
+		}
+	}
+	if bits >= 5 || acc&(1<<bits-1) != 0 {
+		return "", nil, errors.New("invalid padding")
+	}
+	return hrp, data, nil
+}
+
+func bech32ExpandHRP(hrp string) []byte {
+	expanded := []byte{}
+	for _, c := range hrp {
+		expanded = append(expanded, byte(c>>5))
+	}
+	expanded = append(expanded, 0)
+	for _, c := range hrp {
+		expanded = append(expanded, byte(c&31))
+	}
+	return expanded
+}
+
+func bech32Polymod(values []byte) uint32 {
+	generator := []uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	checksum := uint32(1)
+	for _, v := range values {
+		top := checksum >> 25
+		checksum = (checksum&0x1ffffff)<<5 ^ uint32(v)
+		for i := uint(0); i < 5; i++ {
+			if (top>>i)&1 == 1 {
+				checksum ^= generator[i]
+			}
+		}
+	}
+	return checksum
+}
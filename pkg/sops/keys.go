@@ -0,0 +1,83 @@
+package sops
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	pgperrors "golang.org/x/crypto/openpgp/errors"
+)
+
+// Suffixes of the keys of a decryption Secret holding age identities and armored PGP private keys
+const AGE_KEY_SUFFIX = ".agekey"
+const PGP_KEY_SUFFIX = ".asc"
+
+// Keys are the private keys a document may be decrypted with
+type Keys struct {
+	age []ageIdentity
+	pgp openpgp.EntityList
+}
+
+// ParseKeys reads the age identities in the entries of data ending in .agekey and the PGP private keys in the
+// entries ending in .asc, other entries are ignored. PGP keys protected by a passphrase are not supported.
+func ParseKeys(data map[string][]byte) (*Keys, error) {
+	names := []string{}
+	for name := range data {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	keys := &Keys{}
+	for _, name := range names {
+		switch {
+		case strings.HasSuffix(name, AGE_KEY_SUFFIX):
+			identities, err := parseAgeIdentities(data[name])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", name, err)
+			}
+			keys.age = append(keys.age, identities...)
+		case strings.HasSuffix(name, PGP_KEY_SUFFIX):
+			entities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(data[name]))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", name, err)
+			}
+			for _, entity := range entities {
+				if entity.PrivateKey == nil {
+					return nil, fmt.Errorf("%s: %X is not a private key", name, entity.PrimaryKey.Fingerprint)
+				}
+			}
+			keys.pgp = append(keys.pgp, entities...)
+		}
+	}
+	if len(keys.age) == 0 && len(keys.pgp) == 0 {
+		return nil, errors.New("no " + AGE_KEY_SUFFIX + " or " + PGP_KEY_SUFFIX + " keys found")
+	}
+	return keys, nil
+}
+
+// pgpDecrypt decrypts an armored PGP message with the first key of keyring it was encrypted to
+func pgpDecrypt(armored string, keyring openpgp.EntityList) ([]byte, error) {
+	if len(keyring) == 0 {
+		return nil, errNoKey
+	}
+	block, err := armor.Decode(strings.NewReader(armored))
+	if err != nil {
+		return nil, err
+	}
+	prompt := func([]openpgp.Key, bool) ([]byte, error) {
+		return nil, errors.New("PGP keys protected by a passphrase are not supported")
+	}
+	message, err := openpgp.ReadMessage(block.Body, keyring, prompt, nil)
+	if err == pgperrors.ErrKeyIncorrect {
+		return nil, errNoKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(message.UnverifiedBody)
+}
@@ -0,0 +1,290 @@
+// Package sops decrypts documents encrypted with SOPS, https://github.com/mozilla/sops, using age or PGP private
+// keys. Only the values are encrypted, each with AES256-GCM under a data key that is itself encrypted to every
+// recipient in the `sops` metadata block of the document. Decryption happens in memory, the plaintext is only
+// returned to the caller.
+package sops
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// METADATA_KEY is the top-level key of the sops metadata block
+const METADATA_KEY = "sops"
+
+var encryptedValue = regexp.MustCompile(`^ENC\[AES256_GCM,data:(.+),iv:(.+),tag:(.+),type:(.+)\]$`)
+
+var errNoKey = errors.New("no matching key")
+
+// macOnlyEncryptedInitialization starts the hash of the MAC of documents with mac_only_encrypted, the SHA256 of
+// "sops" as in sops
+var macOnlyEncryptedInitialization = []byte{0x8a, 0x3f, 0xd2, 0xad, 0x54, 0xce, 0x66, 0x52, 0x7b, 0x10, 0x34, 0xf3,
+	0xd1, 0x47, 0xbe, 0x0b, 0x0b, 0x97, 0x5b, 0x3b, 0xf4, 0x4f, 0x72, 0xc6, 0xfd, 0xad, 0xec, 0x81, 0x76, 0xf2, 0x7d, 0x69}
+
+// metadata is the part of the sops metadata block needed to decrypt
+type metadata struct {
+	Age []struct {
+		Recipient string `yaml:"recipient"`
+		Enc       string `yaml:"enc"`
+	} `yaml:"age"`
+	PGP []struct {
+		Fingerprint string `yaml:"fp"`
+		Enc         string `yaml:"enc"`
+	} `yaml:"pgp"`
+	KeyGroups        []interface{} `yaml:"key_groups"`
+	LastModified     string        `yaml:"lastmodified"`
+	MAC              string        `yaml:"mac"`
+	MACOnlyEncrypted bool          `yaml:"mac_only_encrypted"`
+}
+
+// IsEncrypted reports whether data is a YAML or JSON document with a sops metadata block
+func IsEncrypted(data []byte) bool {
+	_, meta, err := parse(data)
+	return err == nil && meta != nil
+}
+
+// Decrypt returns the JSON of the document in data, YAML or JSON encrypted with sops, with its values decrypted
+// and its metadata block removed. The integrity of the whole document is checked against the MAC in the metadata.
+func Decrypt(data []byte, keys *Keys) ([]byte, error) {
+	document, meta, err := parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if meta == nil {
+		return nil, errors.New("no sops metadata found")
+	}
+	if len(meta.KeyGroups) > 0 {
+		return nil, errors.New("sops key groups are not supported")
+	}
+	if strings.Contains(string(data), "#ENC[") {
+		return nil, errors.New("encrypted comments are not supported, remove the comments before encrypting")
+	}
+
+	dataKey, err := meta.dataKey(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &decryptor{key: dataKey, hash: sha512.New(), macOnlyEncrypted: meta.MACOnlyEncrypted}
+	if meta.MACOnlyEncrypted {
+		d.hash.Write(macOnlyEncryptedInitialization)
+	}
+	plaintext, err := d.walk(document, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := meta.checkMAC(dataKey, d.hash); err != nil {
+		return nil, err
+	}
+	return json.Marshal(plaintext)
+}
+
+// parse returns the document in data in key order and its metadata block, nil if it has none
+func parse(data []byte) (yaml.MapSlice, *metadata, error) {
+	document := yaml.MapSlice{}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, nil, err
+	}
+	for _, item := range document {
+		if key, ok := item.Key.(string); !ok || key != METADATA_KEY {
+			continue
+		}
+		if _, ok := item.Value.(yaml.MapSlice); !ok {
+			return document, nil, nil
+		}
+		block, err := yaml.Marshal(item.Value)
+		if err != nil {
+			return nil, nil, err
+		}
+		meta := &metadata{}
+		if err := yaml.Unmarshal(block, meta); err != nil {
+			return nil, nil, err
+		}
+		if meta.MAC == "" || len(meta.Age)+len(meta.PGP)+len(meta.KeyGroups) == 0 {
+			return document, nil, nil
+		}
+		return document, meta, nil
+	}
+	return document, nil, nil
+}
+
+// dataKey decrypts the data key with the first age or PGP key it was encrypted to
+func (m *metadata) dataKey(keys *Keys) ([]byte, error) {
+	if keys == nil {
+		return nil, errors.New("no decryption keys")
+	}
+	for _, age := range m.Age {
+		key, err := ageDecrypt(age.Enc, keys.age)
+		if err == nil {
+			return key, nil
+		}
+		if err != errNoKey {
+			return nil, fmt.Errorf("age data key of %s: %v", age.Recipient, err)
+		}
+	}
+	for _, pgp := range m.PGP {
+		key, err := pgpDecrypt(pgp.Enc, keys.pgp)
+		if err == nil {
+			return key, nil
+		}
+		if err != errNoKey {
+			return nil, fmt.Errorf("PGP data key of %s: %v", pgp.Fingerprint, err)
+		}
+	}
+	return nil, errors.New("none of the decryption keys is a recipient of the data key")
+}
+
+// checkMAC compares the hash of the plaintext values with the MAC, encrypted with the last modification time as
+// additional data
+func (m *metadata) checkMAC(dataKey []byte, h hash.Hash) error {
+	lastModified, err := time.Parse(time.RFC3339, m.LastModified)
+	if err != nil {
+		return fmt.Errorf("invalid sops lastmodified %q", m.LastModified)
+	}
+	mac, _, err := decryptValue(m.MAC, dataKey, lastModified.Format(time.RFC3339))
+	if err != nil {
+		return errors.New("could not decrypt the sops MAC")
+	}
+	if string(mac) != fmt.Sprintf("%X", h.Sum(nil)) {
+		return errors.New("sops MAC mismatch, the document has been modified")
+	}
+	return nil
+}
+
+// decryptor decrypts the values of a document and hashes them in document order for the MAC
+type decryptor struct {
+	key              []byte
+	hash             hash.Hash
+	macOnlyEncrypted bool
+}
+
+// walk returns value with its encrypted values decrypted, maps are returned as map[string]interface{}. The
+// additional data of each value is the path of keys leading to it, list items share the path of their list.
+func (d *decryptor) walk(value interface{}, path []string) (interface{}, error) {
+	switch value := value.(type) {
+	case yaml.MapSlice:
+		object := map[string]interface{}{}
+		for _, item := range value {
+			key := fmt.Sprint(item.Key)
+			if len(path) == 0 && key == METADATA_KEY {
+				continue
+			}
+			child, err := d.walk(item.Value, append(append([]string{}, path...), key))
+			if err != nil {
+				return nil, err
+			}
+			object[key] = child
+		}
+		return object, nil
+	case []interface{}:
+		list := make([]interface{}, len(value))
+		for i, item := range value {
+			child, err := d.walk(item, path)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = child
+		}
+		return list, nil
+	case string:
+		if encryptedValue.MatchString(value) {
+			plaintext, kind, err := decryptValue(value, d.key, strings.Join(path, ":")+":")
+			if err != nil {
+				return nil, fmt.Errorf("could not decrypt %s", strings.Join(path, "."))
+			}
+			d.hash.Write(plaintext)
+			typed, err := typedValue(plaintext, kind)
+			if err != nil {
+				// The parse errors quote the plaintext
+				return nil, fmt.Errorf("invalid %s value at %s", kind, strings.Join(path, "."))
+			}
+			return typed, nil
+		}
+		d.write(value)
+		return value, nil
+	default:
+		d.write(value)
+		return value, nil
+	}
+}
+
+// write hashes an unencrypted value the way sops prints it
+func (d *decryptor) write(value interface{}) {
+	if d.macOnlyEncrypted {
+		return
+	}
+	switch value := value.(type) {
+	case string:
+		d.hash.Write([]byte(value))
+	case int:
+		d.hash.Write([]byte(strconv.Itoa(value)))
+	case int64:
+		d.hash.Write([]byte(strconv.FormatInt(value, 10)))
+	case uint64:
+		d.hash.Write([]byte(strconv.FormatUint(value, 10)))
+	case float64:
+		d.hash.Write([]byte(strconv.FormatFloat(value, 'f', -1, 64)))
+	case bool:
+		d.hash.Write([]byte(strings.Title(strconv.FormatBool(value))))
+	}
+}
+
+// decryptValue returns the plaintext and the type of an ENC[AES256_GCM,...] value
+func decryptValue(value string, key []byte, additionalData string) ([]byte, string, error) {
+	parts := encryptedValue.FindStringSubmatch(value)
+	if parts == nil {
+		return nil, "", errors.New("not an encrypted value")
+	}
+	data, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, "", err
+	}
+	iv, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, "", err
+	}
+	tag, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return nil, "", err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, "", err
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	if err != nil {
+		return nil, "", err
+	}
+	plaintext, err := gcm.Open(nil, iv, append(data, tag...), []byte(additionalData))
+	if err != nil {
+		return nil, "", err
+	}
+	return plaintext, parts[4], nil
+}
+
+func typedValue(plaintext []byte, kind string) (interface{}, error) {
+	switch kind {
+	case "str", "bytes":
+		return string(plaintext), nil
+	case "int":
+		return strconv.Atoi(string(plaintext))
+	case "float":
+		return strconv.ParseFloat(string(plaintext), 64)
+	case "bool":
+		return strconv.ParseBool(string(plaintext))
+	}
+	return nil, fmt.Errorf("unsupported sops value type %s", kind)
+}
@@ -0,0 +1,387 @@
+package sops
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"gopkg.in/yaml.v2"
+	k8syaml "sigs.k8s.io/yaml"
+)
+
+const secretYAML = `apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+type: Opaque
+stringData:
+  user: admin
+  password: hunter2
+  port: 5432
+data:
+  token: czNjcjN0
+`
+
+// The helpers below encrypt the way sops and age do, to produce test documents
+
+func bech32Encode(hrp string, data []byte) string {
+	values := []byte{}
+	acc, bits := 0, uint(0)
+	for _, b := range data {
+		acc = acc<<8 | int(b)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			values = append(values, byte(acc>>bits&31))
+		}
+	}
+	if bits > 0 {
+		values = append(values, byte(acc<<(5-bits)&31))
+	}
+	polymod := bech32Polymod(append(append(bech32ExpandHRP(hrp), values...), 0, 0, 0, 0, 0, 0)) ^ 1
+	for i := 0; i < 6; i++ {
+		values = append(values, byte(polymod>>uint(5*(5-i))&31))
+	}
+	encoded := hrp + "1"
+	for _, v := range values {
+		encoded += string(bech32Charset[v])
+	}
+	return encoded
+}
+
+func newAgeIdentity(t *testing.T) (string, [32]byte) {
+	var secret, recipient [32]byte
+	rand.Read(secret[:])
+	curve25519.ScalarBaseMult(&recipient, &secret)
+	return strings.ToUpper(bech32Encode(AGE_SECRET_KEY_HRP, secret[:])), recipient
+}
+
+func ageEncrypt(t *testing.T, recipient [32]byte, plaintext []byte) string {
+	fileKey := make([]byte, 16)
+	rand.Read(fileKey)
+
+	var ephemeral, share, shared [32]byte
+	rand.Read(ephemeral[:])
+	curve25519.ScalarBaseMult(&share, &ephemeral)
+	curve25519.ScalarMult(&shared, &ephemeral, &recipient)
+	aead, _ := chacha20poly1305.New(ageKey(shared[:], append(share[:], recipient[:]...), AGE_X25519_LABEL))
+	body := aead.Seal(nil, make([]byte, chacha20poly1305.NonceSize), fileKey, nil)
+
+	header := AGE_INTRO + "\n-> X25519 " + base64.RawStdEncoding.EncodeToString(share[:]) + "\n" +
+		base64.RawStdEncoding.EncodeToString(body) + "\n---"
+	mac := hmac.New(sha256.New, ageKey(fileKey, nil, "header"))
+	mac.Write([]byte(header))
+
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	aead, _ = chacha20poly1305.New(ageKey(fileKey, nonce, "payload"))
+	chunkNonce := make([]byte, chacha20poly1305.NonceSize)
+	chunkNonce[len(chunkNonce)-1] = 1
+	binary.BigEndian.PutUint64(chunkNonce[3:11], 0)
+	payload := aead.Seal(nonce, chunkNonce, plaintext, nil)
+
+	file := append([]byte(header+" "+base64.RawStdEncoding.EncodeToString(mac.Sum(nil))+"\n"), payload...)
+	return AGE_ARMOR_BEGIN + "\n" + base64.StdEncoding.EncodeToString(file) + "\n" + AGE_ARMOR_END + "\n"
+}
+
+func pgpEncrypt(t *testing.T, entity *openpgp.Entity, plaintext []byte) string {
+	out := &bytes.Buffer{}
+	armored, _ := armor.Encode(out, "PGP MESSAGE", nil)
+	w, err := openpgp.Encrypt(armored, []*openpgp.Entity{entity}, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(plaintext)
+	w.Close()
+	armored.Close()
+	return out.String()
+}
+
+func encryptValue(t *testing.T, value interface{}, key []byte, additionalData string) string {
+	plaintext, kind := fmt.Sprint(value), "str"
+	switch value.(type) {
+	case int:
+		kind = "int"
+	case bool:
+		plaintext, kind = strings.Title(plaintext), "bool"
+	}
+	iv := make([]byte, 32)
+	rand.Read(iv)
+	block, _ := aes.NewCipher(key)
+	gcm, _ := cipher.NewGCMWithNonceSize(block, len(iv))
+	out := gcm.Seal(nil, iv, []byte(plaintext), []byte(additionalData))
+	return fmt.Sprintf("ENC[AES256_GCM,data:%s,iv:%s,tag:%s,type:%s]", base64.StdEncoding.EncodeToString(out[:len(out)-16]),
+		base64.StdEncoding.EncodeToString(iv), base64.StdEncoding.EncodeToString(out[len(out)-16:]), kind)
+}
+
+// encryptTree encrypts the values below the top-level keys in encrypted and hashes every value for the MAC
+func encryptTree(t *testing.T, value interface{}, path []string, key []byte, encrypted map[string]bool, h hash.Hash) interface{} {
+	switch value := value.(type) {
+	case yaml.MapSlice:
+		for i, item := range value {
+			value[i].Value = encryptTree(t, item.Value, append(append([]string{}, path...), item.Key.(string)), key, encrypted, h)
+		}
+		return value
+	case []interface{}:
+		for i, item := range value {
+			value[i] = encryptTree(t, item, path, key, encrypted, h)
+		}
+		return value
+	}
+	plaintext := fmt.Sprint(value)
+	if _, ok := value.(bool); ok {
+		plaintext = strings.Title(plaintext)
+	}
+	h.Write([]byte(plaintext))
+	if encrypted[path[0]] {
+		return encryptValue(t, value, key, strings.Join(path, ":")+":")
+	}
+	return value
+}
+
+// encrypt returns document encrypted like `sops --encrypted-regex '^(data|stringData)$'` with metadata
+func encrypt(t *testing.T, document string, metadata func(dataKey []byte) yaml.MapSlice) string {
+	tree := yaml.MapSlice{}
+	if err := yaml.Unmarshal([]byte(document), &tree); err != nil {
+		t.Fatal(err)
+	}
+	dataKey := make([]byte, 32)
+	rand.Read(dataKey)
+
+	h := sha512.New()
+	encryptTree(t, tree, nil, dataKey, map[string]bool{"data": true, "stringData": true}, h)
+	lastModified := time.Now().UTC().Format(time.RFC3339)
+	meta := append(metadata(dataKey),
+		yaml.MapItem{Key: "lastmodified", Value: lastModified},
+		yaml.MapItem{Key: "mac", Value: encryptValue(t, fmt.Sprintf("%X", h.Sum(nil)), dataKey, lastModified)},
+		yaml.MapItem{Key: "encrypted_regex", Value: "^(data|stringData)$"},
+		yaml.MapItem{Key: "version", Value: "3.7.1"})
+	tree = append(tree, yaml.MapItem{Key: METADATA_KEY, Value: meta})
+
+	out, err := yaml.Marshal(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func encryptWithAge(t *testing.T, document string, recipient [32]byte) string {
+	return encrypt(t, document, func(dataKey []byte) yaml.MapSlice {
+		return yaml.MapSlice{{Key: "age", Value: []interface{}{yaml.MapSlice{
+			{Key: "recipient", Value: bech32Encode("age", recipient[:])},
+			{Key: "enc", Value: ageEncrypt(t, recipient, dataKey)},
+		}}}}
+	})
+}
+
+func checkSecret(t *testing.T, decrypted []byte) {
+	secret := struct {
+		Kind       string
+		StringData map[string]interface{}
+		Data       map[string]string
+	}{}
+	if err := json.Unmarshal(decrypted, &secret); err != nil {
+		t.Fatal(err)
+	}
+	if secret.Kind != "Secret" || secret.StringData["password"] != "hunter2" || secret.StringData["port"] != float64(5432) ||
+		secret.Data["token"] != "czNjcjN0" {
+		t.Errorf("unexpected plaintext: %s", decrypted)
+	}
+	if strings.Contains(string(decrypted), METADATA_KEY) {
+		t.Errorf("metadata not removed: %s", decrypted)
+	}
+}
+
+func TestDecryptAge(t *testing.T) {
+	identity, recipient := newAgeIdentity(t)
+	other, _ := newAgeIdentity(t)
+	encrypted := encryptWithAge(t, secretYAML, recipient)
+	if !IsEncrypted([]byte(encrypted)) || IsEncrypted([]byte(secretYAML)) {
+		t.Fatal("sops metadata not detected")
+	}
+	if strings.Contains(encrypted, "hunter2") {
+		t.Fatalf("not encrypted: %s", encrypted)
+	}
+
+	keys, err := ParseKeys(map[string][]byte{"keys.agekey": []byte("# created: today\n" + other + "\n" + identity + "\n")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := Decrypt([]byte(encrypted), keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSecret(t, decrypted)
+
+	otherKeys, _ := ParseKeys(map[string][]byte{"other.agekey": []byte(other)})
+	if _, err := Decrypt([]byte(encrypted), otherKeys); err == nil {
+		t.Error("decrypted without the key")
+	}
+
+	tampered := strings.Replace(encrypted, "name: db", "name: prod", 1)
+	if _, err := Decrypt([]byte(tampered), keys); err == nil || !strings.Contains(err.Error(), "MAC") {
+		t.Errorf("tampered document not detected: %v", err)
+	}
+}
+
+func TestDecryptPGP(t *testing.T) {
+	entity, err := openpgp.NewEntity("rocketeer", "test", "rocketeer@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, identity := range entity.Identities {
+		identity.SelfSignature.PreferredHash = []uint8{8} // SHA256
+	}
+	private := &bytes.Buffer{}
+	w, _ := armor.Encode(private, openpgp.PrivateKeyType, nil)
+	if err := entity.SerializePrivate(w, nil); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	encrypted := encrypt(t, secretYAML, func(dataKey []byte) yaml.MapSlice {
+		return yaml.MapSlice{{Key: "pgp", Value: []interface{}{yaml.MapSlice{
+			{Key: "fp", Value: fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint)},
+			{Key: "enc", Value: pgpEncrypt(t, entity, dataKey)},
+		}}}}
+	})
+
+	keys, err := ParseKeys(map[string][]byte{"sops.asc": private.Bytes()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, err := Decrypt([]byte(encrypted), keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSecret(t, decrypted)
+}
+
+func TestDecryptJSON(t *testing.T) {
+	identity, recipient := newAgeIdentity(t)
+	document := yaml.MapSlice{}
+	if err := yaml.Unmarshal([]byte(encryptWithAge(t, secretYAML, recipient)), &document); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, _ := ParseKeys(map[string][]byte{"age.agekey": []byte(identity)})
+	decrypted, err := Decrypt([]byte(toJSON(document)), keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSecret(t, decrypted)
+}
+
+// toJSON returns the JSON of value keeping the order of the keys, as the MAC depends on it
+func toJSON(value interface{}) string {
+	switch value := value.(type) {
+	case yaml.MapSlice:
+		items := []string{}
+		for _, item := range value {
+			items = append(items, toJSON(fmt.Sprint(item.Key))+":"+toJSON(item.Value))
+		}
+		return "{" + strings.Join(items, ",") + "}"
+	case []interface{}:
+		items := []string{}
+		for _, item := range value {
+			items = append(items, toJSON(item))
+		}
+		return "[" + strings.Join(items, ",") + "]"
+	}
+	out, _ := json.Marshal(value)
+	return string(out)
+}
+
+// The documents in testdata are secret.yaml encrypted by the sops 3.13 CLI to the age identity in age.agekey and
+// the PGP key in pgp.asc:
+//
+//	sops encrypt --age <age recipient> --pgp <PGP fingerprint> secret.yaml > secret.enc.yaml
+//	sops encrypt --age <age recipient> --pgp <PGP fingerprint> --output-type json secret.yaml > secret.enc.json
+//
+// secret.mac-only-encrypted.enc.yaml is encrypted the same way with mac_only_encrypted set in the creation rule of
+// the sops configuration.
+func TestDecryptGolden(t *testing.T) {
+	plaintext, err := ioutil.ReadFile("testdata/secret.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := interface{}(nil)
+	if err := k8syaml.Unmarshal(plaintext, &expected); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := map[string]*Keys{}
+	for _, name := range []string{"age.agekey", "pgp.asc"} {
+		data, err := ioutil.ReadFile("testdata/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if keys[name], err = ParseKeys(map[string][]byte{name: data}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		file             string
+		macOnlyEncrypted bool
+	}{
+		{file: "secret.enc.yaml"},
+		{file: "secret.enc.json"},
+		{file: "secret.mac-only-encrypted.enc.yaml", macOnlyEncrypted: true},
+	}
+
+	for _, test := range tests {
+		encrypted, err := ioutil.ReadFile("testdata/" + test.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !IsEncrypted(encrypted) {
+			t.Fatalf("%s: sops metadata not detected", test.file)
+		}
+		if strings.Contains(string(encrypted), "hunter2") || !strings.Contains(string(encrypted), "not a secret") {
+			t.Fatalf("%s: unexpected encryption", test.file)
+		}
+
+		for name, key := range keys {
+			decrypted, err := Decrypt(encrypted, key)
+			if err != nil {
+				t.Fatalf("%s with %s: %v", test.file, name, err)
+			}
+			actual := interface{}(nil)
+			if err := json.Unmarshal(decrypted, &actual); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(actual, expected) {
+				t.Errorf("%s with %s: unexpected plaintext %s", test.file, name, decrypted)
+			}
+		}
+
+		// Unencrypted values are only covered by the MAC without mac_only_encrypted
+		tampered := strings.Replace(string(encrypted), "not a secret", "tampered", 1)
+		_, err = Decrypt([]byte(tampered), keys["age.agekey"])
+		if test.macOnlyEncrypted && err != nil {
+			t.Errorf("%s: %v", test.file, err)
+		}
+		if !test.macOnlyEncrypted && (err == nil || !strings.Contains(err.Error(), "MAC")) {
+			t.Errorf("%s: tampered document not detected: %v", test.file, err)
+		}
+	}
+}
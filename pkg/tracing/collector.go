@@ -0,0 +1,93 @@
+package tracing
+
+import (
+	"net"
+	"sync"
+
+	agentmetricspb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/metrics/v1"
+	agenttracepb "github.com/census-instrumentation/opencensus-proto/gen-go/agent/trace/v1"
+	tracepb "github.com/census-instrumentation/opencensus-proto/gen-go/trace/v1"
+	"google.golang.org/grpc"
+)
+
+// Collector is a stand-in OpenCensus agent listening on localhost that keeps the spans it receives in memory, for
+// tests and local debugging
+type Collector struct {
+	listener net.Listener
+	server   *grpc.Server
+
+	mu        sync.Mutex
+	connected bool
+	spans     []*tracepb.Span
+}
+
+// NewCollector starts a Collector on a free port
+func NewCollector() (*Collector, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	c := &Collector{listener: listener, server: grpc.NewServer()}
+	agenttracepb.RegisterTraceServiceServer(c.server, c)
+	agentmetricspb.RegisterMetricsServiceServer(c.server, discardMetrics{})
+	go c.server.Serve(listener)
+	return c, nil
+}
+
+// Address is the endpoint to pass to Start
+func (c *Collector) Address() string {
+	return c.listener.Addr().String()
+}
+
+// Connected reports whether an exporter has connected
+func (c *Collector) Connected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.connected
+}
+
+// Spans returns the spans received so far
+func (c *Collector) Spans() []*tracepb.Span {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*tracepb.Span{}, c.spans...)
+}
+
+// Stop closes the connections and stops listening
+func (c *Collector) Stop() {
+	c.server.Stop()
+}
+
+// Config receives the trace configurations applied by exporters and never pushes new ones
+func (c *Collector) Config(stream agenttracepb.TraceService_ConfigServer) error {
+	for {
+		if _, err := stream.Recv(); err != nil {
+			return err
+		}
+	}
+}
+
+// Export receives spans, the first message of a stream only identifies the exporter
+func (c *Collector) Export(stream agenttracepb.TraceService_ExportServer) error {
+	for {
+		request, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		c.mu.Lock()
+		c.connected = true
+		c.spans = append(c.spans, request.Spans...)
+		c.mu.Unlock()
+	}
+}
+
+// discardMetrics accepts the metrics stream the exporter opens along with the trace one
+type discardMetrics struct{}
+
+func (discardMetrics) Export(stream agentmetricspb.MetricsService_ExportServer) error {
+	for {
+		if _, err := stream.Recv(); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opencensus.io/trace"
+)
+
+func waitFor(condition func() bool) bool {
+	for deadline := time.Now().Add(15 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		if condition() {
+			return true
+		}
+	}
+	return false
+}
+
+func TestExportToCollector(t *testing.T) {
+	collector, err := NewCollector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer collector.Stop()
+
+	stop, err := Start(collector.Address(), "test", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	if !waitFor(collector.Connected) {
+		t.Fatal("exporter not connected")
+	}
+
+	ctx, parent := trace.StartSpan(context.Background(), "Reconcile")
+	_, child := trace.StartSpan(ctx, "git.pull")
+	End(child, nil)
+	parent.End()
+
+	received := func() bool { return len(collector.Spans()) == 2 }
+	if !waitFor(received) {
+		t.Fatalf("spans received: %d, expected 2", len(collector.Spans()))
+	}
+	parentID := parent.SpanContext().SpanID
+	for _, span := range collector.Spans() {
+		if span.Name.GetValue() == "git.pull" && string(span.ParentSpanId) != string(parentID[:]) {
+			t.Errorf("git.pull is not a child of Reconcile")
+		}
+	}
+}
@@ -0,0 +1,43 @@
+// Package tracing exports the spans recorded by the operator to an OpenCensus agent, or to an OpenTelemetry
+// collector through its opencensus receiver
+package tracing
+
+import (
+	"time"
+
+	"contrib.go.opencensus.io/exporter/ocagent"
+	"go.opencensus.io/trace"
+)
+
+const DefaultServiceName = "rocketeer-operator"
+
+// How often the exporter tries to connect to the agent again once disconnected
+const reconnectionPeriod = 5 * time.Second
+
+// Start exports the spans of the operator to the agent at endpoint, e.g. `otel-collector:55678`, and samples the
+// given fraction of traces. The function returned flushes the spans not sent yet and stops exporting.
+func Start(endpoint string, serviceName string, fraction float64) (func(), error) {
+	exporter, err := ocagent.NewExporter(
+		ocagent.WithAddress(endpoint),
+		ocagent.WithInsecure(),
+		ocagent.WithServiceName(serviceName),
+		ocagent.WithReconnectionPeriod(reconnectionPeriod))
+	if err != nil {
+		return nil, err
+	}
+	trace.RegisterExporter(exporter)
+	trace.ApplyConfig(trace.Config{DefaultSampler: trace.ProbabilitySampler(fraction)})
+
+	return func() {
+		trace.UnregisterExporter(exporter)
+		exporter.Stop()
+	}, nil
+}
+
+// End ends span, with an error status if err is not nil
+func End(span *trace.Span, err error) {
+	if err != nil {
+		span.SetStatus(trace.Status{Code: trace.StatusCodeUnknown, Message: err.Error()})
+	}
+	span.End()
+}